@@ -0,0 +1,62 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"log"
+
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+)
+
+// addressesCmd represents the addresses command
+var addressesCmd = &cobra.Command{
+	Use:   "addresses",
+	Short: "Check email addresses across users, groups and aliases (Part of Admin SDK API)",
+	Long: `Checks whether email addresses are already taken by a user, a group, a user alias, a group alias or a domain alias.
+Uses the APIs documented at https://developers.google.com/workspace/admin/directory/reference/rest`,
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		err := cmd.Help()
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+var addressFlags map[string]*gsmhelpers.Flag = map[string]*gsmhelpers.Flag{
+	"address": {
+		AvailableFor:   []string{"check"},
+		Type:           "string",
+		Description:    "The email address to check.",
+		Required:       []string{"check"},
+		ExcludeFromAll: true,
+	},
+	"customer": {
+		AvailableFor: []string{"check"},
+		Type:         "string",
+		Description:  "The unique ID for the customer's Workspace account.",
+		Defaults:     map[string]any{"check": "my_customer"},
+	},
+}
+var addressFlagsALL = gsmhelpers.GetAllFlags(addressFlags)
+
+func init() {
+	rootCmd.AddCommand(addressesCmd)
+}
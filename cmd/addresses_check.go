@@ -0,0 +1,60 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"log"
+
+	"github.com/hanneshayashi/gsm/gsmadmin"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+)
+
+// addressesCheckCmd represents the check command
+var addressesCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Checks if an email address is available.",
+	Long: `Checks if an email address is already taken by a user, a group, a user alias or a group alias and reports the type and ID of the owning object.
+If the domain of the address is a domain alias, the corresponding address in the parent domain is checked as well.
+Addresses in domains that are not managed by the customer are reported as unavailable.
+Possible types are:
+user                   - The primary email address of a user
+userAlias              - An alias of a user
+userNonEditableAlias   - An address of a user in a domain alias (or another alias that can't be removed)
+group                  - The email address of a group
+groupAlias             - An alias of a group
+groupNonEditableAlias  - An address of a group in a domain alias (or another alias that can't be removed)`,
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := gsmhelpers.FlagsToMap(cmd.Flags())
+		domains, err := gsmadmin.GetManagedDomains(flags["customer"].GetString())
+		if err != nil {
+			log.Fatalf("Error getting domains: %v", err)
+		}
+		result := gsmadmin.CheckAddress(flags["address"].GetString(), domains)
+		err = gsmhelpers.Output(result, "json", compressOutput)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+func init() {
+	gsmhelpers.InitCommand(addressesCmd, addressesCheckCmd, addressFlags)
+}
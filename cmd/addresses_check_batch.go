@@ -0,0 +1,137 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"log"
+	"sync"
+
+	"github.com/hanneshayashi/gsm/gsmadmin"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+)
+
+// addressesCheckBatchCmd represents the batch command
+var addressesCheckBatchCmd = &cobra.Command{
+	Use:   "batch",
+	Short: "Batch checks if email addresses are available using a CSV file as input.",
+	Long: `Checks if email addresses are already taken by a user, a group, a user alias or a group alias.
+See "gsm addresses check --help" for details.
+Addresses that appear more than once in the file are marked as duplicates.
+Use --preflight before bulk provisioning users, groups or aliases from the same file.
+In pre-flight mode, the command exits with a non-zero exit code if any of the addresses is not available.`,
+	Annotations: map[string]string{
+		"crescendoAttachToParent": "true",
+	},
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		maps, err := gsmhelpers.GetBatchMaps(cmd, addressFlags)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		preflight := gsmhelpers.FlagsToMap(cmd.Flags())["preflight"].GetBool()
+		domains := make(map[string]map[string]*gsmadmin.ManagedDomain)
+		var mu sync.Mutex
+		getDomains := func(customer string) (map[string]*gsmadmin.ManagedDomain, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if d, ok := domains[customer]; ok {
+				return d, nil
+			}
+			d, err := gsmadmin.GetManagedDomains(customer)
+			if err != nil {
+				return nil, err
+			}
+			domains[customer] = d
+			return d, nil
+		}
+		var wg sync.WaitGroup
+		cap := cap(maps)
+		results := make(chan *gsmadmin.AddressCheckResult, cap)
+		go func() {
+			for i := 0; i < cap; i++ {
+				wg.Add(1)
+				go func() {
+					for m := range maps {
+						d, err := getDomains(m["customer"].GetString())
+						if err != nil {
+							log.Println(err)
+							results <- &gsmadmin.AddressCheckResult{Address: m["address"].GetString(), Error: err.Error()}
+							continue
+						}
+						results <- gsmadmin.CheckAddress(m["address"].GetString(), d)
+					}
+					wg.Done()
+				}()
+			}
+			wg.Wait()
+			close(results)
+		}()
+		seen := make(map[string]bool)
+		unavailable := 0
+		total := 0
+		check := func(r *gsmadmin.AddressCheckResult) {
+			total++
+			if seen[r.Address] {
+				r.Duplicate = true
+				r.Available = false
+			}
+			seen[r.Address] = true
+			if !r.Available {
+				unavailable++
+			}
+		}
+		if streamOutput {
+			enc := gsmhelpers.GetJSONEncoder(false)
+			for r := range results {
+				check(r)
+				err := enc.Encode(r)
+				if err != nil {
+					log.Println(err)
+				}
+			}
+		} else {
+			final := []*gsmadmin.AddressCheckResult{}
+			for res := range results {
+				check(res)
+				final = append(final, res)
+			}
+			err := gsmhelpers.Output(final, "json", compressOutput)
+			if err != nil {
+				log.Fatalln(err)
+			}
+		}
+		if preflight && unavailable > 0 {
+			log.Fatalf("Pre-flight check failed: %d of %d addresses are not available\n", unavailable, total)
+		}
+	},
+}
+
+func init() {
+	addressCheckBatchFlags := make(map[string]*gsmhelpers.Flag, len(batchFlags)+1)
+	for k, v := range batchFlags {
+		addressCheckBatchFlags[k] = v
+	}
+	addressCheckBatchFlags["preflight"] = &gsmhelpers.Flag{
+		AvailableFor: []string{"batch"},
+		Type:         "bool",
+		Description:  "Exit with a non-zero exit code if any of the addresses is not available or appears more than once in the file.",
+	}
+	gsmhelpers.InitBatchCommand(addressesCheckCmd, addressesCheckBatchCmd, addressFlags, addressFlagsALL, addressCheckBatchFlags)
+}
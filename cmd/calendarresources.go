@@ -49,11 +49,11 @@ var calendarResourceFlags map[string]*gsmhelpers.Flag = map[string]*gsmhelpers.F
 		ExcludeFromAll: true,
 	},
 	"customer": {
-		AvailableFor: []string{"delete", "get", "insert", "list", "patch"},
+		AvailableFor: []string{"delete", "generate", "get", "insert", "list", "patch"},
 		Type:         "string",
 		Description: `The unique ID for the customer's Workspace account.
 As an account administrator, you can also use the my_customer alias to represent your account's customer ID.`,
-		Defaults: map[string]any{"delete": "my_customer", "get": "my_customer", "insert": "my_customer", "list": "my_customer", "patch": "my_customer"},
+		Defaults: map[string]any{"delete": "my_customer", "generate": "my_customer", "get": "my_customer", "insert": "my_customer", "list": "my_customer", "patch": "my_customer"},
 	},
 	"resourceId": {
 		AvailableFor:   []string{"insert"},
@@ -142,6 +142,42 @@ An asterisk (*) is only valid at end of value, it cannot be used at start or mid
 Query strings are case sensitive.
 Supported fields include generatedResourceName, resourceName, name, buildingId, featureInstances.feature.name.`,
 	},
+	"definition": {
+		AvailableFor: []string{"generate"},
+		Type:         "string",
+		Description: `Path to a YAML (or JSON) file that defines the rooms of one or more buildings, e.g.:
+buildings:
+- buildingId: MUC1
+  floors:
+  - name: "3"
+    rooms:
+    - name: Alpspitze
+      capacity: 8
+      section: North
+      features: [Projector, Video conferencing]
+Rooms can also define category, type, description and userVisibleDescription.`,
+		Required: []string{"generate"},
+	},
+	"nameTemplate": {
+		AvailableFor: []string{"generate"},
+		Type:         "string",
+		Description: `Go template for the name of the resources.
+The template can use the fields .Building, .BuildingName, .Floor, .Section, .Name, .Capacity, .Category and .Type
+and the functions lower, upper and slug.`,
+		Defaults: map[string]any{"generate": "{{.Building}}-{{.Floor}}-{{.Name}} ({{.Capacity}})"},
+	},
+	"idTemplate": {
+		AvailableFor: []string{"generate"},
+		Type:         "string",
+		Description: `Go template for the resourceId of the resources. See --nameTemplate for the available fields and functions.
+The resourceId is used to find existing resources, so it should not change once the resources are created.`,
+		Defaults: map[string]any{"generate": "{{slug .Building}}-{{slug .Floor}}-{{slug .Name}}"},
+	},
+	"dryRun": {
+		AvailableFor: []string{"generate"},
+		Type:         "bool",
+		Description:  "Only report the features and resources that would be created or patched.",
+	},
 	"fields": {
 		AvailableFor: []string{"get", "insert", "list", "patch"},
 		Type:         "string",
@@ -0,0 +1,176 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"log"
+	"sync"
+
+	"github.com/hanneshayashi/gsm/gsmadmin"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// calendarResourcesGenerateCmd represents the generate command
+var calendarResourcesGenerateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Creates or updates calendar resources from a definition of buildings, floors and rooms.",
+	Long: `Generates the name and resourceId of every room in the definition with Go templates and creates or patches the calendar resources accordingly.
+Buildings and floors are validated before any changes are made. Features that don't exist yet are created.
+Existing resources are found by their resourceId and only patched if they differ from the definition, so the command can be run repeatedly.
+Implements the APIs documented at:
+https://developers.google.com/workspace/admin/directory/reference/rest/v1/resources.buildings/get
+https://developers.google.com/workspace/admin/directory/reference/rest/v1/resources.features
+https://developers.google.com/workspace/admin/directory/reference/rest/v1/resources.calendars`,
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := gsmhelpers.FlagsToMap(cmd.Flags())
+		definition, err := gsmadmin.LoadCalendarResourceDefinition(flags["definition"].GetString())
+		if err != nil {
+			log.Fatalf("Error loading definition: %v", err)
+		}
+		customer := flags["customer"].GetString()
+		dryRun := flags["dryRun"].GetBool()
+		threads := gsmhelpers.MaxThreads(0)
+		buildings := make(map[string]*admin.Building)
+		for _, id := range definition.BuildingIDs() {
+			b, err := gsmadmin.GetBuilding(customer, id, "buildingId,buildingName,floorNames")
+			if err != nil {
+				if !gsmhelpers.IsNotFound(err) {
+					log.Fatalf("Error getting building %s: %v", id, err)
+				}
+				continue
+			}
+			buildings[id] = b
+		}
+		resources, err := gsmadmin.GenerateCalendarResources(definition, buildings, flags["nameTemplate"].GetString(), flags["idTemplate"].GetString())
+		if err != nil {
+			log.Fatalf("Invalid definition:\n%v", err)
+		}
+		type featureResult struct {
+			Name   string `json:"name"`
+			Status string `json:"status"`
+			Error  string `json:"error,omitempty"`
+		}
+		type resourceResult struct {
+			ResourceID   string   `json:"resourceId"`
+			ResourceName string   `json:"resourceName"`
+			Status       string   `json:"status"`
+			Changes      []string `json:"changes,omitempty"`
+			Error        string   `json:"error,omitempty"`
+		}
+		result := struct {
+			Features  []*featureResult  `json:"features,omitempty"`
+			Resources []*resourceResult `json:"resources"`
+		}{}
+		featuresChan, errChan := gsmadmin.ListFeatures(customer, "features(name),nextPageToken", threads)
+		existingFeatures := make(map[string]bool)
+		for f := range featuresChan {
+			existingFeatures[f.Name] = true
+		}
+		if e := <-errChan; e != nil {
+			log.Fatalf("Error listing features: %v", e)
+		}
+		for _, name := range definition.FeatureNames() {
+			r := &featureResult{Name: name, Status: "exists"}
+			result.Features = append(result.Features, r)
+			if existingFeatures[name] {
+				continue
+			}
+			if dryRun {
+				r.Status = "wouldCreate"
+				continue
+			}
+			_, err := gsmadmin.InsertFeature(customer, "name", &admin.Feature{Name: name})
+			if err != nil {
+				log.Fatalf("Error creating feature %s: %v", name, err)
+			}
+			r.Status = "created"
+		}
+		resourcesChan, errChan := gsmadmin.ListCalendarResources(customer, "", "", "items(resourceId,resourceName,buildingId,floorName,floorSection,capacity,resourceCategory,resourceType,resourceDescription,userVisibleDescription,featureInstances),nextPageToken", threads)
+		existingResources := make(map[string]*admin.CalendarResource)
+		for r := range resourcesChan {
+			existingResources[r.ResourceId] = r
+		}
+		if e := <-errChan; e != nil {
+			log.Fatalf("Error listing calendar resources: %v", e)
+		}
+		type job struct {
+			result   *resourceResult
+			resource *admin.CalendarResource
+			patch    bool
+		}
+		jobs := make(chan *job, threads)
+		var wg sync.WaitGroup
+		for range threads {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := range jobs {
+					var err error
+					if j.patch {
+						_, err = gsmadmin.PatchCalendarResource(customer, j.result.ResourceID, "resourceId", j.resource)
+						j.result.Status = "patched"
+					} else {
+						_, err = gsmadmin.InsertCalendarResource(customer, "resourceId", j.resource)
+						j.result.Status = "created"
+					}
+					if err != nil {
+						log.Println(err)
+						j.result.Status = "failed"
+						j.result.Error = err.Error()
+					}
+				}
+			}()
+		}
+		for _, resource := range resources {
+			r := &resourceResult{ResourceID: resource.ResourceId, ResourceName: resource.ResourceName}
+			result.Resources = append(result.Resources, r)
+			existing, ok := existingResources[resource.ResourceId]
+			if !ok {
+				r.Status = "wouldCreate"
+				if !dryRun {
+					jobs <- &job{result: r, resource: resource}
+				}
+				continue
+			}
+			patch, changes := gsmadmin.CalendarResourcePatch(existing, resource)
+			if len(changes) == 0 {
+				r.Status = "unchanged"
+				continue
+			}
+			r.Changes = changes
+			r.Status = "wouldPatch"
+			if !dryRun {
+				jobs <- &job{result: r, resource: patch, patch: true}
+			}
+		}
+		close(jobs)
+		wg.Wait()
+		err = gsmhelpers.Output(result, "json", compressOutput)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+func init() {
+	gsmhelpers.InitCommand(calendarResourcesCmd, calendarResourcesGenerateCmd, calendarResourceFlags)
+}
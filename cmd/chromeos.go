@@ -76,13 +76,25 @@ REMOTE_POWERWASH   - Wipes the device by performing a power wash.
 					 Use with caution, as this is an irreversible action!`,
 		Required: []string{"issueCommand"},
 	},
+	"wait": {
+		AvailableFor: []string{"issueCommand"},
+		Type:         "bool",
+		Description: `Wait until the command has been executed, has expired or has been cancelled and output the final command including the result.
+The state of the command is polled with an exponential backoff.`,
+	},
+	"timeout": {
+		AvailableFor: []string{"issueCommand"},
+		Type:         "int",
+		Description:  `Maximum time in minutes to wait for the command when using --wait. Must be greater than 0.`,
+		Defaults:     map[string]any{"issueCommand": 10},
+	},
 	"payload": {
 		AvailableFor: []string{"issueCommand"},
 		Type:         "string",
 		Description:  `The payload for the command, provide it only if command supports it. The following commands support adding payload: - SET_VOLUME: Payload is a stringified JSON object in the form: { "volume": 50 }. The volume has to be an integer in the range [0,100].`,
 	},
 }
-var chromeOsFlagsALL = gsmhelpers.GetAllFlags(chromeOsDeviceFlags)
+var chromeOsFlagsALL = gsmhelpers.GetAllFlags(chromeOsFlags)
 
 func init() {
 	rootCmd.AddCommand(chromeOsCmd)
@@ -19,6 +19,7 @@ package cmd
 
 import (
 	"log"
+	"time"
 
 	"github.com/hanneshayashi/gsm/gsmadmin"
 	"github.com/hanneshayashi/gsm/gsmhelpers"
@@ -28,9 +29,10 @@ import (
 
 // chromeOsIssueCommandCmd represents the issueCommand command
 var chromeOsIssueCommandCmd = &cobra.Command{
-	Use:               "issueCommand",
-	Short:             "Takes an issueCommand that affects a Chrome OS Device. This includes deprovisioning, disabling, and re-enabling devices.",
-	Long:              "Implements the API documented at https://developers.google.com/workspace/admin/directory/reference/rest/v1/customer.devices.chromeos/issueCommand",
+	Use:   "issueCommand",
+	Short: "Takes an issueCommand that affects a Chrome OS Device. This includes deprovisioning, disabling, and re-enabling devices.",
+	Long: `Use --wait to wait for the command to finish and output the final command including the result instead of the command ID.
+Implements the API documented at https://developers.google.com/workspace/admin/directory/reference/rest/v1/customer.devices.chromeos/issueCommand`,
 	DisableAutoGenTag: true,
 	Run: func(cmd *cobra.Command, _ []string) {
 		flags := gsmhelpers.FlagsToMap(cmd.Flags())
@@ -38,10 +40,29 @@ var chromeOsIssueCommandCmd = &cobra.Command{
 		if err != nil {
 			log.Fatalf("Error building DirectoryChromeosdevicesIssueCommandRequest object: %v", err)
 		}
-		result, err := gsmadmin.IssueCommand(flags["customerId"].GetString(), flags["deviceId"].GetString(), i)
+		if flags["wait"].GetBool() && flags["timeout"].GetInt() <= 0 {
+			log.Fatalln("--timeout must be greater than 0 when using --wait")
+		}
+		customerID := flags["customerId"].GetString()
+		deviceID := flags["deviceId"].GetString()
+		result, err := gsmadmin.IssueCommand(customerID, deviceID, i)
 		if err != nil {
 			log.Fatalf("Error issuing command to Chrome OS device: %v", err)
 		}
+		if flags["wait"].GetBool() {
+			command, err := gsmadmin.WaitForCommand(customerID, deviceID, result, time.Duration(flags["timeout"].GetInt())*time.Minute)
+			if err != nil {
+				if command != nil {
+					_ = gsmhelpers.Output(command, "json", compressOutput)
+				}
+				log.Fatalf("Error waiting for command: %v", err)
+			}
+			err = gsmhelpers.Output(command, "json", compressOutput)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			return
+		}
 		err = gsmhelpers.Output(result, "json", compressOutput)
 		if err != nil {
 			log.Fatalln(err)
@@ -20,18 +20,21 @@ package cmd
 import (
 	"log"
 	"sync"
+	"time"
 
 	"github.com/hanneshayashi/gsm/gsmadmin"
 	"github.com/hanneshayashi/gsm/gsmhelpers"
 
 	"github.com/spf13/cobra"
+	admin "google.golang.org/api/admin/directory/v1"
 )
 
 // chromeOsIssueCommandBatchCmd represents the batch command
 var chromeOsIssueCommandBatchCmd = &cobra.Command{
 	Use:   "batch",
 	Short: "Batch issues commands to Chrome OS devices using a CSV file as input",
-	Long:  "Implements the API documented at https://developers.google.com/workspace/admin/directory/reference/rest/v1/customer.devices.chromeos/issueCommand",
+	Long: `Use --wait to wait for the commands to finish. The final commands including the results are added to the output.
+Implements the API documented at https://developers.google.com/workspace/admin/directory/reference/rest/v1/customer.devices.chromeos/issueCommand`,
 	Annotations: map[string]string{
 		"crescendoAttachToParent": "true",
 	},
@@ -42,11 +45,14 @@ var chromeOsIssueCommandBatchCmd = &cobra.Command{
 			log.Fatalln(err)
 		}
 		var wg sync.WaitGroup
+		var wgWait sync.WaitGroup
 		cap := cap(maps)
 		type resultStruct struct {
-			DeviceID    string `json:"deviceId,omitempty"`
-			CommandType string `json:"commandType,omitempty"`
-			CommandID   int64  `json:"commandId"`
+			DeviceID    string                                 `json:"deviceId,omitempty"`
+			CommandType string                                 `json:"commandType,omitempty"`
+			CommandID   int64                                  `json:"commandId"`
+			Command     *admin.DirectoryChromeosdevicesCommand `json:"command,omitempty"`
+			Error       string                                 `json:"error,omitempty"`
 		}
 		results := make(chan resultStruct, cap)
 		go func() {
@@ -59,18 +65,40 @@ var chromeOsIssueCommandBatchCmd = &cobra.Command{
 							log.Printf("Error building DirectoryChromeosdevicesIssueCommandRequest object: %v\n", err)
 							continue
 						}
+						customerID := m["customerId"].GetString()
 						deviceID := m["deviceId"].GetString()
-						result, err := gsmadmin.IssueCommand(m["customerId"].GetString(), deviceID, i)
+						if m["wait"].GetBool() && m["timeout"].GetInt() <= 0 {
+							log.Printf("Not issuing command to device %s: timeout must be greater than 0 when using wait\n", deviceID)
+							continue
+						}
+						result, err := gsmadmin.IssueCommand(customerID, deviceID, i)
 						if err != nil {
 							log.Println(err)
-						} else {
-							results <- resultStruct{DeviceID: deviceID, CommandID: result, CommandType: i.CommandType}
+							continue
+						}
+						r := resultStruct{DeviceID: deviceID, CommandID: result, CommandType: i.CommandType}
+						if !m["wait"].GetBool() {
+							results <- r
+							continue
 						}
+						// Wait in a separate goroutine so that waiting doesn't block issuing the remaining commands
+						wgWait.Add(1)
+						go func(timeout time.Duration) {
+							defer wgWait.Done()
+							command, err := gsmadmin.WaitForCommand(customerID, deviceID, result, timeout)
+							if err != nil {
+								log.Println(err)
+								r.Error = err.Error()
+							}
+							r.Command = command
+							results <- r
+						}(time.Duration(m["timeout"].GetInt()) * time.Minute)
 					}
 					wg.Done()
 				}()
 			}
 			wg.Wait()
+			wgWait.Wait()
 			close(results)
 		}()
 		if streamOutput {
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"log"
 
+	"github.com/hanneshayashi/gsm/gsmadmin"
 	"github.com/hanneshayashi/gsm/gsmhelpers"
 
 	"github.com/spf13/cobra"
@@ -96,6 +97,20 @@ The deviceIds are returned in the response from the chromeosdevices.list method.
 		Required:       []string{"get", "patch"},
 		ExcludeFromAll: true,
 	},
+	"idType": {
+		AvailableFor: []string{"action", "moveToOU", "patch"},
+		Type:         "string",
+		Description: `Specifies how the values of resourceId, deviceId or deviceIds are interpreted.
+Serial numbers, asset IDs and MAC addresses are resolved to device IDs by searching the devices of the customer.
+Values that match no device or more than one device are reported and skipped.
+
+Acceptable values are:
+deviceId      - The unique ID of the device (default)
+serialNumber  - The serial number of the device
+assetId       - The asset ID as noted by an administrator or specified during enrollment
+macAddress    - The WiFi or Ethernet MAC address of the device`,
+		Defaults: map[string]any{"action": "deviceId", "moveToOU": "deviceId", "patch": "deviceId"},
+	},
 	"projection": {
 		AvailableFor: []string{"get", "list", "patch"},
 		Type:         "string",
@@ -143,8 +158,9 @@ DESCENDING  - Descending order.`,
 	"deviceIds": {
 		AvailableFor: []string{"moveToOU"},
 		Type:         "stringSlice",
-		Description:  `Chrome OS devices to be moved to OU`,
-		Required:     []string{"moveToOU"},
+		Description: `Chrome OS devices to be moved to OU.
+More than 50 devices are moved in multiple requests.`,
+		Required: []string{"moveToOU"},
 	},
 	"annotatedAssetId": {
 		AvailableFor: []string{"patch"},
@@ -202,7 +218,7 @@ func mapToChromeOsDeviceAction(flags map[string]*gsmhelpers.Value) (*admin.Chrom
 
 func mapToChromeOsMoveDevicesToOu(flags map[string]*gsmhelpers.Value) (*admin.ChromeOsMoveDevicesToOu, error) {
 	chromeOsMoveDevicesToOu := &admin.ChromeOsMoveDevicesToOu{}
-	chromeOsMoveDevicesToOu.DeviceIds = flags["deviceId"].GetStringSlice()
+	chromeOsMoveDevicesToOu.DeviceIds = flags["deviceIds"].GetStringSlice()
 	return chromeOsMoveDevicesToOu, nil
 }
 
@@ -226,8 +242,8 @@ func mapToChromeOsDevice(flags map[string]*gsmhelpers.Value) (*admin.ChromeOsDev
 			chromeOsDevice.ForceSendFields = append(chromeOsDevice.ForceSendFields, "Notes")
 		}
 	}
-	if flags["annotatedAssetID"].IsSet() {
-		chromeOsDevice.AnnotatedAssetId = flags["annotatedAssetID"].GetString()
+	if flags["annotatedAssetId"].IsSet() {
+		chromeOsDevice.AnnotatedAssetId = flags["annotatedAssetId"].GetString()
 		if chromeOsDevice.AnnotatedAssetId == "" {
 			chromeOsDevice.ForceSendFields = append(chromeOsDevice.ForceSendFields, "AnnotatedAssetId")
 		}
@@ -240,3 +256,43 @@ func mapToChromeOsDevice(flags map[string]*gsmhelpers.Value) (*admin.ChromeOsDev
 	}
 	return chromeOsDevice, nil
 }
+
+type chromeOsDeviceMoveResult struct {
+	Device      string `json:"device"`
+	DeviceID    string `json:"deviceId,omitempty"`
+	OrgUnitPath string `json:"orgUnitPath"`
+	Result      bool   `json:"result"`
+	Error       string `json:"error,omitempty"`
+}
+
+// moveChromeOsDevicesToOU resolves the specified devices to device IDs and moves them to the orgUnit in chunks of 50 devices
+func moveChromeOsDevicesToOU(resolver *gsmadmin.ChromeOsDeviceResolver, customerID, orgUnitPath, idType string, devices []string) []*chromeOsDeviceMoveResult {
+	results := make([]*chromeOsDeviceMoveResult, 0, len(devices))
+	var resolved []*chromeOsDeviceMoveResult
+	for _, d := range devices {
+		r := &chromeOsDeviceMoveResult{Device: d, OrgUnitPath: orgUnitPath}
+		results = append(results, r)
+		var err error
+		r.DeviceID, err = resolver.Resolve(customerID, idType, d)
+		if err != nil {
+			r.Error = err.Error()
+			continue
+		}
+		resolved = append(resolved, r)
+	}
+	for i := 0; i < len(resolved); i += 50 {
+		chunk := resolved[i:min(i+50, len(resolved))]
+		move := &admin.ChromeOsMoveDevicesToOu{}
+		for _, r := range chunk {
+			move.DeviceIds = append(move.DeviceIds, r.DeviceID)
+		}
+		result, err := gsmadmin.MoveChromeOSDevicesToOU(customerID, orgUnitPath, move)
+		for _, r := range chunk {
+			r.Result = result
+			if err != nil {
+				r.Error = err.Error()
+			}
+		}
+	}
+	return results
+}
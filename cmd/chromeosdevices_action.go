@@ -38,7 +38,12 @@ var chromeOsDevicesActionCmd = &cobra.Command{
 		if err != nil {
 			log.Fatalf("Error building chromeOsDeviceAction object: %v", err)
 		}
-		result, err := gsmadmin.TakeActionOnChromeOsDevice(flags["customerId"].GetString(), flags["resourceId"].GetString(), a)
+		customerID := flags["customerId"].GetString()
+		resourceID, err := gsmadmin.NewChromeOsDeviceResolver().Resolve(customerID, flags["idType"].GetString(), flags["resourceId"].GetString())
+		if err != nil {
+			log.Fatalf("Error resolving Chrome OS device: %v", err)
+		}
+		result, err := gsmadmin.TakeActionOnChromeOsDevice(customerID, resourceID, a)
 		if err != nil {
 			log.Fatalf("Error taking action on Chrome OS device: %v", err)
 		}
@@ -41,11 +41,14 @@ var chromeOsDevicesActionBatchCmd = &cobra.Command{
 		if err != nil {
 			log.Fatalln(err)
 		}
+		resolver := gsmadmin.NewChromeOsDeviceResolver()
 		var wg sync.WaitGroup
 		cap := cap(maps)
 		type resultStruct struct {
+			Device     string `json:"device,omitempty"`
 			ResourceID string `json:"resourceId,omitempty"`
 			Result     bool   `json:"result"`
+			Error      string `json:"error,omitempty"`
 		}
 		results := make(chan resultStruct, cap)
 		go func() {
@@ -58,12 +61,19 @@ var chromeOsDevicesActionBatchCmd = &cobra.Command{
 							log.Printf("Error building chromeOsDeviceAction object: %v", err)
 							continue
 						}
-						resourceID := m["resourceId"].GetString()
-						result, err := gsmadmin.TakeActionOnChromeOsDevice(m["customerId"].GetString(), resourceID, a)
+						customerID := m["customerId"].GetString()
+						device := m["resourceId"].GetString()
+						resourceID, err := resolver.Resolve(customerID, m["idType"].GetString(), device)
+						if err != nil {
+							log.Println(err)
+							results <- resultStruct{Device: device, Error: err.Error()}
+							continue
+						}
+						result, err := gsmadmin.TakeActionOnChromeOsDevice(customerID, resourceID, a)
 						if err != nil {
 							log.Println(err)
 						}
-						results <- resultStruct{ResourceID: resourceID, Result: result}
+						results <- resultStruct{Device: device, ResourceID: resourceID, Result: result}
 					}
 					wg.Done()
 				}()
@@ -28,9 +28,12 @@ import (
 
 // chromeOsDevicesMoveToOUCmd represents the moveToOU command
 var chromeOsDevicesMoveToOUCmd = &cobra.Command{
-	Use:               "moveToOU",
-	Short:             "Move or insert multiple Chrome OS devices to an organizational unit. You can move up to 50 devices at once.",
-	Long:              "Implements the API documented at https://developers.google.com/workspace/admin/directory/reference/rest/v1/chromeosdevices/moveDevicesToOu",
+	Use:   "moveToOU",
+	Short: "Move or insert multiple Chrome OS devices to an organizational unit.",
+	Long: `More than 50 devices are moved in multiple requests.
+The output contains the result of every device, including the resolved device ID and the error for devices that could not be resolved (e.g. ambiguous or missing matches) or moved.
+The command exits with a non-zero status if any device could not be moved.
+Implements the API documented at https://developers.google.com/workspace/admin/directory/reference/rest/v1/chromeosdevices/moveDevicesToOu`,
 	DisableAutoGenTag: true,
 	Run: func(cmd *cobra.Command, _ []string) {
 		flags := gsmhelpers.FlagsToMap(cmd.Flags())
@@ -38,14 +41,23 @@ var chromeOsDevicesMoveToOUCmd = &cobra.Command{
 		if err != nil {
 			log.Fatalf("Error building ChromeOsMoveDevicesToOu object: %v", err)
 		}
-		result, err := gsmadmin.MoveChromeOSDevicesToOU(flags["customerId"].GetString(), flags["orgUnitPath"].GetString(), d)
-		if err != nil {
-			log.Fatalf("Error moving Chrome OS device: %v", err)
+		results := moveChromeOsDevicesToOU(gsmadmin.NewChromeOsDeviceResolver(), flags["customerId"].GetString(), flags["orgUnitPath"].GetString(), flags["idType"].GetString(), d.DeviceIds)
+		failed := 0
+		for _, r := range results {
+			if r.Error != "" {
+				log.Printf("Error moving Chrome OS device %s: %s\n", r.Device, r.Error)
+			}
+			if !r.Result {
+				failed++
+			}
 		}
-		err = gsmhelpers.Output(result, "json", compressOutput)
+		err = gsmhelpers.Output(results, "json", compressOutput)
 		if err != nil {
 			log.Fatalln(err)
 		}
+		if failed > 0 {
+			log.Fatalf("%d Chrome OS devices could not be moved", failed)
+		}
 	},
 }
 
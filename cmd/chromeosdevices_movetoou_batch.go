@@ -0,0 +1,112 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"log"
+	"sync"
+
+	"github.com/hanneshayashi/gsm/gsmadmin"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+)
+
+// chromeOsDevicesMoveToOUBatchCmd represents the batch command
+var chromeOsDevicesMoveToOUBatchCmd = &cobra.Command{
+	Use:   "batch",
+	Short: "Batch moves Chrome OS devices to organizational units using a CSV file as input.",
+	Long: `Devices that are moved to the same organizational unit are moved together in chunks of 50 devices.
+Implements the API documented at https://developers.google.com/workspace/admin/directory/reference/rest/v1/chromeosdevices/moveDevicesToOu`,
+	Annotations: map[string]string{
+		"crescendoAttachToParent": "true",
+	},
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		maps, err := gsmhelpers.GetBatchMaps(cmd, chromeOsDeviceFlags)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		type moveKey struct {
+			customerID  string
+			orgUnitPath string
+			idType      string
+		}
+		resolver := gsmadmin.NewChromeOsDeviceResolver()
+		moves := make(map[moveKey][]string)
+		var keys []moveKey
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for i := 0; i < cap(maps); i++ {
+			wg.Add(1)
+			go func() {
+				for m := range maps {
+					k := moveKey{customerID: m["customerId"].GetString(), orgUnitPath: m["orgUnitPath"].GetString(), idType: m["idType"].GetString()}
+					devices := m["deviceIds"].GetStringSlice()
+					// Resolve the devices in parallel. The results are cached by the resolver.
+					for _, d := range devices {
+						_, _ = resolver.Resolve(k.customerID, k.idType, d)
+					}
+					mu.Lock()
+					if _, ok := moves[k]; !ok {
+						keys = append(keys, k)
+					}
+					moves[k] = append(moves[k], devices...)
+					mu.Unlock()
+				}
+				wg.Done()
+			}()
+		}
+		wg.Wait()
+		final := []*chromeOsDeviceMoveResult{}
+		failed := 0
+		for _, k := range keys {
+			results := moveChromeOsDevicesToOU(resolver, k.customerID, k.orgUnitPath, k.idType, moves[k])
+			for _, r := range results {
+				if r.Error != "" {
+					log.Printf("Error moving Chrome OS device %s: %s\n", r.Device, r.Error)
+				}
+				if !r.Result {
+					failed++
+				}
+			}
+			final = append(final, results...)
+		}
+		if streamOutput {
+			enc := gsmhelpers.GetJSONEncoder(false)
+			for _, r := range final {
+				err := enc.Encode(r)
+				if err != nil {
+					log.Println(err)
+				}
+			}
+		} else {
+			err := gsmhelpers.Output(final, "json", compressOutput)
+			if err != nil {
+				log.Fatalln(err)
+			}
+		}
+		if failed > 0 {
+			log.Fatalf("%d Chrome OS devices could not be moved", failed)
+		}
+	},
+}
+
+func init() {
+	gsmhelpers.InitBatchCommand(chromeOsDevicesMoveToOUCmd, chromeOsDevicesMoveToOUBatchCmd, chromeOsDeviceFlags, chromeOsDeviceFlagsALL, batchFlags)
+}
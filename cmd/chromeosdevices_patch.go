@@ -38,7 +38,12 @@ var chromeOsDevicesPatchCmd = &cobra.Command{
 		if err != nil {
 			log.Fatalf("Error building chromeOsDevice object: %v", err)
 		}
-		result, err := gsmadmin.PatchChromeOsDevice(flags["customerId"].GetString(), flags["deviceId"].GetString(), flags["fields"].GetString(), flags["projection"].GetString(), c)
+		customerID := flags["customerId"].GetString()
+		deviceID, err := gsmadmin.NewChromeOsDeviceResolver().Resolve(customerID, flags["idType"].GetString(), flags["deviceId"].GetString())
+		if err != nil {
+			log.Fatalf("Error resolving Chrome OS device: %v", err)
+		}
+		result, err := gsmadmin.PatchChromeOsDevice(customerID, deviceID, flags["fields"].GetString(), flags["projection"].GetString(), c)
 		if err != nil {
 			log.Fatalf("Error patching Chrome OS device: %v", err)
 		}
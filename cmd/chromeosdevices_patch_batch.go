@@ -42,6 +42,7 @@ var chromeOsDevicesPatchBatchCmd = &cobra.Command{
 		if err != nil {
 			log.Fatalln(err)
 		}
+		resolver := gsmadmin.NewChromeOsDeviceResolver()
 		var wg sync.WaitGroup
 		cap := cap(maps)
 		results := make(chan *admin.ChromeOsDevice, cap)
@@ -55,7 +56,13 @@ var chromeOsDevicesPatchBatchCmd = &cobra.Command{
 							log.Printf("Error building chromeOsDevice object: %v\n", err)
 							continue
 						}
-						result, err := gsmadmin.PatchChromeOsDevice(m["customerId"].GetString(), m["deviceId"].GetString(), m["fields"].GetString(), m["projection"].GetString(), c)
+						customerID := m["customerId"].GetString()
+						deviceID, err := resolver.Resolve(customerID, m["idType"].GetString(), m["deviceId"].GetString())
+						if err != nil {
+							log.Println(err)
+							continue
+						}
+						result, err := gsmadmin.PatchChromeOsDevice(customerID, deviceID, m["fields"].GetString(), m["projection"].GetString(), c)
 						if err != nil {
 							log.Println(err)
 						} else {
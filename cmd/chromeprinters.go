@@ -43,7 +43,7 @@ var chromePrintersCmd = &cobra.Command{
 
 var chromePrinterFlags map[string]*gsmhelpers.Flag = map[string]*gsmhelpers.Flag{
 	"parent": {
-		AvailableFor: []string{"batchCreate", "batchDelete", "create", "list", "listModels"},
+		AvailableFor: []string{"batchCreate", "batchDelete", "create", "import", "list", "listModels"},
 		Type:         "string",
 		Description:  `The name of the customer. Format: customers/{customer_id}`,
 	},
@@ -95,10 +95,10 @@ Value must be in format as seen in printers.listPrinterModels response.`,
 		Required:     []string{"create"},
 	},
 	"orgUnitId": {
-		AvailableFor: []string{"create"},
+		AvailableFor: []string{"create", "import"},
 		Type:         "string",
 		Description:  `Organization Unit`,
-		Required:     []string{"create"},
+		Required:     []string{"create", "import"},
 	},
 	"useDriverlessConfig": {
 		AvailableFor: []string{"create", "patch"},
@@ -111,6 +111,29 @@ Value must be in format as seen in printers.listPrinterModels response.`,
 		Description:  `The name of the printer to be updated. Format: customers/{customer_id}/chrome/printers/{printer_id}`,
 		Required:     []string{"delete", "get", "patch"},
 	},
+	"cupsConfig": {
+		AvailableFor: []string{"import"},
+		Type:         "string",
+		Description:  `Path to a CUPS printers.conf file. Every <Printer> section is imported as a Chrome printer.`,
+		Required:     []string{"import"},
+	},
+	"ppdDir": {
+		AvailableFor: []string{"import"},
+		Type:         "string",
+		Description: `Path to a directory containing the PPD files of the print queues (usually /etc/cups/ppd).
+If a file named "<queue name>.ppd" exists, the model is read from its *NickName instead of the MakeModel in printers.conf.`,
+	},
+	"driverlessFallback": {
+		AvailableFor: []string{"import"},
+		Type:         "bool",
+		Description: `Use the driverless configuration for printers whose model doesn't match any Chrome printer model.
+By default, these printers are skipped.`,
+	},
+	"dryRun": {
+		AvailableFor: []string{"import"},
+		Type:         "bool",
+		Description:  `Only report how the print queues would be imported.`,
+	},
 	"filter": {
 		AvailableFor: []string{"list", "listModels"},
 		Type:         "string",
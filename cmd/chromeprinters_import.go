@@ -0,0 +1,187 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"github.com/hanneshayashi/gsm/gsmadmin"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// chromePrintersImportCmd represents the import command
+var chromePrintersImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Imports the print queues of a CUPS server as Chrome printers.",
+	Long: `Reads the print queues from a CUPS printers.conf file and creates a Chrome printer for each queue.
+The name of the queue is used as the display name, the Info and Location as the description and the DeviceURI as the URI.
+Queues with a URI that is not supported by Chrome OS (e.g. usb:// or dnssd://) are skipped.
+The model of every queue is matched against the Chrome printer models. Queues whose model doesn't match are reported and skipped, unless --driverlessFallback is used.
+Printers are created in chunks of 50.
+Implements the APIs documented at:
+https://developers.google.com/workspace/admin/chrome-printer/reference/rest/v1/admin.directory.v1.customers.chrome.printers/listPrinterModels
+https://developers.google.com/workspace/admin/chrome-printer/reference/rest/v1/admin.directory.v1.customers.chrome.printers/batchCreatePrinters`,
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := gsmhelpers.FlagsToMap(cmd.Flags())
+		f, err := os.Open(flags["cupsConfig"].GetString())
+		if err != nil {
+			log.Fatalf("Error opening CUPS configuration: %v", err)
+		}
+		queues, err := gsmadmin.ParseCUPSPrinters(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("Error parsing CUPS configuration: %v", err)
+		}
+		if flags["ppdDir"].IsSet() {
+			err = gsmadmin.ApplyPPDModels(queues, flags["ppdDir"].GetString())
+			if err != nil {
+				log.Fatalf("Error reading PPD files: %v", err)
+			}
+		}
+		parent := flags["parent"].GetString()
+		if parent == "" {
+			customerID, err := gsmadmin.GetOwnCustomerID()
+			if err != nil {
+				log.Fatalf("Error determining customer ID: %v", err)
+			}
+			parent = "customers/" + customerID
+		}
+		modelsChan, errChan := gsmadmin.ListPrinterModels(parent, "", "printerModels(makeAndModel),nextPageToken", gsmhelpers.MaxThreads(0))
+		var models []*admin.PrinterModel
+		for m := range modelsChan {
+			models = append(models, m)
+		}
+		if e := <-errChan; e != nil {
+			log.Fatalf("Error listing printer models: %v", e)
+		}
+		matcher := gsmadmin.NewPrinterModelMatcher(models)
+		type queueResult struct {
+			Queue        string `json:"queue"`
+			MakeModel    string `json:"makeModel,omitempty"`
+			MakeAndModel string `json:"makeAndModel,omitempty"`
+			Driverless   bool   `json:"driverless,omitempty"`
+			Status       string `json:"status"`
+			PrinterID    string `json:"printerId,omitempty"`
+			Error        string `json:"error,omitempty"`
+		}
+		result := struct {
+			Queues          []*queueResult `json:"queues"`
+			UnmatchedModels []string       `json:"unmatchedModels,omitempty"`
+		}{}
+		var requests []*admin.CreatePrinterRequest
+		var pending []*queueResult
+		unmatched := make(map[string]bool)
+		driverlessFallback := flags["driverlessFallback"].GetBool()
+		orgUnitID := flags["orgUnitId"].GetString()
+		for _, q := range queues {
+			r := &queueResult{Queue: q.Name, MakeModel: q.MakeModel}
+			result.Queues = append(result.Queues, r)
+			if !gsmadmin.CUPSPrinterURISupported(q.DeviceURI) {
+				r.Status = "skipped"
+				r.Error = "unsupported device URI: " + q.DeviceURI
+				continue
+			}
+			r.MakeAndModel = matcher.Match(q.MakeModel)
+			if r.MakeAndModel == "" {
+				if !unmatched[q.MakeModel] {
+					unmatched[q.MakeModel] = true
+					result.UnmatchedModels = append(result.UnmatchedModels, q.MakeModel)
+				}
+				if !driverlessFallback {
+					r.Status = "skipped"
+					r.Error = "no matching printer model"
+					continue
+				}
+				r.Driverless = true
+			}
+			r.Status = "pending"
+			requests = append(requests, &admin.CreatePrinterRequest{Parent: parent, Printer: gsmadmin.CUPSPrinterToPrinter(q, r.MakeAndModel, orgUnitID)})
+			pending = append(pending, r)
+		}
+		if flags["dryRun"].GetBool() {
+			for _, r := range pending {
+				r.Status = "wouldCreate"
+			}
+			requests = nil
+		}
+		for i := 0; i < len(requests); i += 50 {
+			end := min(i+50, len(requests))
+			chunk := pending[i:end]
+			for _, r := range chunk {
+				r.Status = "failed"
+			}
+			created, err := gsmadmin.BatchCreatePrinters(parent, "", &admin.BatchCreatePrintersRequest{Requests: requests[i:end]})
+			if err != nil {
+				log.Printf("Error creating printers: %v\n", err)
+				for _, r := range chunk {
+					r.Error = err.Error()
+				}
+				continue
+			}
+			byName := make(map[string]*queueResult, len(chunk))
+			for _, r := range chunk {
+				byName[r.Queue] = r
+			}
+			for _, p := range created.Printers {
+				if r, ok := byName[p.DisplayName]; ok {
+					r.Status = "created"
+					r.PrinterID = p.Id
+				}
+			}
+			// Failures that can't be attributed to a printer are added to all printers of the chunk that were not created
+			var unattributed []string
+			for _, fail := range created.Failures {
+				if fail.Printer == nil {
+					unattributed = append(unattributed, fail.ErrorMessage)
+					continue
+				}
+				if r, ok := byName[fail.Printer.DisplayName]; ok {
+					r.Error = fail.ErrorMessage
+				} else {
+					unattributed = append(unattributed, fail.ErrorMessage)
+				}
+			}
+			for _, r := range chunk {
+				if r.Status != "failed" {
+					continue
+				}
+				if r.Error == "" {
+					r.Error = "the printer was not created"
+					if len(unattributed) > 0 {
+						r.Error += ": " + strings.Join(unattributed, "; ")
+					}
+				}
+				log.Printf("Error creating printer %s: %s\n", r.Queue, r.Error)
+			}
+		}
+		err = gsmhelpers.Output(result, "json", compressOutput)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+func init() {
+	gsmhelpers.InitCommand(chromePrintersCmd, chromePrintersImportCmd, chromePrinterFlags)
+}
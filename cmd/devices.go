@@ -50,12 +50,12 @@ var deviceFlags map[string]*gsmhelpers.Flag = map[string]*gsmhelpers.Flag{
 		ExcludeFromAll: true,
 	},
 	"customer": {
-		AvailableFor: []string{"cancelWipe", "create", "delete", "get", "list", "wipe"},
+		AvailableFor: []string{"cancelWipe", "create", "delete", "get", "list", "stale", "wipe"},
 		Type:         "string",
 		Description: `Resource name of the customer.
 If you're using this API for your own organization, use customers/my_customer.
 If you're using this API to manage another organization, use customers/{customer_id}, where customer_id is the customer to whom the device belongs.`,
-		Defaults: map[string]any{"cancelWipe": "customers/my_customer", "create": "customers/my_customer", "delete": "customers/my_customer", "get": "customers/my_customer", "list": "customers/my_customer", "wipe": "customers/my_customer"},
+		Defaults: map[string]any{"cancelWipe": "customers/my_customer", "create": "customers/my_customer", "delete": "customers/my_customer", "get": "customers/my_customer", "list": "customers/my_customer", "stale": "customers/my_customer", "wipe": "customers/my_customer"},
 	},
 	"serialNumber": {
 		AvailableFor:   []string{"create"},
@@ -109,6 +109,39 @@ COMPANY_INVENTORY      This view contains all devices imported by the company ad
 USER_ASSIGNED_DEVICES  This view contains all devices with at least one user registered on the device.
                        Each device in the response contains all device information, except for asset tags.`,
 	},
+	"olderThan": {
+		AvailableFor: []string{"stale"},
+		Type:         "string",
+		Description: `Devices that haven't synced for longer than this are considered stale.
+Accepts days (e.g. "90d"), weeks (e.g. "12w") or any duration understood by Go (e.g. "36h").`,
+		Defaults: map[string]any{"stale": "90d"},
+	},
+	"action": {
+		AvailableFor: []string{"stale"},
+		Type:         "string",
+		Description: `The action to take on stale devices.
+[none|block|wipe|delete]
+none    - Only report the devices.
+block   - Block the device (Admin SDK mobile devices) or all of its device users (Cloud Identity devices).
+wipe    - Remotely wipe the device.
+delete  - Delete the device.`,
+		Defaults: map[string]any{"stale": "none"},
+	},
+	"dryRun": {
+		AvailableFor: []string{"stale"},
+		Type:         "bool",
+		Description:  "Only report the action that would be taken on each device, without executing it.",
+	},
+	"confirm": {
+		AvailableFor: []string{"stale"},
+		Type:         "bool",
+		Description:  "Execute the action without asking for confirmation.",
+	},
+	"includeNeverSynced": {
+		AvailableFor: []string{"stale"},
+		Type:         "bool",
+		Description:  "Also wipe or delete devices that have never synced. By default, these devices are only reported.",
+	},
 	"fields": {
 		AvailableFor: []string{"create", "get", "list"},
 		Type:         "string",
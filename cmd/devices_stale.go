@@ -0,0 +1,386 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hanneshayashi/gsm/gsmadmin"
+	"github.com/hanneshayashi/gsm/gsmci"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+	admin "google.golang.org/api/admin/directory/v1"
+	ci "google.golang.org/api/cloudidentity/v1"
+)
+
+type staleDeviceOwner struct {
+	Email  string `json:"email"`
+	Status string `json:"status"`
+}
+
+type staleDeviceAction struct {
+	Source string `json:"source"`
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type staleDevice struct {
+	MobileDeviceID string               `json:"mobileDeviceResourceId,omitempty"`
+	DeviceName     string               `json:"deviceName,omitempty"`
+	SerialNumber   string               `json:"serialNumber,omitempty"`
+	Model          string               `json:"model,omitempty"`
+	Type           string               `json:"type,omitempty"`
+	LastSync       string               `json:"lastSync,omitempty"`
+	Owners         []*staleDeviceOwner  `json:"owners,omitempty"`
+	Reasons        []string             `json:"reasons"`
+	Actions        []*staleDeviceAction `json:"actions,omitempty"`
+	Skipped        string               `json:"skipped,omitempty"`
+	lastSync       time.Time
+	deviceUsers    []string
+}
+
+// parseDeviceSyncTime parses the last sync timestamp of a device.
+// The Admin SDK reports devices that never synced with the Unix epoch.
+func parseDeviceSyncTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil || t.Unix() <= 0 {
+		return time.Time{}
+	}
+	return t
+}
+
+// mergeDevices merges mobile devices from the Admin SDK and devices from the Cloud Identity API.
+// Devices are merged if they have the same serial number.
+func mergeDevices(mobileDevices []*admin.MobileDevice, ciDevices []*ci.GoogleAppsCloudidentityDevicesV1Device, deviceUsers map[string][]*ci.GoogleAppsCloudidentityDevicesV1DeviceUser) []*staleDevice {
+	var devices []*staleDevice
+	bySerial := make(map[string]*staleDevice)
+	addOwner := func(d *staleDevice, email string) {
+		email = strings.ToLower(email)
+		if email == "" {
+			return
+		}
+		for _, o := range d.Owners {
+			if o.Email == email {
+				return
+			}
+		}
+		d.Owners = append(d.Owners, &staleDeviceOwner{Email: email})
+	}
+	setLastSync := func(d *staleDevice, s string) {
+		if t := parseDeviceSyncTime(s); t.After(d.lastSync) {
+			d.lastSync = t
+			d.LastSync = s
+		}
+	}
+	find := func(serial string) *staleDevice {
+		key := strings.ToLower(serial)
+		if d, ok := bySerial[key]; ok && key != "" {
+			return d
+		}
+		d := &staleDevice{SerialNumber: serial}
+		devices = append(devices, d)
+		if key != "" {
+			bySerial[key] = d
+		}
+		return d
+	}
+	for _, m := range mobileDevices {
+		d := find(m.SerialNumber)
+		d.MobileDeviceID = m.ResourceId
+		d.Model = m.Model
+		d.Type = m.Type
+		setLastSync(d, m.LastSync)
+		for _, e := range m.Email {
+			addOwner(d, e)
+		}
+	}
+	for _, c := range ciDevices {
+		d := find(c.SerialNumber)
+		d.DeviceName = c.Name
+		if d.Model == "" {
+			d.Model = c.Model
+		}
+		if d.Type == "" {
+			d.Type = c.DeviceType
+		}
+		setLastSync(d, c.LastSyncTime)
+		for _, u := range deviceUsers[c.Name] {
+			d.deviceUsers = append(d.deviceUsers, u.Name)
+			addOwner(d, u.UserEmail)
+		}
+	}
+	return devices
+}
+
+// lookupOwnerStatus returns "active", "suspended", "deleted" or "unknown" for every email address
+func lookupOwnerStatus(emails []string, threads int) map[string]string {
+	status := make(map[string]string, len(emails))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	emailChan := make(chan string, threads)
+	for range threads {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range emailChan {
+				s := "active"
+				u, err := gsmadmin.GetUser(e, "suspended", "", "", "")
+				if err != nil {
+					if gsmhelpers.IsNotFound(err) {
+						s = "deleted"
+					} else {
+						log.Println(err)
+						s = "unknown"
+					}
+				} else if u.Suspended {
+					s = "suspended"
+				}
+				mu.Lock()
+				status[e] = s
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, e := range emails {
+		emailChan <- e
+	}
+	close(emailChan)
+	wg.Wait()
+	return status
+}
+
+// staleDeviceReasons returns the reasons why a device is considered stale
+func staleDeviceReasons(d *staleDevice, cutoff time.Time) []string {
+	var reasons []string
+	if d.lastSync.IsZero() {
+		reasons = append(reasons, "neverSynced")
+	} else if d.lastSync.Before(cutoff) {
+		reasons = append(reasons, "inactive")
+	}
+	if len(d.Owners) == 0 {
+		return reasons
+	}
+	var suspended, deleted bool
+	for _, o := range d.Owners {
+		switch o.Status {
+		case "suspended":
+			suspended = true
+		case "deleted":
+			deleted = true
+		default:
+			return reasons
+		}
+	}
+	if suspended {
+		reasons = append(reasons, "ownerSuspended")
+	}
+	if deleted {
+		reasons = append(reasons, "ownerDeleted")
+	}
+	return reasons
+}
+
+// staleDeviceActions returns the actions that have to be taken to execute action on the device
+func staleDeviceActions(d *staleDevice, action string) []*staleDeviceAction {
+	var actions []*staleDeviceAction
+	if d.MobileDeviceID != "" {
+		actions = append(actions, &staleDeviceAction{Source: "mobileDevice", ID: d.MobileDeviceID})
+	}
+	if d.DeviceName != "" {
+		if action == "block" {
+			for _, u := range d.deviceUsers {
+				actions = append(actions, &staleDeviceAction{Source: "deviceUser", ID: u})
+			}
+		} else {
+			actions = append(actions, &staleDeviceAction{Source: "device", ID: d.DeviceName})
+		}
+	}
+	return actions
+}
+
+func executeStaleDeviceAction(a *staleDeviceAction, action, customer string) error {
+	customerID := strings.TrimPrefix(customer, "customers/")
+	var err error
+	switch a.Source {
+	case "mobileDevice":
+		switch action {
+		case "block":
+			_, err = gsmadmin.TakeActionOnMobileDevice(customerID, a.ID, &admin.MobileDeviceAction{Action: "block"})
+		case "wipe":
+			_, err = gsmadmin.TakeActionOnMobileDevice(customerID, a.ID, &admin.MobileDeviceAction{Action: "admin_remote_wipe"})
+		case "delete":
+			_, err = gsmadmin.DeleteMobileDevice(customerID, a.ID)
+		}
+	case "deviceUser":
+		_, err = gsmci.BlockDeviceUser(a.ID, "", &ci.GoogleAppsCloudidentityDevicesV1BlockDeviceUserRequest{Customer: customer})
+	case "device":
+		switch action {
+		case "wipe":
+			_, err = gsmci.WipeDevice(a.ID, "", &ci.GoogleAppsCloudidentityDevicesV1WipeDeviceRequest{Customer: customer})
+		case "delete":
+			_, err = gsmci.DeleteDevice(a.ID, customer)
+		}
+	}
+	return err
+}
+
+// devicesStaleCmd represents the stale command
+var devicesStaleCmd = &cobra.Command{
+	Use:   "stale",
+	Short: "Lists (and optionally blocks, wipes or deletes) stale devices.",
+	Long: `Merges the mobile devices of the Admin SDK with the devices of the Cloud Identity API (by serial number) and lists all devices that
+- have never synced or haven't synced for longer than --olderThan or
+- whose owners are all suspended or deleted.
+If an action is specified, you will be asked for confirmation before it is executed, unless --confirm is set.
+Devices that have never synced are not wiped or deleted, unless --includeNeverSynced is set, because a missing sync time doesn't necessarily mean that the device is unused.
+Use --dryRun to only report the actions that would be taken.
+Note that this command requires manually adding the 'https://www.googleapis.com/auth/cloud-identity.devices' scope to your configuration.
+Implements the APIs documented at:
+https://developers.google.com/workspace/admin/directory/reference/rest/v1/mobiledevices
+https://cloud.google.com/identity/docs/reference/rest/v1/devices
+https://cloud.google.com/identity/docs/reference/rest/v1/devices.deviceUsers`,
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := gsmhelpers.FlagsToMap(cmd.Flags())
+		olderThan, err := gsmhelpers.ParseAge(flags["olderThan"].GetString())
+		if err != nil {
+			log.Fatalf("Error parsing --olderThan: %v", err)
+		}
+		action := flags["action"].GetString()
+		if !gsmhelpers.Contains(action, []string{"none", "block", "wipe", "delete"}) {
+			log.Fatalf("Unknown action %s. Must be one of none, block, wipe or delete", action)
+		}
+		customer := gsmhelpers.EnsurePrefix(flags["customer"].GetString(), "customers/")
+		threads := gsmhelpers.MaxThreads(0)
+		mobileDevicesChan, mobileErr := gsmadmin.ListMobileDevices(strings.TrimPrefix(customer, "customers/"), "", "mobiledevices(resourceId,serialNumber,model,type,lastSync,email),nextPageToken", "FULL", "", "", threads)
+		var mobileDevices []*admin.MobileDevice
+		for m := range mobileDevicesChan {
+			mobileDevices = append(mobileDevices, m)
+		}
+		if e := <-mobileErr; e != nil {
+			log.Fatalf("Error listing mobile devices: %v", e)
+		}
+		ciDevicesChan, ciErr := gsmci.ListDevices(customer, "", "", "", "devices(name,serialNumber,model,deviceType,lastSyncTime),nextPageToken", threads)
+		var ciDevices []*ci.GoogleAppsCloudidentityDevicesV1Device
+		for d := range ciDevicesChan {
+			ciDevices = append(ciDevices, d)
+		}
+		if e := <-ciErr; e != nil {
+			log.Fatalf("Error listing devices: %v", e)
+		}
+		deviceUsersChan, deviceUsersErr := gsmci.ListDeviceUsers("devices/-", customer, "", "", "deviceUsers(name,userEmail),nextPageToken", threads)
+		deviceUsers := make(map[string][]*ci.GoogleAppsCloudidentityDevicesV1DeviceUser)
+		for u := range deviceUsersChan {
+			deviceName, _, _ := strings.Cut(u.Name, "/deviceUsers/")
+			deviceUsers[deviceName] = append(deviceUsers[deviceName], u)
+		}
+		if e := <-deviceUsersErr; e != nil {
+			log.Fatalf("Error listing device users: %v", e)
+		}
+		devices := mergeDevices(mobileDevices, ciDevices, deviceUsers)
+		var emails []string
+		for _, d := range devices {
+			for _, o := range d.Owners {
+				if !gsmhelpers.Contains(o.Email, emails) {
+					emails = append(emails, o.Email)
+				}
+			}
+		}
+		ownerStatus := lookupOwnerStatus(emails, threads)
+		cutoff := time.Now().Add(-olderThan)
+		stale := []*staleDevice{}
+		for _, d := range devices {
+			for _, o := range d.Owners {
+				o.Status = ownerStatus[o.Email]
+			}
+			d.Reasons = staleDeviceReasons(d, cutoff)
+			if len(d.Reasons) > 0 {
+				stale = append(stale, d)
+			}
+		}
+		sort.SliceStable(stale, func(i, j int) bool {
+			return stale[i].lastSync.Before(stale[j].lastSync)
+		})
+		if action != "none" {
+			var actions []*staleDeviceAction
+			includeNeverSynced := flags["includeNeverSynced"].GetBool()
+			affected := 0
+			for _, d := range stale {
+				if (action == "wipe" || action == "delete") && d.lastSync.IsZero() && !includeNeverSynced {
+					d.Skipped = "The device has never synced. Use --includeNeverSynced to " + action + " it."
+					continue
+				}
+				d.Actions = staleDeviceActions(d, action)
+				actions = append(actions, d.Actions...)
+				affected++
+			}
+			dryRun := flags["dryRun"].GetBool()
+			if !dryRun && len(actions) > 0 && !flags["confirm"].GetBool() {
+				fmt.Fprintf(os.Stderr, "About to %s %d stale devices. Type 'yes' to continue: ", action, affected)
+				answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+				if strings.TrimSpace(strings.ToLower(answer)) != "yes" {
+					log.Fatalln("Aborted")
+				}
+			}
+			var wg sync.WaitGroup
+			actionChan := make(chan *staleDeviceAction, threads)
+			for range threads {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for a := range actionChan {
+						if dryRun {
+							a.Status = "wouldExecute"
+							continue
+						}
+						err := executeStaleDeviceAction(a, action, customer)
+						if err != nil {
+							log.Println(err)
+							a.Status = "failed"
+							a.Error = err.Error()
+							continue
+						}
+						a.Status = "executed"
+					}
+				}()
+			}
+			for _, a := range actions {
+				actionChan <- a
+			}
+			close(actionChan)
+			wg.Wait()
+		}
+		err = gsmhelpers.Output(stale, "json", compressOutput)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+func init() {
+	gsmhelpers.InitCommand(devicesCmd, devicesStaleCmd, deviceFlags)
+}
@@ -42,18 +42,87 @@ var domainsCmd = &cobra.Command{
 
 var domainFlags map[string]*gsmhelpers.Flag = map[string]*gsmhelpers.Flag{
 	"customer": {
-		AvailableFor: []string{"delete", "get", "insert", "list"},
+		AvailableFor: []string{"delete", "dnsRecords", "get", "insert", "list"},
 		Type:         "string",
 		Description:  "Immutable ID of the Workspace account.",
-		Defaults:     map[string]any{"delete": "my_customer", "get": "my_customer", "insert": "my_customer", "list": "my_customer"},
+		Defaults:     map[string]any{"delete": "my_customer", "dnsRecords": "my_customer", "get": "my_customer", "insert": "my_customer", "list": "my_customer"},
 	},
 	"domainName": {
-		AvailableFor:   []string{"delete", "get", "insert"},
+		AvailableFor:   []string{"delete", "dnsRecords", "get", "insert"},
 		Type:           "string",
 		Description:    "Name of domain .",
-		Required:       []string{"delete", "get", "insert"},
+		Required:       []string{"delete", "dnsRecords", "get", "insert"},
 		ExcludeFromAll: true,
 	},
+	"format": {
+		AvailableFor: []string{"dnsRecords"},
+		Type:         "string",
+		Description: `Output format of the records.
+[json|bind|terraform|cloudflare]
+json        - JSON, including the state of the domain and the results of --check
+bind        - BIND zone file snippet
+terraform   - Terraform google_dns_record_set resources
+cloudflare  - JSON records in the format of the Cloudflare API`,
+		Defaults: map[string]any{"dnsRecords": "json"},
+	},
+	"ttl": {
+		AvailableFor: []string{"dnsRecords"},
+		Type:         "int64",
+		Description:  "TTL of the records in seconds.",
+		Defaults:     map[string]any{"dnsRecords": int64(3600)},
+	},
+	"verificationToken": {
+		AvailableFor: []string{"dnsRecords"},
+		Type:         "string",
+		Description: `The site verification token shown in the Admin console (with or without the "google-site-verification=" prefix).
+If set, a verification TXT record is added.`,
+	},
+	"dkimKey": {
+		AvailableFor: []string{"dnsRecords"},
+		Type:         "string",
+		Description: `The DKIM public key generated in the Admin console.
+Can either be the full TXT value ("v=DKIM1; k=rsa; p=...") or only the key.
+If set, a DKIM TXT record is added.`,
+	},
+	"dkimSelector": {
+		AvailableFor: []string{"dnsRecords"},
+		Type:         "string",
+		Description:  "The DKIM selector (prefix).",
+		Defaults:     map[string]any{"dnsRecords": "google"},
+	},
+	"dmarcPolicy": {
+		AvailableFor: []string{"dnsRecords"},
+		Type:         "string",
+		Description:  "The DMARC policy [none|quarantine|reject].",
+		Defaults:     map[string]any{"dnsRecords": "none"},
+	},
+	"dmarcReport": {
+		AvailableFor: []string{"dnsRecords"},
+		Type:         "string",
+		Description:  "Email address that receives aggregate DMARC reports.",
+	},
+	"cnames": {
+		AvailableFor: []string{"dnsRecords"},
+		Type:         "stringSlice",
+		Description:  "Host names (e.g. mail, calendar, drive) that should point to ghs.googlehosted.com for custom service URLs.",
+	},
+	"managedZone": {
+		AvailableFor: []string{"dnsRecords"},
+		Type:         "string",
+		Description: `Name of the Cloud DNS managed zone for the Terraform output.
+Defaults to the domain name with dots replaced by dashes.`,
+	},
+	"check": {
+		AvailableFor: []string{"dnsRecords"},
+		Type:         "bool",
+		Description:  "Query the current DNS answers and report whether the records are in place.",
+	},
+	"resolver": {
+		AvailableFor: []string{"dnsRecords"},
+		Type:         "string",
+		Description: `DNS server (host or host:port) to use for --check, e.g. 8.8.8.8.
+Defaults to the system resolver.`,
+	},
 	"fields": {
 		AvailableFor: []string{"get", "insert", "list"},
 		Type:         "string",
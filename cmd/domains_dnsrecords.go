@@ -0,0 +1,129 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/hanneshayashi/gsm/gsmadmin"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+)
+
+// domainsDNSRecordsCmd represents the dnsRecords command
+var domainsDNSRecordsCmd = &cobra.Command{
+	Use:   "dnsRecords",
+	Short: "Outputs the DNS records required to use a domain or domain alias with Google Workspace.",
+	Long: `Generates the MX, SPF and DMARC records and, optionally, the verification, DKIM and CNAME records for a domain or domain alias.
+The domain must be a domain or domain alias of the customer.
+The verification token and DKIM key are not available through the API and have to be copied from the Admin console.
+With --check, the current DNS answers are compared with the generated records.
+Implements the APIs documented at:
+https://developers.google.com/workspace/admin/directory/reference/rest/v1/domains/get
+https://developers.google.com/workspace/admin/directory/reference/rest/v1/domainAliases/list`,
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := gsmhelpers.FlagsToMap(cmd.Flags())
+		customer := flags["customer"].GetString()
+		domainName := strings.ToLower(flags["domainName"].GetString())
+		result := struct {
+			DomainName       string                     `json:"domainName"`
+			Type             string                     `json:"type"`
+			ParentDomainName string                     `json:"parentDomainName,omitempty"`
+			Verified         bool                       `json:"verified"`
+			Records          []*gsmadmin.DNSRecord      `json:"records"`
+			Checks           []*gsmadmin.DNSRecordCheck `json:"checks,omitempty"`
+		}{DomainName: domainName}
+		domain, err := gsmadmin.GetDomain(customer, domainName, "domainName,isPrimary,verified")
+		if err == nil {
+			result.Type = "secondary"
+			if domain.IsPrimary {
+				result.Type = "primary"
+			}
+			result.Verified = domain.Verified
+		} else if gsmhelpers.IsNotFound(err) {
+			aliases, err := gsmadmin.ListDomainAliases(customer, "", "domainAliases(domainAliasName,parentDomainName,verified)")
+			if err != nil {
+				log.Fatalf("Error listing domain aliases: %v", err)
+			}
+			for _, a := range aliases {
+				if strings.EqualFold(a.DomainAliasName, domainName) {
+					result.Type = "alias"
+					result.ParentDomainName = a.ParentDomainName
+					result.Verified = a.Verified
+					break
+				}
+			}
+			if result.Type == "" {
+				log.Fatalf("%s is neither a domain nor a domain alias of customer %s", domainName, customer)
+			}
+		} else {
+			log.Fatalf("Error getting domain: %v", err)
+		}
+		result.Records = gsmadmin.GenerateDNSRecords(domainName, &gsmadmin.DNSRecordOptions{
+			TTL:               flags["ttl"].GetInt64(),
+			VerificationToken: flags["verificationToken"].GetString(),
+			DKIMSelector:      flags["dkimSelector"].GetString(),
+			DKIMKey:           flags["dkimKey"].GetString(),
+			DMARCPolicy:       flags["dmarcPolicy"].GetString(),
+			DMARCReport:       flags["dmarcReport"].GetString(),
+			CNAMEs:            flags["cnames"].GetStringSlice(),
+		})
+		if !result.Verified && flags["verificationToken"].GetString() == "" {
+			log.Printf("Domain %s is not verified. Use --verificationToken to include the verification record.\n", domainName)
+		}
+		format := flags["format"].GetString()
+		if flags["check"].GetBool() {
+			result.Checks = gsmadmin.CheckDNSRecords(context.Background(), gsmadmin.NewDNSResolver(flags["resolver"].GetString()), result.Records)
+			if format != "json" {
+				for _, c := range result.Checks {
+					if c.Status != "ok" {
+						log.Printf("%s %s (%s): %s %s\n", c.FQDN, c.Type, c.Purpose, c.Status, strings.Join(c.Actual, ", "))
+					}
+				}
+			}
+		}
+		switch format {
+		case "json":
+			err = gsmhelpers.Output(result, "json", compressOutput)
+		case "bind":
+			err = gsmadmin.WriteDNSRecordsBIND(os.Stdout, domainName, result.Records)
+		case "terraform":
+			managedZone := flags["managedZone"].GetString()
+			if managedZone == "" {
+				managedZone = strings.ReplaceAll(domainName, ".", "-")
+			}
+			err = gsmadmin.WriteDNSRecordsTerraform(os.Stdout, managedZone, result.Records)
+		case "cloudflare":
+			err = gsmhelpers.Output(gsmadmin.CloudflareDNSRecords(result.Records), "json", compressOutput)
+		default:
+			log.Fatalf("Unknown format: %s", format)
+		}
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+func init() {
+	gsmhelpers.InitCommand(domainsCmd, domainsDNSRecordsCmd, domainFlags)
+}
@@ -111,6 +111,48 @@ For example, "from:someuser@example.com rfc822msgid:<somemsgid@example.com> is:u
 		AvailableFor: []string{"create", "update"},
 		Type:         "stringSlice",
 		Description: `Path to a file that should be attached to the message.
+Can be used multiple times.`,
+	},
+	"from": {
+		AvailableFor: []string{"create", "update"},
+		Type:         "string",
+		Description: `Sender of the (draft) message.
+Must be a valid sendAs address.
+If this is not set, your primary sendAs address will be used automatically.`,
+	},
+	"textBody": {
+		AvailableFor: []string{"create", "update"},
+		Type:         "string",
+		Description: `Plain text version of the body.
+Only used with --html. The message will contain both versions (multipart/alternative).`,
+	},
+	"replyTo": {
+		AvailableFor: []string{"create", "update"},
+		Type:         "string",
+		Description:  "Reply-To address(es) of the (draft) message",
+	},
+	"inReplyTo": {
+		AvailableFor: []string{"create", "update"},
+		Type:         "string",
+		Description:  "Message-ID of the message this (draft) message replies to, e.g. \"<abc@mail.gmail.com>\"",
+	},
+	"references": {
+		AvailableFor: []string{"create", "update"},
+		Type:         "string",
+		Description:  "Message-IDs of the previous messages in the thread, separated by spaces",
+	},
+	"header": {
+		AvailableFor: []string{"create", "update"},
+		Type:         "stringSlice",
+		Description: `Additional header in the form "Name: value".
+Can be used multiple times.`,
+	},
+	"inlineImage": {
+		AvailableFor: []string{"create", "update"},
+		Type:         "stringSlice",
+		Description: `Path to an image that should be embedded in the HTML body.
+The image can be referenced by its file name, e.g. <img src="cid:logo.png">.
+If the message has no HTML body, the image is added as a regular attachment.
 Can be used multiple times.`,
 	},
 	"fields": {
@@ -43,10 +43,21 @@ var filtersCmd = &cobra.Command{
 
 var filterFlags map[string]*gsmhelpers.Flag = map[string]*gsmhelpers.Flag{
 	"userId": {
-		AvailableFor: []string{"create", "delete", "get", "list"},
+		AvailableFor: []string{"create", "delete", "export", "get", "import", "list"},
 		Type:         "string",
 		Description:  "The user's email address. The special value \"me\" can be used to indicate the authenticated user.",
-		Defaults:     map[string]any{"create": "me", "delete": "me", "get": "me", "list": "me"},
+		Defaults:     map[string]any{"create": "me", "delete": "me", "export": "me", "get": "me", "import": "me", "list": "me"},
+	},
+	"file": {
+		AvailableFor: []string{"import"},
+		Type:         "string",
+		Description:  `Path to a mailFilters.xml file, as exported by the Gmail web interface (Settings > Filters and Blocked Addresses > Export).`,
+		Required:     []string{"import"},
+	},
+	"dryRun": {
+		AvailableFor: []string{"import"},
+		Type:         "bool",
+		Description:  `Only check which filters would be created.`,
 	},
 	"addLabelIds": {
 		AvailableFor: []string{"create"},
@@ -0,0 +1,71 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"github.com/hanneshayashi/gsm/gsmgmail"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+)
+
+// filtersExportCmd represents the export command
+var filtersExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Exports a user's filters in the mailFilters.xml format",
+	Long: `Writes the filters of the user to stdout in the mailFilters.xml format that is used by the Gmail web interface.
+Label IDs are exported as label names. Filters that apply more than one label are exported as one filter per label.
+Example: gsm filters export --userId user@example.org > mailFilters.xml
+
+Implements the APIs documented at:
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.settings.filters/list
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.labels/list`,
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := gsmhelpers.FlagsToMap(cmd.Flags())
+		userID := flags["userId"].GetString()
+		filters, err := gsmgmail.ListFilters(userID, "")
+		if err != nil {
+			log.Fatalf("Error listing filters: %v", err)
+		}
+		labels, err := gsmgmail.ListLabels(userID, "labels(id,name)")
+		if err != nil {
+			log.Fatalf("Error listing labels: %v", err)
+		}
+		labelNames := make(map[string]string, len(labels))
+		for _, l := range labels {
+			labelNames[l.Id] = l.Name
+		}
+		var author string
+		if strings.Contains(userID, "@") {
+			author = userID
+		}
+		err = gsmgmail.WriteMailFilters(os.Stdout, filters, labelNames, author)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+func init() {
+	gsmhelpers.InitCommand(filtersCmd, filtersExportCmd, filterFlags)
+}
@@ -0,0 +1,141 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/hanneshayashi/gsm/gsmgmail"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/api/gmail/v1"
+)
+
+// filtersImportCmd represents the import command
+var filtersImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Imports filters from a mailFilters.xml file",
+	Long: `Creates the filters of a mailFilters.xml file (as exported by the Gmail web interface or "gsm filters export") for the user.
+Label names are resolved to label IDs. Missing labels (including nested labels) are created.
+Filters in the file with identical criteria are merged into a single filter.
+Filters with the same criteria as an existing filter of the user are skipped.
+Properties that can't be set via the API (e.g. cannedResponse) are ignored and reported as "unsupported".
+Use "gsm filters import batch" to import the file for many users.
+Example: gsm filters import --userId user@example.org --file mailFilters.xml
+
+Implements the APIs documented at:
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.settings.filters/create
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.labels/create`,
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := gsmhelpers.FlagsToMap(cmd.Flags())
+		results, err := importMailFilters(flags["userId"].GetString(), flags["file"].GetString(), flags["dryRun"].GetBool())
+		if err != nil {
+			log.Fatalln(err)
+		}
+		err = gsmhelpers.Output(results, "json", compressOutput)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+type filterImportResult struct {
+	UserID      string                `json:"userId"`
+	Criteria    *gmail.FilterCriteria `json:"criteria,omitempty"`
+	Labels      []string              `json:"labels,omitempty"`
+	Unsupported []string              `json:"unsupported,omitempty"`
+	Status      string                `json:"status"`
+	ID          string                `json:"id,omitempty"`
+	Error       string                `json:"error,omitempty"`
+}
+
+// importMailFilters creates the filters of a mailFilters.xml file for a user
+func importMailFilters(userID, path string, dryRun bool) ([]*filterImportResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %v", path, err)
+	}
+	filters, err := gsmgmail.ParseMailFilters(f)
+	gsmhelpers.CloseLog(f, "mailFilters")
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	filters = gsmgmail.MergeMailFilters(filters)
+	existing, err := gsmgmail.ListFilters(userID, "filter(id,criteria)")
+	if err != nil {
+		return nil, fmt.Errorf("error listing filters of %s: %v", userID, err)
+	}
+	labels, err := gsmgmail.NewLabelCache(userID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing labels of %s: %v", userID, err)
+	}
+	results := make([]*filterImportResult, 0, len(filters))
+	for _, filter := range filters {
+		r := &filterImportResult{UserID: userID, Criteria: filter.Criteria, Labels: filter.Labels, Unsupported: filter.Unsupported}
+		results = append(results, r)
+		exists := false
+		for _, e := range existing {
+			if gsmgmail.FilterCriteriaEqual(e.Criteria, filter.Criteria) {
+				exists = true
+				r.ID = e.Id
+				break
+			}
+		}
+		if exists {
+			r.Status = "exists"
+			continue
+		}
+		if dryRun {
+			r.Status = "wouldCreate"
+			continue
+		}
+		action := *filter.Action
+		action.AddLabelIds = append([]string{}, filter.Action.AddLabelIds...)
+		for _, name := range filter.Labels {
+			id, err := labels.ID(name, true)
+			if err != nil {
+				r.Error = fmt.Sprintf("error creating label %s: %v", name, err)
+				break
+			}
+			if id != "" && !gsmhelpers.Contains(id, action.AddLabelIds) {
+				action.AddLabelIds = append(action.AddLabelIds, id)
+			}
+		}
+		if r.Error == "" {
+			result, err := gsmgmail.CreateFilter(userID, "id", &gmail.Filter{Criteria: filter.Criteria, Action: &action})
+			if err != nil {
+				r.Error = err.Error()
+			} else {
+				r.ID = result.Id
+				r.Status = "created"
+				continue
+			}
+		}
+		log.Printf("Error creating filter for %s: %s", userID, r.Error)
+		r.Status = "failed"
+	}
+	return results, nil
+}
+
+func init() {
+	gsmhelpers.InitCommand(filtersCmd, filtersImportCmd, filterFlags)
+}
@@ -44,10 +44,10 @@ var gmailSettingsCmd = &cobra.Command{
 
 var gmailSettingFlags map[string]*gsmhelpers.Flag = map[string]*gsmhelpers.Flag{
 	"userId": {
-		AvailableFor: []string{"getAutoForwarding", "getImap", "getLanguage", "getPop", "getVacation", "updateAutoForwarding", "updateImap", "updateLanguage", "updatePop", "updateVacation"},
+		AvailableFor: []string{"audit", "getAutoForwarding", "getImap", "getLanguage", "getPop", "getVacation", "updateAutoForwarding", "updateImap", "updateLanguage", "updatePop", "updateVacation"},
 		Type:         "string",
 		Description:  "The user's email address. The special value \"me\" can be used to indicate the authenticated user.",
-		Defaults:     map[string]any{"getAutoForwarding": "me", "getImap": "me", "getLanguage": "me", "getPop": "me", "getVacation": "me", "updateAutoForwarding": "me", "updateImap": "me", "updateLanguage": "me", "updatePop": "me", "updateVacation": "me"},
+		Defaults:     map[string]any{"audit": "me", "getAutoForwarding": "me", "getImap": "me", "getLanguage": "me", "getPop": "me", "getVacation": "me", "updateAutoForwarding": "me", "updateImap": "me", "updateLanguage": "me", "updatePop": "me", "updateVacation": "me"},
 	},
 	"enabled": {
 		AvailableFor: []string{"updateAutoForwarding", "updateImap"},
@@ -157,6 +157,20 @@ If both startTime and endTime are specified, startTime must precede endTime.`,
 When this is specified, Gmail will automatically reply only to messages that it receives before the end time.
 If both startTime and endTime are specified, startTime must precede endTime.`,
 	},
+	"customer": {
+		AvailableFor: []string{"audit"},
+		Type:         "string",
+		Description: `The unique ID for the customer's Workspace account.
+The customer's domains and domain aliases are considered internal. Forwarding to any other domain is flagged as external.`,
+		Defaults:  map[string]any{"audit": "my_customer"},
+		Recursive: []string{"audit"},
+	},
+	"findingsOnly": {
+		AvailableFor: []string{"audit"},
+		Type:         "bool",
+		Description:  `Only include users with findings (delegates, forwarding or external send-as addresses) in the report.`,
+		Recursive:    []string{"audit"},
+	},
 	"fields": {
 		AvailableFor: []string{"getAutoForwarding", "getImap", "getLanguage", "getPop", "getVacation", "updateAutoForwarding", "updateImap", "updateLanguage", "updatePop", "updateVacation"},
 		Type:         "string",
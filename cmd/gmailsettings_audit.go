@@ -0,0 +1,298 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hanneshayashi/gsm/gsmadmin"
+	"github.com/hanneshayashi/gsm/gsmgmail"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/api/gmail/v1"
+)
+
+// gmailSettingsAuditCmd represents the audit command
+var gmailSettingsAuditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Reports delegates, forwarding, POP / IMAP access and send-as addresses of a user.",
+	Long: `Collects the delegates, auto-forwarding settings, forwarding addresses, filters that forward messages,
+POP / IMAP settings and send-as addresses of a user into one report.
+Forwarding to (and send-as addresses in) domains that are not domains or domain aliases of the customer are flagged as external.
+Use "gsm gmailSettings audit recursive" to audit all users in organizational units or groups.
+
+Implements the APIs documented at:
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.settings.delegates/list
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.settings/getAutoForwarding
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.settings.forwardingAddresses/list
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.settings.filters/list
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.settings/getPop
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.settings/getImap
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.settings.sendAs/list`,
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := gsmhelpers.FlagsToMap(cmd.Flags())
+		domains, err := tenantDomains(flags["customer"].GetString())
+		if err != nil {
+			log.Fatalf("Error listing domains: %v", err)
+		}
+		result := auditMailbox(flags["userId"].GetString(), domains)
+		if flags["findingsOnly"].GetBool() && len(result.Findings) == 0 {
+			return
+		}
+		err = gsmhelpers.Output(result, "json", compressOutput)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+type mailboxAuditFinding struct {
+	Type    string `json:"type"`
+	Address string `json:"address"`
+}
+
+type mailboxAuditForwardingAddress struct {
+	ForwardingEmail    string `json:"forwardingEmail"`
+	VerificationStatus string `json:"verificationStatus,omitempty"`
+	External           bool   `json:"external"`
+}
+
+type mailboxAuditFilter struct {
+	ID       string                `json:"id"`
+	Forward  string                `json:"forward"`
+	Criteria *gmail.FilterCriteria `json:"criteria,omitempty"`
+	External bool                  `json:"external"`
+}
+
+type mailboxAuditSendAs struct {
+	SendAsEmail        string `json:"sendAsEmail"`
+	IsPrimary          bool   `json:"isPrimary,omitempty"`
+	TreatAsAlias       bool   `json:"treatAsAlias,omitempty"`
+	VerificationStatus string `json:"verificationStatus,omitempty"`
+	SMTPHost           string `json:"smtpHost,omitempty"`
+	External           bool   `json:"external"`
+}
+
+type mailboxAuditResult struct {
+	UserID              string                           `json:"userId"`
+	Delegates           []*gmail.Delegate                `json:"delegates,omitempty"`
+	AutoForwarding      *gmail.AutoForwarding            `json:"autoForwarding,omitempty"`
+	ForwardingAddresses []*mailboxAuditForwardingAddress `json:"forwardingAddresses,omitempty"`
+	ForwardingFilters   []*mailboxAuditFilter            `json:"forwardingFilters,omitempty"`
+	PopAccessWindow     string                           `json:"popAccessWindow,omitempty"`
+	ImapEnabled         bool                             `json:"imapEnabled"`
+	SendAs              []*mailboxAuditSendAs            `json:"sendAs,omitempty"`
+	Findings            []*mailboxAuditFinding           `json:"findings,omitempty"`
+	Errors              []string                         `json:"errors,omitempty"`
+}
+
+// mailboxAuditSummary consolidates the results of the audits of multiple mailboxes
+type mailboxAuditSummary struct {
+	Users                       int            `json:"users"`
+	UsersWithFindings           int            `json:"usersWithFindings"`
+	UsersWithExternalForwarding int            `json:"usersWithExternalForwarding"`
+	UsersWithErrors             int            `json:"usersWithErrors"`
+	Delegates                   int            `json:"delegates"`
+	ExternalDelegates           int            `json:"externalDelegates"`
+	AutoForwarding              int            `json:"autoForwarding"`
+	ExternalAutoForwarding      int            `json:"externalAutoForwarding"`
+	ExternalForwardingAddresses int            `json:"externalForwardingAddresses"`
+	FilterForwarding            int            `json:"filterForwarding"`
+	ExternalFilterForwarding    int            `json:"externalFilterForwarding"`
+	ExternalSendAs              int            `json:"externalSendAs"`
+	ExternalForwardingDomains   map[string]int `json:"externalForwardingDomains,omitempty"`
+}
+
+// add adds the result of a mailbox audit to the summary
+func (s *mailboxAuditSummary) add(r *mailboxAuditResult) {
+	s.Users++
+	if len(r.Findings) > 0 {
+		s.UsersWithFindings++
+	}
+	if len(r.Errors) > 0 {
+		s.UsersWithErrors++
+	}
+	externalForwarding := false
+	for _, f := range r.Findings {
+		switch f.Type {
+		case "delegate":
+			s.Delegates++
+		case "externalDelegate":
+			s.Delegates++
+			s.ExternalDelegates++
+		case "autoForwarding":
+			s.AutoForwarding++
+		case "externalAutoForwarding":
+			s.AutoForwarding++
+			s.ExternalAutoForwarding++
+		case "externalForwardingAddress":
+			s.ExternalForwardingAddresses++
+		case "filterForwarding":
+			s.FilterForwarding++
+		case "externalFilterForwarding":
+			s.FilterForwarding++
+			s.ExternalFilterForwarding++
+		case "externalSendAs":
+			s.ExternalSendAs++
+		}
+		switch f.Type {
+		case "externalAutoForwarding", "externalForwardingAddress", "externalFilterForwarding":
+			externalForwarding = true
+			if s.ExternalForwardingDomains == nil {
+				s.ExternalForwardingDomains = make(map[string]int)
+			}
+			s.ExternalForwardingDomains[gsmgmail.AddressDomain(f.Address)]++
+		}
+	}
+	if externalForwarding {
+		s.UsersWithExternalForwarding++
+	}
+}
+
+// tenantDomains returns the (lower case) domains and domain aliases of the customer
+func tenantDomains(customerID string) (map[string]bool, error) {
+	domains := make(map[string]bool)
+	d, err := gsmadmin.ListDomains(customerID, "domains(domainName)")
+	if err != nil {
+		return nil, err
+	}
+	for i := range d {
+		domains[strings.ToLower(d[i].DomainName)] = true
+	}
+	aliases, err := gsmadmin.ListDomainAliases(customerID, "", "domainAliases(domainAliasName)")
+	if err != nil {
+		return nil, err
+	}
+	for i := range aliases {
+		domains[strings.ToLower(aliases[i].DomainAliasName)] = true
+	}
+	return domains, nil
+}
+
+// auditMailbox collects the delegation and forwarding settings of a user.
+// Errors are added to the result, so that one failing API call doesn't prevent the rest of the report.
+func auditMailbox(userID string, domains map[string]bool) *mailboxAuditResult {
+	r := &mailboxAuditResult{UserID: userID}
+	fail := func(what string, err error) {
+		log.Printf("Error getting %s for user %s: %v", what, userID, err)
+		r.Errors = append(r.Errors, fmt.Sprintf("%s: %v", what, err))
+	}
+	addFinding := func(findingType, address string) {
+		r.Findings = append(r.Findings, &mailboxAuditFinding{Type: findingType, Address: address})
+	}
+	delegates, err := gsmgmail.ListDelegates(userID, "*")
+	if err != nil {
+		fail("delegates", err)
+	}
+	r.Delegates = delegates
+	for i := range delegates {
+		findingType := "delegate"
+		if gsmgmail.IsExternalAddress(delegates[i].DelegateEmail, domains) {
+			findingType = "externalDelegate"
+		}
+		addFinding(findingType, delegates[i].DelegateEmail)
+	}
+	autoForwarding, err := gsmgmail.GetAutoForwardingSettings(userID, "*")
+	if err != nil {
+		fail("auto-forwarding settings", err)
+	} else if autoForwarding.Enabled {
+		r.AutoForwarding = autoForwarding
+		findingType := "autoForwarding"
+		if gsmgmail.IsExternalAddress(autoForwarding.EmailAddress, domains) {
+			findingType = "externalAutoForwarding"
+		}
+		addFinding(findingType, autoForwarding.EmailAddress)
+	}
+	forwardingAddresses, err := gsmgmail.ListForwardingAddresses(userID, "*")
+	if err != nil {
+		fail("forwarding addresses", err)
+	}
+	for i := range forwardingAddresses {
+		a := &mailboxAuditForwardingAddress{
+			ForwardingEmail:    forwardingAddresses[i].ForwardingEmail,
+			VerificationStatus: forwardingAddresses[i].VerificationStatus,
+			External:           gsmgmail.IsExternalAddress(forwardingAddresses[i].ForwardingEmail, domains),
+		}
+		r.ForwardingAddresses = append(r.ForwardingAddresses, a)
+		if a.External {
+			addFinding("externalForwardingAddress", a.ForwardingEmail)
+		}
+	}
+	filters, err := gsmgmail.ListFilters(userID, "*")
+	if err != nil {
+		fail("filters", err)
+	}
+	for i := range filters {
+		if filters[i].Action == nil || filters[i].Action.Forward == "" {
+			continue
+		}
+		f := &mailboxAuditFilter{
+			ID:       filters[i].Id,
+			Forward:  filters[i].Action.Forward,
+			Criteria: filters[i].Criteria,
+			External: gsmgmail.IsExternalAddress(filters[i].Action.Forward, domains),
+		}
+		r.ForwardingFilters = append(r.ForwardingFilters, f)
+		findingType := "filterForwarding"
+		if f.External {
+			findingType = "externalFilterForwarding"
+		}
+		addFinding(findingType, f.Forward)
+	}
+	pop, err := gsmgmail.GetPOPSettings(userID, "accessWindow")
+	if err != nil {
+		fail("POP settings", err)
+	} else {
+		r.PopAccessWindow = pop.AccessWindow
+	}
+	imap, err := gsmgmail.GetIMAPSettings(userID, "enabled")
+	if err != nil {
+		fail("IMAP settings", err)
+	} else {
+		r.ImapEnabled = imap.Enabled
+	}
+	sendAs, err := gsmgmail.ListSendAs(userID, "*")
+	if err != nil {
+		fail("send-as addresses", err)
+	}
+	for i := range sendAs {
+		s := &mailboxAuditSendAs{
+			SendAsEmail:        sendAs[i].SendAsEmail,
+			IsPrimary:          sendAs[i].IsPrimary,
+			TreatAsAlias:       sendAs[i].TreatAsAlias,
+			VerificationStatus: sendAs[i].VerificationStatus,
+			External:           gsmgmail.IsExternalAddress(sendAs[i].SendAsEmail, domains),
+		}
+		if sendAs[i].SmtpMsa != nil {
+			s.SMTPHost = sendAs[i].SmtpMsa.Host
+		}
+		r.SendAs = append(r.SendAs, s)
+		if s.External {
+			addFinding("externalSendAs", s.SendAsEmail)
+		}
+	}
+	return r
+}
+
+func init() {
+	gsmhelpers.InitCommand(gmailSettingsCmd, gmailSettingsAuditCmd, gmailSettingFlags)
+}
@@ -0,0 +1,114 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"log"
+	"sync"
+
+	"github.com/hanneshayashi/gsm/gsmadmin"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+)
+
+// gmailSettingsAuditRecursiveCmd represents the recursive command
+var gmailSettingsAuditRecursiveCmd = &cobra.Command{
+	Use:   "recursive",
+	Short: "Reports delegates, forwarding, POP / IMAP access and send-as addresses of users by referencing one or more organizational units and/or groups.",
+	Long: `The output contains a summary with the number of delegates, forwarding settings and external addresses across all audited users.
+When using --streamOutput, the summary is written as the last line.
+Example: gsm gmailSettings audit recursive --orgUnit /Sales --findingsOnly
+Implements the APIs documented at:
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.settings.delegates/list
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.settings/getAutoForwarding
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.settings.forwardingAddresses/list
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.settings.filters/list
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.settings/getPop
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.settings/getImap
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.settings.sendAs/list`,
+	Annotations: map[string]string{
+		"crescendoAttachToParent": "true",
+	},
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := gsmhelpers.FlagsToMap(cmd.Flags())
+		domains, err := tenantDomains(flags["customer"].GetString())
+		if err != nil {
+			log.Fatalf("Error listing domains: %v", err)
+		}
+		findingsOnly := flags["findingsOnly"].GetBool()
+		threads := gsmhelpers.MaxThreads(flags["batchThreads"].GetInt())
+		results := make(chan *mailboxAuditResult, threads)
+		summary := &mailboxAuditSummary{}
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		userKeysUnique, _ := gsmadmin.GetUniqueUsersChannelRecursive(flags["orgUnit"].GetStringSlice(), flags["groupEmail"].GetStringSlice(), threads)
+		go func() {
+			for i := 0; i < threads; i++ {
+				wg.Add(1)
+				go func() {
+					for uk := range userKeysUnique {
+						r := auditMailbox(uk, domains)
+						mu.Lock()
+						summary.add(r)
+						mu.Unlock()
+						if findingsOnly && len(r.Findings) == 0 {
+							continue
+						}
+						results <- r
+					}
+					wg.Done()
+				}()
+			}
+			wg.Wait()
+			close(results)
+		}()
+		if streamOutput {
+			enc := gsmhelpers.GetJSONEncoder(false)
+			for r := range results {
+				err := enc.Encode(r)
+				if err != nil {
+					log.Println(err)
+				}
+			}
+			err := enc.Encode(map[string]*mailboxAuditSummary{"summary": summary})
+			if err != nil {
+				log.Println(err)
+			}
+		} else {
+			type resultStruct struct {
+				Summary *mailboxAuditSummary  `json:"summary"`
+				Users   []*mailboxAuditResult `json:"users"`
+			}
+			final := resultStruct{Users: []*mailboxAuditResult{}}
+			for r := range results {
+				final.Users = append(final.Users, r)
+			}
+			final.Summary = summary
+			err := gsmhelpers.Output(final, "json", compressOutput)
+			if err != nil {
+				log.Fatalln(err)
+			}
+		}
+	},
+}
+
+func init() {
+	gsmhelpers.InitRecursiveCommand(gmailSettingsAuditCmd, gmailSettingsAuditRecursiveCmd, gmailSettingFlags, recursiveUserFlags)
+}
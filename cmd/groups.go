@@ -42,11 +42,11 @@ var groupsCmd = &cobra.Command{
 
 var groupFlags map[string]*gsmhelpers.Flag = map[string]*gsmhelpers.Flag{
 	"groupKey": {
-		AvailableFor: []string{"delete", "get", "patch"},
+		AvailableFor: []string{"delete", "get", "patch", "tree"},
 		Type:         "string",
 		Description: `Identifies the group in the API request.
 The value can be the group's email address, group alias, or the unique group ID.`,
-		Required:       []string{"delete", "get", "patch"},
+		Required:       []string{"delete", "get", "patch", "tree"},
 		ExcludeFromAll: true,
 	},
 	"email": {
@@ -111,6 +111,23 @@ DESCENDING  - Descending order.`,
 		Type:         "string",
 		Description: `Email or immutable ID of the user if only those groups are to be listed, the given user is a member of.
 If it's an ID, it should match with the ID of the user object.`,
+	},
+	"format": {
+		AvailableFor: []string{"tree"},
+		Type:         "string",
+		Description: `The output format of the tree.
+Acceptable values are:
+json     - A JSON tree including the nesting paths of every user
+text     - Indented text
+dot      - A Graphviz DOT digraph
+mermaid  - A Mermaid flowchart`,
+		Defaults: map[string]any{"tree": "json"},
+	},
+	"memberKey": {
+		AvailableFor: []string{"tree"},
+		Type:         "string",
+		Description: `Only show the paths through which the specified member (email address) is a member of the group.
+Use this to answer questions like "Why is Alice a member of this group?".`,
 	},
 	"fields": {
 		AvailableFor: []string{"get", "insert", "list", "patch"},
@@ -0,0 +1,69 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"log"
+	"os"
+
+	"github.com/hanneshayashi/gsm/gsmadmin"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+)
+
+// groupsTreeCmd represents the tree command
+var groupsTreeCmd = &cobra.Command{
+	Use:   "tree",
+	Short: "Shows the nested membership tree of a group.",
+	Long: `Recursively lists the members of a group and all of its subgroups.
+Unlike "gsm members list --includeDerivedMembership", the output shows through which subgroups a user is a member of the group.
+Groups that are (indirectly) members of themselves are reported as cycles and are not expanded again.
+The output also contains the maximum nesting depth of the group.
+Implements the API documented at https://developers.google.com/workspace/admin/directory/reference/rest/v1/members/list`,
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := gsmhelpers.FlagsToMap(cmd.Flags())
+		tree, err := gsmadmin.BuildGroupTree(flags["groupKey"].GetString(), gsmhelpers.MaxThreads(0))
+		if err != nil {
+			log.Fatalf("Error building group tree: %v", err)
+		}
+		if flags["memberKey"].IsSet() {
+			tree = tree.Filter(flags["memberKey"].GetString())
+		}
+		switch flags["format"].GetString() {
+		case "json":
+			err = gsmhelpers.Output(tree, "json", compressOutput)
+		case "text":
+			err = tree.WriteText(os.Stdout)
+		case "dot":
+			err = tree.WriteDOT(os.Stdout)
+		case "mermaid":
+			err = tree.WriteMermaid(os.Stdout)
+		default:
+			log.Fatalf("Unknown format: %s", flags["format"].GetString())
+		}
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+func init() {
+	gsmhelpers.InitCommand(groupsCmd, groupsTreeCmd, groupFlags)
+}
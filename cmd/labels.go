@@ -18,8 +18,10 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 package cmd
 
 import (
+	"fmt"
 	"log"
 
+	"github.com/hanneshayashi/gsm/gsmgmail"
 	"github.com/hanneshayashi/gsm/gsmhelpers"
 
 	"github.com/spf13/cobra"
@@ -42,10 +44,10 @@ var labelsCmd = &cobra.Command{
 
 var labelFlags map[string]*gsmhelpers.Flag = map[string]*gsmhelpers.Flag{
 	"userId": {
-		AvailableFor: []string{"create", "delete", "get", "list", "patch"},
+		AvailableFor: []string{"create", "delete", "get", "list", "merge", "patch", "rename", "tree"},
 		Type:         "string",
 		Description:  "The user's email address. The special value \"me\" can be used to indicate the authenticated user.",
-		Defaults:     map[string]any{"create": "me", "delete": "me", "get": "me", "list": "me", "patch": "me"},
+		Defaults:     map[string]any{"create": "me", "delete": "me", "get": "me", "list": "me", "merge": "me", "patch": "me", "rename": "me", "tree": "me"},
 	},
 	"id": {
 		AvailableFor:   []string{"delete", "get", "patch"},
@@ -55,12 +57,50 @@ var labelFlags map[string]*gsmhelpers.Flag = map[string]*gsmhelpers.Flag{
 		ExcludeFromAll: true,
 	},
 	"name": {
-		AvailableFor:   []string{"create", "patch"},
-		Type:           "string",
-		Description:    "The display name of the label.",
-		Required:       []string{"create"},
+		AvailableFor: []string{"create", "patch", "rename"},
+		Type:         "string",
+		Description: `The display name of the label.
+Nested labels are separated by "/", e.g. "Projects/Alpha".
+For rename, the current name of the label.`,
+		Required:       []string{"create", "rename"},
 		ExcludeFromAll: true,
 	},
+	"parents": {
+		AvailableFor: []string{"create"},
+		Type:         "bool",
+		Description:  `Create missing parent labels of a nested label, e.g. "a" and "a/b" for "a/b/c".`,
+	},
+	"newName": {
+		AvailableFor: []string{"rename"},
+		Type:         "string",
+		Description: `The new name of the label.
+All nested labels are renamed accordingly and missing parents of the new name are created.`,
+		Required: []string{"rename"},
+	},
+	"source": {
+		AvailableFor: []string{"merge"},
+		Type:         "string",
+		Description:  `Name of the label that should be merged into the target label. The label is deleted after its messages have been relabeled.`,
+		Required:     []string{"merge"},
+	},
+	"target": {
+		AvailableFor: []string{"merge"},
+		Type:         "string",
+		Description:  `Name of the label the source label should be merged into. The label is created if it doesn't exist.`,
+		Required:     []string{"merge"},
+	},
+	"dryRun": {
+		AvailableFor: []string{"merge", "rename"},
+		Type:         "bool",
+		Description:  `Only show which labels would be changed.`,
+	},
+	"format": {
+		AvailableFor: []string{"tree"},
+		Type:         "string",
+		Description: `Output format.
+[json|text]`,
+		Defaults: map[string]any{"tree": "json"},
+	},
 	"messageListVisibility": {
 		AvailableFor: []string{"create", "patch"},
 		Type:         "string",
@@ -96,6 +136,17 @@ func init() {
 	rootCmd.AddCommand(labelsCmd)
 }
 
+// createLabelParents creates the missing parents of a nested label
+func createLabelParents(cache *gsmgmail.LabelCache, name string) error {
+	for _, p := range gsmgmail.LabelParents(name) {
+		_, err := cache.ID(p, true)
+		if err != nil {
+			return fmt.Errorf("error creating parent label %s: %v", p, err)
+		}
+	}
+	return nil
+}
+
 func mapToLabel(flags map[string]*gsmhelpers.Value) (*gmail.Label, error) {
 	label := &gmail.Label{}
 	if flags["name"].IsSet() {
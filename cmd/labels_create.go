@@ -38,7 +38,18 @@ var labelsCreateCmd = &cobra.Command{
 		if err != nil {
 			log.Fatalf("Error building label object: %v", err)
 		}
-		result, err := gsmgmail.CreateLabel(flags["userId"].GetString(), flags["fields"].GetString(), l)
+		userID := flags["userId"].GetString()
+		if flags["parents"].GetBool() {
+			cache, err := gsmgmail.NewLabelCache(userID)
+			if err != nil {
+				log.Fatalf("Error listing labels: %v", err)
+			}
+			err = createLabelParents(cache, l.Name)
+			if err != nil {
+				log.Fatalln(err)
+			}
+		}
+		result, err := gsmgmail.CreateLabel(userID, flags["fields"].GetString(), l)
 		if err != nil {
 			log.Fatalf("Error creating label: %v", err)
 		}
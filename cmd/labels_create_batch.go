@@ -45,6 +45,9 @@ var labelsCreateBatchCmd = &cobra.Command{
 		var wg sync.WaitGroup
 		cap := cap(maps)
 		results := make(chan *gmail.Label, cap)
+		// Label caches are shared between threads, so that parent labels are only created once
+		caches := make(map[string]*gsmgmail.LabelCache)
+		var mu sync.Mutex
 		go func() {
 			for i := 0; i < cap; i++ {
 				wg.Add(1)
@@ -55,7 +58,26 @@ var labelsCreateBatchCmd = &cobra.Command{
 							log.Printf("Error building label object: %v\n", err)
 							continue
 						}
-						result, err := gsmgmail.CreateLabel(m["userId"].GetString(), m["fields"].GetString(), l)
+						userID := m["userId"].GetString()
+						if m["parents"].GetBool() {
+							mu.Lock()
+							cache, ok := caches[userID]
+							if !ok {
+								cache, err = gsmgmail.NewLabelCache(userID)
+								if err == nil {
+									caches[userID] = cache
+								}
+							}
+							mu.Unlock()
+							if err == nil {
+								err = createLabelParents(cache, l.Name)
+							}
+							if err != nil {
+								log.Println(err)
+								continue
+							}
+						}
+						result, err := gsmgmail.CreateLabel(userID, m["fields"].GetString(), l)
 						if err != nil {
 							log.Println(err)
 						} else {
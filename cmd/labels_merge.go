@@ -0,0 +1,146 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hanneshayashi/gsm/gsmgmail"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/api/gmail/v1"
+)
+
+// labelsMergeCmd represents the merge command
+var labelsMergeCmd = &cobra.Command{
+	Use:   "merge",
+	Short: "Merges a label into another label",
+	Long: `Applies the target label to all messages with the source label, removes the source label from them and deletes the source label.
+Nested labels of the source label are moved under the target label. If a nested label with the same name already exists under the target label, the labels are merged as well.
+Example: gsm labels merge --userId user@example.org --source "Old Projects" --target "Archive/Projects"
+
+Implements the APIs documented at:
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.messages/batchModify
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.labels/patch
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.labels/delete`,
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := gsmhelpers.FlagsToMap(cmd.Flags())
+		userID := flags["userId"].GetString()
+		source := strings.Trim(flags["source"].GetString(), "/ ")
+		target := strings.Trim(flags["target"].GetString(), "/ ")
+		if strings.EqualFold(source, target) || gsmgmail.IsLabelDescendant(target, source) {
+			log.Fatalln("The target label can't be the source label or nested under it")
+		}
+		dryRun := flags["dryRun"].GetBool()
+		cache, err := gsmgmail.NewLabelCache(userID)
+		if err != nil {
+			log.Fatalf("Error listing labels: %v", err)
+		}
+		labels := cache.Labels()
+		type mergeResult struct {
+			Name     string `json:"name"`
+			Target   string `json:"target"`
+			Action   string `json:"action"`
+			Messages int    `json:"messages,omitempty"`
+			Status   string `json:"status"`
+			Error    string `json:"error,omitempty"`
+		}
+		var results []*mergeResult
+		// The source label is handled first, then the nested labels from the shallowest to the deepest, so that the parents of a target exist before their children are moved
+		sources := gsmgmail.LabelDescendants(labels, source)
+		if l := gsmgmail.FindLabelByName(labels, source); l != nil {
+			sources = append([]*gmail.Label{l}, sources...)
+		}
+		if len(sources) == 0 {
+			log.Fatalf("Label %s not found", source)
+		}
+		for _, l := range sources {
+			r := &mergeResult{Name: l.Name, Target: target + l.Name[len(source):]}
+			results = append(results, r)
+			// The source label itself is always merged, nested labels are only merged if the target already exists
+			if strings.EqualFold(l.Name, source) || gsmgmail.FindLabelByName(labels, r.Target) != nil {
+				r.Action = "merge"
+			} else {
+				r.Action = "rename"
+			}
+			if dryRun {
+				r.Status = "would" + strings.ToUpper(r.Action[:1]) + r.Action[1:]
+				continue
+			}
+			err = createLabelParents(cache, r.Target)
+			if err == nil {
+				if r.Action == "rename" {
+					_, err = gsmgmail.PatchLabel(userID, l.Id, "id", &gmail.Label{Name: r.Target})
+					if err == nil {
+						cache.Add(l.Id, r.Target)
+					}
+				} else {
+					r.Messages, err = mergeLabelMessages(userID, l.Id, cache, r.Target)
+				}
+			}
+			if err != nil {
+				log.Printf("Error merging label %s into %s: %v", r.Name, r.Target, err)
+				r.Status = "failed"
+				r.Error = err.Error()
+				continue
+			}
+			r.Status = r.Action + "d"
+		}
+		err = gsmhelpers.Output(results, "json", compressOutput)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+// mergeLabelMessages moves all messages from the source label to the target label (creating it if necessary) and deletes the source label.
+// It returns the number of messages that were relabeled.
+func mergeLabelMessages(userID, sourceID string, cache *gsmgmail.LabelCache, target string) (int, error) {
+	targetID, err := cache.ID(target, true)
+	if err != nil {
+		return 0, fmt.Errorf("error creating label %s: %v", target, err)
+	}
+	messages, listErr := gsmgmail.ListMessages(userID, "", "messages(id),nextPageToken", []string{sourceID}, true, gsmhelpers.MaxThreads(0))
+	var ids []string
+	for m := range messages {
+		ids = append(ids, m.Id)
+	}
+	if err := <-listErr; err != nil {
+		return 0, fmt.Errorf("error listing messages: %v", err)
+	}
+	// batchModify accepts up to 1000 messages per request
+	for i := 0; i < len(ids); i += 1000 {
+		_, err = gsmgmail.BatchModifyMessages(userID, ids[i:min(i+1000, len(ids))], []string{targetID}, []string{sourceID})
+		if err != nil {
+			return i, fmt.Errorf("error relabeling messages: %v", err)
+		}
+	}
+	_, err = gsmgmail.DeleteLabel(userID, sourceID)
+	if err != nil {
+		return len(ids), fmt.Errorf("error deleting label: %v", err)
+	}
+	return len(ids), nil
+}
+
+func init() {
+	gsmhelpers.InitCommand(labelsCmd, labelsMergeCmd, labelFlags)
+}
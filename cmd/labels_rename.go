@@ -0,0 +1,106 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"log"
+	"strings"
+
+	"github.com/hanneshayashi/gsm/gsmgmail"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/api/gmail/v1"
+)
+
+// labelsRenameCmd represents the rename command
+var labelsRenameCmd = &cobra.Command{
+	Use:   "rename",
+	Short: "Renames a label together with all of its nested labels",
+	Long: `Renames a label and all labels that are nested under it, e.g. renaming "Projects" to "Archive/Projects" also renames "Projects/Alpha" to "Archive/Projects/Alpha".
+Missing parents of the new name are created.
+If a label with one of the new names already exists, nothing is renamed. Use "gsm labels merge" instead.
+Example: gsm labels rename --userId user@example.org --name "Projects" --newName "Archive/Projects"
+
+Implements the API documented at https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.labels/patch`,
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := gsmhelpers.FlagsToMap(cmd.Flags())
+		userID := flags["userId"].GetString()
+		name := strings.Trim(flags["name"].GetString(), "/ ")
+		newName := strings.Trim(flags["newName"].GetString(), "/ ")
+		cache, err := gsmgmail.NewLabelCache(userID)
+		if err != nil {
+			log.Fatalf("Error listing labels: %v", err)
+		}
+		labels := cache.Labels()
+		renames := labelRenames(labels, name, newName)
+		if len(renames) == 0 {
+			log.Fatalf("Label %s not found", name)
+		}
+		for _, r := range renames {
+			if existing := gsmgmail.FindLabelByName(labels, r.NewName); existing != nil && existing.Id != r.ID {
+				log.Fatalf("A label named %s already exists. Use \"gsm labels merge\" to merge the labels", existing.Name)
+			}
+		}
+		if !flags["dryRun"].GetBool() {
+			err = createLabelParents(cache, newName)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			for _, r := range renames {
+				_, err := gsmgmail.PatchLabel(userID, r.ID, "id", &gmail.Label{Name: r.NewName})
+				if err != nil {
+					log.Printf("Error renaming label %s: %v", r.Name, err)
+					r.Status = "failed"
+					r.Error = err.Error()
+					continue
+				}
+				r.Status = "renamed"
+			}
+		}
+		err = gsmhelpers.Output(renames, "json", compressOutput)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+type labelRename struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	NewName string `json:"newName"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+// labelRenames returns the renames for a label and its nested labels, parents first
+func labelRenames(labels []*gmail.Label, name, newName string) []*labelRename {
+	var renames []*labelRename
+	if l := gsmgmail.FindLabelByName(labels, name); l != nil {
+		renames = append(renames, &labelRename{ID: l.Id, Name: l.Name, NewName: newName, Status: "wouldRename"})
+	}
+	for _, l := range gsmgmail.LabelDescendants(labels, name) {
+		renames = append(renames, &labelRename{ID: l.Id, Name: l.Name, NewName: newName + l.Name[len(name):], Status: "wouldRename"})
+	}
+	return renames
+}
+
+func init() {
+	gsmhelpers.InitCommand(labelsCmd, labelsRenameCmd, labelFlags)
+}
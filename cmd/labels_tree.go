@@ -0,0 +1,93 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"log"
+	"os"
+	"sync"
+
+	"github.com/hanneshayashi/gsm/gsmgmail"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/api/gmail/v1"
+)
+
+// labelsTreeCmd represents the tree command
+var labelsTreeCmd = &cobra.Command{
+	Use:   "tree",
+	Short: "Shows the tree of nested labels with message counts",
+	Long: `Shows the user's labels as a tree of nested labels, with the number of messages and unread messages of each label.
+Parents that don't exist as labels themselves are shown without counts.
+Example: gsm labels tree --userId user@example.org --format text
+
+Implements the APIs documented at:
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.labels/list
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.labels/get`,
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := gsmhelpers.FlagsToMap(cmd.Flags())
+		userID := flags["userId"].GetString()
+		labels, err := gsmgmail.ListLabels(userID, "labels(id,type)")
+		if err != nil {
+			log.Fatalf("Error listing labels: %v", err)
+		}
+		// Message counts are only returned by labels.get
+		ids := make(chan string)
+		var detailed []*gmail.Label
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for range gsmhelpers.MaxThreads(0) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for id := range ids {
+					l, err := gsmgmail.GetLabel(userID, id, "id,name,type,messagesTotal,messagesUnread")
+					if err != nil {
+						log.Printf("Error getting label %s: %v", id, err)
+						continue
+					}
+					mu.Lock()
+					detailed = append(detailed, l)
+					mu.Unlock()
+				}
+			}()
+		}
+		for _, l := range labels {
+			if l.Type != "system" {
+				ids <- l.Id
+			}
+		}
+		close(ids)
+		wg.Wait()
+		tree := gsmgmail.BuildLabelTree(detailed)
+		if flags["format"].GetString() == "text" {
+			err = gsmgmail.WriteLabelTree(os.Stdout, tree)
+		} else {
+			err = gsmhelpers.Output(tree, "json", compressOutput)
+		}
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+func init() {
+	gsmhelpers.InitCommand(labelsCmd, labelsTreeCmd, labelFlags)
+}
@@ -22,9 +22,11 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"net/http"
 	"os"
+	"strings"
+	"sync"
 
+	"github.com/hanneshayashi/gsm/gsmgmail"
 	"github.com/hanneshayashi/gsm/gsmhelpers"
 
 	"github.com/spf13/cobra"
@@ -47,10 +49,10 @@ var messagesCmd = &cobra.Command{
 
 var messageFlags map[string]*gsmhelpers.Flag = map[string]*gsmhelpers.Flag{ //TODO
 	"userId": {
-		AvailableFor: []string{"delete", "modify", "get", "import", "insert", "list", "send", "trash", "untrash"},
+		AvailableFor: []string{"delete", "emlDir", "export", "modify", "get", "import", "insert", "list", "mailMerge", "mbox", "send", "trash", "untrash"},
 		Type:         "string",
 		Description:  `The user's email address. The special value \"me\" can be used to indicate the authenticated user.`,
-		Defaults:     map[string]any{"delete": "me", "modify": "me", "get": "me", "import": "me", "insert": "me", "list": "me", "send": "me", "trash": "me", "untrash": "me"},
+		Defaults:     map[string]any{"delete": "me", "emlDir": "me", "export": "me", "modify": "me", "get": "me", "import": "me", "insert": "me", "list": "me", "mailMerge": "me", "mbox": "me", "send": "me", "trash": "me", "untrash": "me"},
 	},
 	"ids": {
 		AvailableFor:   []string{"batchDelete"},
@@ -60,7 +62,7 @@ var messageFlags map[string]*gsmhelpers.Flag = map[string]*gsmhelpers.Flag{ //TO
 		ExcludeFromAll: true,
 	},
 	"addLabelIds": {
-		AvailableFor: []string{"modify"},
+		AvailableFor: []string{"emlDir", "mbox", "modify"},
 		Type:         "stringSlice",
 		Description:  `A list of label IDs to add to messages.`,
 	},
@@ -76,15 +78,19 @@ var messageFlags map[string]*gsmhelpers.Flag = map[string]*gsmhelpers.Flag{ //TO
 		ExcludeFromAll: true,
 	},
 	"format": {
-		AvailableFor: []string{"get"},
+		AvailableFor: []string{"export", "get"},
 		Type:         "string",
-		Description: `The format to return the message in.
+		Description: `For export, the format of the archive.
+[mbox|eml]
+mbox  - All messages are written to a single mbox file (mailbox.mbox).
+eml   - Every message is written to its own .eml file in a folder named after its label.
+For get, the format to return the message in.
 [MINIMAL|FULL|RAW|METADATA]
 MINIMAL   - Returns only email message ID and labels; does not return the email headers, body, or payload.
 FULL      - Returns the full email message data with body content parsed in the payload field; the raw field is not used. Format cannot be used when accessing the api using the gmail.metadata scope.
 RAW       - Returns the full email message data with body content in the raw field as a base64url encoded string; the payload field is not used. Format cannot be used when accessing the api using the gmail.metadata scope.
 METADATA  - Returns only email message ID, labels, and email headers.`,
-		Defaults: map[string]any{"get": "MINIMAL"},
+		Defaults: map[string]any{"export": "mbox", "get": "MINIMAL"},
 	},
 	"metadataHeaders": {
 		AvailableFor: []string{"get"},
@@ -94,33 +100,33 @@ METADATA  - Returns only email message ID, labels, and email headers.`,
 	"eml": {
 		AvailableFor: []string{"insert", "import"},
 		Type:         "string",
-		Description:  `Path to the local .eml file`,
-		Required:     []string{"insert", "import"},
+		Description: `Path to the local .eml file.
+If this is not set, the message is composed from the other flags (subject, from, to, body, etc.).`,
 	},
 	"internalDateSource": {
-		AvailableFor: []string{"insert", "import"},
+		AvailableFor: []string{"emlDir", "insert", "import", "mbox", "migrate"},
 		Type:         "string",
 		Description:  `Source for Gmail's internal date of the message. [DATE_HEADER|RECEIVED_TIME]`,
-		Defaults:     map[string]any{"insert": "DATE_HEADER", "import": "DATE_HEADER"},
+		Defaults:     map[string]any{"emlDir": "DATE_HEADER", "insert": "DATE_HEADER", "import": "DATE_HEADER", "mbox": "DATE_HEADER", "migrate": "DATE_HEADER"},
 	},
 	"deleted": {
-		AvailableFor: []string{"insert", "import"},
+		AvailableFor: []string{"emlDir", "insert", "import", "mbox"},
 		Type:         "bool",
 		Description: `Mark the email as permanently deleted (not TRASH) and only visible in Google Vault to a Vault administrator.
 Only used for Workspace accounts.`,
 	},
 	"neverMarkSpam": {
-		AvailableFor: []string{"import"},
+		AvailableFor: []string{"emlDir", "import", "mbox", "migrate"},
 		Type:         "bool",
 		Description:  `Ignore the Gmail spam classifier decision and never mark this email as SPAM in the mailbox.`,
 	},
 	"processForCalendar": {
-		AvailableFor: []string{"import"},
+		AvailableFor: []string{"emlDir", "import", "mbox"},
 		Type:         "bool",
 		Description:  `Process calendar invites in the email and add any extracted meetings to the Google Calendar for this user.`,
 	},
 	"q": {
-		AvailableFor: []string{"list"},
+		AvailableFor: []string{"export", "list", "migrate"},
 		Type:         "string",
 		Description: `Only return messages matching the specified query.
 Supports the same query format as the Gmail search box.
@@ -128,60 +134,202 @@ For example, "from:someuser@example.com rfc822msgid:<somemsgid@example.com> is:u
 Parameter cannot be used when accessing the api using the gmail.metadata scope.`,
 	},
 	"labelIds": {
-		AvailableFor: []string{"list"},
+		AvailableFor: []string{"export", "list", "migrate"},
 		Type:         "stringSlice",
 		Description:  `Only return messages with labels that match all of the specified label IDs.`,
 	},
 	"includeSpamTrash": {
-		AvailableFor: []string{"list"},
+		AvailableFor: []string{"export", "list", "migrate"},
 		Type:         "bool",
 		Description:  `Include messages from SPAM and TRASH in the results.`,
 	},
 	"subject": {
-		AvailableFor: []string{"send"},
+		AvailableFor: []string{"import", "insert", "send"},
 		Type:         "string",
 		Description:  "Subject of the (draft) message",
 	},
 	"from": {
-		AvailableFor: []string{"send"},
+		AvailableFor: []string{"import", "insert", "send"},
 		Type:         "string",
 		Description: `Sender of the (draft) message.
 Must be a valid sendAs address.
 If this is not set, your primary sendAs address will be used automatically.`,
 	},
 	"html": {
-		AvailableFor: []string{"send"},
+		AvailableFor: []string{"import", "insert", "send"},
 		Type:         "bool",
 		Description:  "Send the body as HTML",
 	},
 	"to": {
-		AvailableFor: []string{"send"},
+		AvailableFor: []string{"import", "insert", "send"},
 		Type:         "string",
 		Description:  "Recipient of the (draft) message",
 	},
 	"cc": {
-		AvailableFor: []string{"send"},
+		AvailableFor: []string{"import", "insert", "send"},
 		Type:         "string",
 		Description:  "Copy (Cc)",
 	},
 	"bcc": {
-		AvailableFor: []string{"send"},
+		AvailableFor: []string{"import", "insert", "send"},
 		Type:         "string",
 		Description:  "Blind Copy (Bcc)",
 	},
 	"body": {
-		AvailableFor: []string{"send"},
+		AvailableFor: []string{"import", "insert", "send"},
 		Type:         "string",
 		Description:  "Body or content of the (draft) message",
 	},
 	"attachment": {
-		AvailableFor: []string{"send"},
+		AvailableFor: []string{"import", "insert", "send"},
 		Type:         "stringSlice",
 		Description: `Path to a file that should be attached to the message.
 Can be used multiple times.`,
 	},
+	"textBody": {
+		AvailableFor: []string{"import", "insert", "send"},
+		Type:         "string",
+		Description: `Plain text version of the body.
+Only used with --html. The message will contain both versions (multipart/alternative).`,
+	},
+	"replyTo": {
+		AvailableFor: []string{"import", "insert", "send"},
+		Type:         "string",
+		Description:  "Reply-To address(es) of the (draft) message",
+	},
+	"inReplyTo": {
+		AvailableFor: []string{"import", "insert", "send"},
+		Type:         "string",
+		Description:  "Message-ID of the message this (draft) message replies to, e.g. \"<abc@mail.gmail.com>\"",
+	},
+	"references": {
+		AvailableFor: []string{"import", "insert", "send"},
+		Type:         "string",
+		Description:  "Message-IDs of the previous messages in the thread, separated by spaces",
+	},
+	"header": {
+		AvailableFor: []string{"import", "insert", "mailMerge", "send"},
+		Type:         "stringSlice",
+		Description: `Additional header in the form "Name: value".
+Can be used multiple times.`,
+	},
+	"inlineImage": {
+		AvailableFor: []string{"import", "insert", "mailMerge", "send"},
+		Type:         "stringSlice",
+		Description: `Path to an image that should be embedded in the HTML body.
+The image can be referenced by its file name, e.g. <img src="cid:logo.png">.
+If the message has no HTML body, the image is added as a regular attachment.
+Can be used multiple times.`,
+	},
+	"data": {
+		AvailableFor: []string{"mailMerge"},
+		Type:         "string",
+		Description: `Path to a CSV file with a header.
+Every line is rendered into one message. The columns can be used in the templates, e.g. {{.firstName}}.
+The columns "to", "cc", "bcc", "from" and "replyTo" are used as the headers of the message.
+"from" must be a send-as address of the user.`,
+		Required: []string{"mailMerge"},
+	},
+	"delimiter": {
+		AvailableFor: []string{"mailMerge"},
+		Type:         "string",
+		Description:  "Delimiter to use for CSV columns. Must be exactly one character. Default is ';'",
+	},
+	"subjectTemplate": {
+		AvailableFor: []string{"mailMerge"},
+		Type:         "string",
+		Description:  `Go text/template for the subject, e.g. "Welcome, {{.firstName}}!"`,
+		Required:     []string{"mailMerge"},
+	},
+	"textTemplate": {
+		AvailableFor: []string{"mailMerge"},
+		Type:         "string",
+		Description:  `Path to a Go text/template file for the plain text body.`,
+	},
+	"htmlTemplate": {
+		AvailableFor: []string{"mailMerge"},
+		Type:         "string",
+		Description: `Path to a Go html/template file for the HTML body.
+If both --textTemplate and --htmlTemplate are set, the message contains both versions.`,
+	},
+	"attachmentTemplate": {
+		AvailableFor: []string{"mailMerge"},
+		Type:         "stringSlice",
+		Description: `Go text/template for the path of a file that should be attached, e.g. "invoices/{{.customerId}}.pdf".
+Templates that render to an empty string are ignored.
+Can be used multiple times.`,
+	},
+	"preview": {
+		AvailableFor: []string{"mailMerge"},
+		Type:         "string",
+		Description: `Path to a directory.
+If this is set, the rendered messages are written to this directory as .eml files instead of being sent.`,
+	},
+	"draft": {
+		AvailableFor: []string{"mailMerge"},
+		Type:         "bool",
+		Description:  `Create drafts instead of sending the messages.`,
+	},
+	"rate": {
+		AvailableFor: []string{"mailMerge"},
+		Type:         "int",
+		Description:  `Maximum number of messages to send (or drafts to create) per minute.`,
+		Defaults:     map[string]any{"mailMerge": 20},
+	},
+	"dailyLimit": {
+		AvailableFor: []string{"mailMerge"},
+		Type:         "int",
+		Description: `Maximum number of messages to send in this run.
+Lines after the limit is reached are skipped. Gmail allows Workspace users to send 2000 messages per day.
+Set to 0 to disable the limit.`,
+		Defaults: map[string]any{"mailMerge": 2000},
+	},
+	"path": {
+		AvailableFor: []string{"emlDir", "mbox"},
+		Type:         "string",
+		Description: `Path to the mbox file or to the directory that contains the .eml files.
+.eml files in subdirectories are imported with a label named after the subdirectory (e.g. "Projects/Alpha").`,
+		Required: []string{"emlDir", "mbox"},
+	},
+	"noLabels": {
+		AvailableFor: []string{"emlDir", "mbox"},
+		Type:         "bool",
+		Description:  `Don't apply (or create) labels from X-Gmail-Labels headers or folder names.`,
+	},
+	"sourceUser": {
+		AvailableFor: []string{"migrate"},
+		Type:         "string",
+		Description:  `Email address of the user whose messages should be copied.`,
+		Required:     []string{"migrate"},
+	},
+	"targetUser": {
+		AvailableFor: []string{"migrate"},
+		Type:         "string",
+		Description:  `Email address of the user the messages should be copied to.`,
+		Required:     []string{"migrate"},
+	},
+	"labelPrefix": {
+		AvailableFor: []string{"migrate"},
+		Type:         "string",
+		Description: `Name of a label that is applied to all migrated messages.
+The source user's labels are created as nested labels under this label, e.g. "From Alice/Projects".`,
+	},
+	"dryRun": {
+		AvailableFor: []string{"migrate"},
+		Type:         "bool",
+		Description:  `Only check which messages would be migrated.`,
+	},
+	"dir": {
+		AvailableFor: []string{"export"},
+		Type:         "string",
+		Description: `Path to the directory the messages are exported to.
+The directory contains a manifest (manifest.jsonl) with the ID, labels and SHA-256 checksum of every exported message.
+If the directory already contains a manifest, messages that are already listed in it are skipped,
+so an interrupted export can be resumed by running the same command again.`,
+		Required: []string{"export"},
+	},
 	"fields": {
-		AvailableFor: []string{"get", "import", "insert", "list", "modify", "send", "trash", "untrash"},
+		AvailableFor: []string{"get", "import", "insert", "list", "mailMerge", "modify", "send", "trash", "untrash"},
 		Type:         "string",
 		Description: `Fields allows partial responses to be retrieved.
 See https://developers.google.com/gdata/docs/2.0/basics#PartialResponse for more information.`,
@@ -193,63 +341,70 @@ func init() {
 	rootCmd.AddCommand(messagesCmd)
 }
 
-func mapToMessage(flags map[string]*gsmhelpers.Value) (*gmail.Message, error) {
-	message := &gmail.Message{}
-	header := make(map[string]string)
-	var msg string
-	boundary := "asdh9818gdhKA**GSM**adhiu2GSM==="
-	header["Content-Type"] = fmt.Sprintf("multipart/mixed; boundary=\"%s\"", boundary)
-	header["MIME-Version"] = "1.0"
-	if flags["from"].IsSet() {
-		header["From"] = flags["from"].GetString()
-	}
-	if flags["to"].IsSet() {
-		header["To"] = flags["to"].GetString()
-	}
-	if flags["cc"].IsSet() {
-		header["Cc"] = flags["cc"].GetString()
-	}
-	if flags["bcc"].IsSet() {
-		header["Bcc"] = flags["bcc"].GetString()
+// mapToMailMessage composes a message from the subject, from, to, body, etc. flags
+func mapToMailMessage(flags map[string]*gsmhelpers.Value) (*gsmgmail.MailMessage, error) {
+	m := &gsmgmail.MailMessage{}
+	for k, v := range map[string]*string{"from": &m.From, "to": &m.To, "cc": &m.Cc, "bcc": &m.Bcc, "replyTo": &m.ReplyTo, "subject": &m.Subject, "inReplyTo": &m.InReplyTo, "references": &m.References} {
+		if flags[k].IsSet() {
+			*v = flags[k].GetString()
+		}
 	}
-	header["Subject"] = flags["subject"].GetString()
-	for i := range header {
-		msg += fmt.Sprintf("%s: %s\n", i, header[i])
+	var body string
+	if flags["body"].IsSet() {
+		body = flags["body"].GetString()
 	}
-	msg += fmt.Sprintf("\n--%s\n", boundary)
-	var format string
-	if flags["html"].GetBool() {
-		format = "html"
+	if flags["html"].IsSet() && flags["html"].GetBool() {
+		m.HTML = body
+		if flags["textBody"].IsSet() {
+			m.Text = flags["textBody"].GetString()
+		}
 	} else {
-		format = "plain"
+		m.Text = body
+	}
+	if flags["header"].IsSet() {
+		for _, h := range flags["header"].GetStringSlice() {
+			header, err := gsmgmail.ParseMailHeader(h)
+			if err != nil {
+				return nil, err
+			}
+			m.Headers = append(m.Headers, header)
+		}
 	}
-	msg += "Content-Type: text/" + format + "; charset=\"utf-8\"\n"
-	msg += "MIME-Version: 1.0\n"
-	msg += "Content-Transfer-Encoding: 7bit\n\n"
-	body := flags["body"].GetString()
-	msg += "\n" + body
 	if flags["attachment"].IsSet() {
-		attachments := flags["attachment"].GetStringSlice()
-		for i := range attachments {
-			file, err := os.Open(attachments[i])
+		for _, path := range flags["attachment"].GetStringSlice() {
+			part, err := gsmgmail.ReadMailPart(path)
 			if err != nil {
 				return nil, err
 			}
-			bytes, err := io.ReadAll(file)
+			m.Attachments = append(m.Attachments, part)
+		}
+	}
+	if flags["inlineImage"].IsSet() {
+		for _, path := range flags["inlineImage"].GetStringSlice() {
+			part, err := gsmgmail.ReadMailPart(path)
 			if err != nil {
 				return nil, err
 			}
-			msg += fmt.Sprintf("\n--%s\n", boundary)
-			msg += "Content-Type: " + http.DetectContentType(bytes) + "; name=" + file.Name() + "\n"
-			msg += "MIME-Version: 1.0\n"
-			msg += "Content-Transfer-Encoding: base64\n"
-			msg += "Content-Disposition: attachment; filename=\"" + file.Name() + "\"\n\n"
-			msg += base64.StdEncoding.EncodeToString(bytes) + "\n"
+			m.InlineImages = append(m.InlineImages, part)
 		}
 	}
-	msg += fmt.Sprintf("\n--%s--\n", boundary)
-	message.Raw = base64.URLEncoding.EncodeToString([]byte(msg))
-	return message, nil
+	return m, nil
+}
+
+// mapToMessage reads the message from the eml file, if set, or composes it from the other flags
+func mapToMessage(flags map[string]*gsmhelpers.Value) (*gmail.Message, error) {
+	if flags["eml"].IsSet() {
+		return emlToMessage(flags["eml"].GetString())
+	}
+	m, err := mapToMailMessage(flags)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := m.Raw()
+	if err != nil {
+		return nil, err
+	}
+	return &gmail.Message{Raw: raw}, nil
 }
 
 func emlToMessage(eml string) (*gmail.Message, error) {
@@ -266,3 +421,113 @@ func emlToMessage(eml string) (*gmail.Message, error) {
 	}
 	return message, nil
 }
+
+// mailImportJob is a message read from an mbox file or .eml directory
+type mailImportJob struct {
+	source string
+	raw    []byte
+	labels []string
+}
+
+type mailImportResult struct {
+	Source string   `json:"source"`
+	ID     string   `json:"id,omitempty"`
+	Labels []string `json:"labels,omitempty"`
+	Status string   `json:"status"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// importMailArchive imports the messages read by readJobs in parallel.
+// readJobs must send all messages to the channel and return. It must not close the channel.
+func importMailArchive(flags map[string]*gsmhelpers.Value, readJobs func(chan<- *mailImportJob) error) {
+	userID := flags["userId"].GetString()
+	internalDateSource := strings.ToUpper(flags["internalDateSource"].GetString())
+	if !gsmgmail.InternalDateSourceIsValid(internalDateSource) {
+		log.Fatalf("%s is not a valid value for internalDateSource", internalDateSource)
+	}
+	noLabels := flags["noLabels"].GetBool()
+	var cache *gsmgmail.LabelCache
+	if !noLabels {
+		var err error
+		cache, err = gsmgmail.NewLabelCache(userID)
+		if err != nil {
+			log.Fatalf("Error listing labels: %v", err)
+		}
+	}
+	addLabelIds := flags["addLabelIds"].GetStringSlice()
+	deleted := flags["deleted"].GetBool()
+	neverMarkSpam := flags["neverMarkSpam"].GetBool()
+	processForCalendar := flags["processForCalendar"].GetBool()
+	threads := gsmhelpers.MaxThreads(0)
+	// The channel is buffered by the number of threads, so only a few messages are kept in memory at a time
+	jobs := make(chan *mailImportJob, threads)
+	results := make(chan *mailImportResult, threads)
+	var readErr error
+	go func() {
+		readErr = readJobs(jobs)
+		close(jobs)
+	}()
+	var wg sync.WaitGroup
+	for range threads {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				r := &mailImportResult{Source: j.source, Status: "failed"}
+				labelIds := append([]string{}, addLabelIds...)
+				if !noLabels {
+					r.Labels = j.labels
+					for _, l := range j.labels {
+						id, err := cache.ID(l, true)
+						if err != nil {
+							r.Error = fmt.Sprintf("error creating label %s: %v", l, err)
+							break
+						}
+						if id != "" && !gsmhelpers.Contains(id, labelIds) {
+							labelIds = append(labelIds, id)
+						}
+					}
+				}
+				if r.Error == "" {
+					message := &gmail.Message{Raw: base64.URLEncoding.EncodeToString(j.raw), LabelIds: labelIds}
+					result, err := gsmgmail.ImportMessage(userID, internalDateSource, "id", message, deleted, neverMarkSpam, processForCalendar)
+					if err != nil {
+						r.Error = err.Error()
+					} else {
+						r.ID = result.Id
+						r.Status = "imported"
+					}
+				}
+				if r.Error != "" {
+					log.Printf("Error importing %s: %s", r.Source, r.Error)
+				}
+				results <- r
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	if streamOutput {
+		enc := gsmhelpers.GetJSONEncoder(false)
+		for r := range results {
+			err := enc.Encode(r)
+			if err != nil {
+				log.Println(err)
+			}
+		}
+	} else {
+		final := []*mailImportResult{}
+		for r := range results {
+			final = append(final, r)
+		}
+		err := gsmhelpers.Output(final, "json", compressOutput)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if readErr != nil {
+		log.Fatalf("Error reading messages: %v", readErr)
+	}
+}
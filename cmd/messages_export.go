@@ -0,0 +1,259 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hanneshayashi/gsm/gsmgmail"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/api/gmail/v1"
+)
+
+const (
+	mailExportManifest = "manifest.jsonl"
+	mailExportMbox     = "mailbox.mbox"
+)
+
+// messagesExportCmd represents the export command
+var messagesExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Exports messages to a local mbox file or .eml files",
+	Long: `Exports all messages of a user (or the messages that match --q / --labelIds) to a local directory.
+Messages are downloaded in RAW format in parallel and written either to a single mbox file (mboxrd format, with an X-Gmail-Labels header)
+or as one .eml file per message in a folder named after the first user label of the message (or its system label, e.g. INBOX).
+Every exported message is recorded in a manifest, so the export can be resumed if it is interrupted.
+Example: gsm messages export --userId user@example.org --format eml --dir ./user@example.org
+
+Implements the APIs documented at:
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.messages/list
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.messages/get`,
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := gsmhelpers.FlagsToMap(cmd.Flags())
+		userID := flags["userId"].GetString()
+		dir := flags["dir"].GetString()
+		format := strings.ToLower(flags["format"].GetString())
+		if format != "mbox" && format != "eml" {
+			log.Fatalf("Unknown format %s. Must be mbox or eml", format)
+		}
+		err := os.MkdirAll(dir, 0o755)
+		if err != nil {
+			log.Fatalf("Error creating export directory: %v", err)
+		}
+		labels, err := gsmgmail.ListLabels(userID, "labels(id,name,type)")
+		if err != nil {
+			log.Fatalf("Error listing labels: %v", err)
+		}
+		manifestPath := filepath.Join(dir, mailExportManifest)
+		entries, err := gsmgmail.ReadMailExportManifest(manifestPath)
+		if err != nil {
+			log.Fatalf("Error reading manifest: %v", err)
+		}
+		done := make(map[string]bool, len(entries))
+		var mboxEnd int64
+		for _, e := range entries {
+			done[e.ID] = true
+			if e.File == mailExportMbox && e.Offset+e.Size > mboxEnd {
+				mboxEnd = e.Offset + e.Size
+			}
+		}
+		var mbox *os.File
+		if format == "mbox" {
+			mbox, err = os.OpenFile(filepath.Join(dir, mailExportMbox), os.O_CREATE|os.O_WRONLY, 0o644)
+			if err != nil {
+				log.Fatalf("Error opening mbox file: %v", err)
+			}
+			defer gsmhelpers.CloseLog(mbox, "mbox")
+			// Remove messages that were written by a previous run but never made it into the manifest
+			err = mbox.Truncate(mboxEnd)
+			if err != nil {
+				log.Fatalf("Error truncating mbox file: %v", err)
+			}
+			_, err = mbox.Seek(mboxEnd, 0)
+			if err != nil {
+				log.Fatalf("Error seeking mbox file: %v", err)
+			}
+		}
+		manifest, err := os.OpenFile(manifestPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			log.Fatalf("Error opening manifest: %v", err)
+		}
+		defer gsmhelpers.CloseLog(manifest, "manifest")
+		type exportSummary struct {
+			Exported int      `json:"exported"`
+			Skipped  int      `json:"skipped"`
+			Failed   int      `json:"failed"`
+			Errors   []string `json:"errors,omitempty"`
+		}
+		summary := &exportSummary{}
+		threads := gsmhelpers.MaxThreads(0)
+		messages, listErr := gsmgmail.ListMessages(userID, flags["q"].GetString(), "messages(id),nextPageToken", flags["labelIds"].GetStringSlice(), flags["includeSpamTrash"].GetBool(), threads)
+		ids := make(chan string, threads)
+		fetched := make(chan *gmail.Message, threads)
+		var mu sync.Mutex
+		fail := func(id string, err error) {
+			log.Printf("Error exporting message %s: %v", id, err)
+			mu.Lock()
+			summary.Failed++
+			summary.Errors = append(summary.Errors, fmt.Sprintf("%s: %v", id, err))
+			mu.Unlock()
+		}
+		go func() {
+			for m := range messages {
+				if done[m.Id] {
+					summary.Skipped++
+					continue
+				}
+				ids <- m.Id
+			}
+			close(ids)
+		}()
+		var wg sync.WaitGroup
+		for range threads {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for id := range ids {
+					m, err := gsmgmail.GetMessage(userID, id, "RAW", "", "id,threadId,labelIds,internalDate,raw")
+					if err != nil {
+						fail(id, err)
+						continue
+					}
+					fetched <- m
+				}
+			}()
+		}
+		go func() {
+			wg.Wait()
+			close(fetched)
+		}()
+		enc := json.NewEncoder(manifest)
+		for m := range fetched {
+			raw, err := gsmgmail.DecodeRaw(m.Raw)
+			if err != nil {
+				fail(m.Id, err)
+				continue
+			}
+			sum := sha256.Sum256(raw)
+			entry := &gsmgmail.MailExportManifestEntry{
+				ID:           m.Id,
+				ThreadID:     m.ThreadId,
+				LabelIDs:     m.LabelIds,
+				Labels:       mailExportLabelNames(m.LabelIds, labels),
+				InternalDate: m.InternalDate,
+				SHA256:       hex.EncodeToString(sum[:]),
+			}
+			if format == "mbox" {
+				entry.File = mailExportMbox
+				entry.Offset = mboxEnd
+				entry.Size, err = gsmgmail.WriteMboxMessage(mbox, raw, time.UnixMilli(m.InternalDate), entry.Labels)
+				mboxEnd += entry.Size
+			} else {
+				entry.File = filepath.Join(mailExportFolder(m.LabelIds, labels), m.Id+".eml")
+				err = gsmhelpers.WriteFileAtomic(filepath.Join(dir, entry.File), raw)
+			}
+			if err != nil {
+				fail(m.Id, err)
+				if format == "mbox" {
+					log.Fatalf("Error writing mbox file: %v", err)
+				}
+				continue
+			}
+			err = enc.Encode(entry)
+			if err != nil {
+				log.Fatalf("Error writing manifest: %v", err)
+			}
+			summary.Exported++
+		}
+		if e := <-listErr; e != nil {
+			log.Printf("Error listing messages: %v", e)
+			summary.Errors = append(summary.Errors, e.Error())
+		}
+		err = gsmhelpers.Output(summary, "json", compressOutput)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+// mailExportLabelNames returns the names of the labels of a message
+func mailExportLabelNames(labelIDs []string, labels []*gmail.Label) []string {
+	var names []string
+	for _, id := range labelIDs {
+		name := id
+		for _, l := range labels {
+			if l.Id == id {
+				name = l.Name
+				break
+			}
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+var mailExportSystemFolders = []string{"INBOX", "SENT", "DRAFT", "SPAM", "TRASH", "CHAT"}
+
+var unsafePathChars = regexp.MustCompile(`[<>:"\\|?*\x00-\x1f]`)
+
+// mailExportFolder returns the folder for a message in an eml export.
+// This is the first user label (by name) of the message, a system label like INBOX or "Archive".
+// Nested labels (e.g. "Projects/Alpha") become nested folders.
+func mailExportFolder(labelIDs []string, labels []*gmail.Label) string {
+	var userLabels []string
+	for _, l := range labels {
+		if l.Type == "user" && gsmhelpers.Contains(l.Id, labelIDs) {
+			userLabels = append(userLabels, l.Name)
+		}
+	}
+	if len(userLabels) > 0 {
+		sort.Strings(userLabels)
+		segments := strings.Split(userLabels[0], "/")
+		for i := range segments {
+			segments[i] = strings.Trim(unsafePathChars.ReplaceAllString(segments[i], "_"), " .")
+			if segments[i] == "" {
+				segments[i] = "_"
+			}
+		}
+		return filepath.Join(segments...)
+	}
+	for _, f := range mailExportSystemFolders {
+		if gsmhelpers.Contains(f, labelIDs) {
+			return f
+		}
+	}
+	return "Archive"
+}
+
+func init() {
+	gsmhelpers.InitCommand(messagesCmd, messagesExportCmd, messageFlags)
+}
@@ -50,9 +50,9 @@ var messagesImportBatchCmd = &cobra.Command{
 				wg.Add(1)
 				go func() {
 					for m := range maps {
-						message, err := emlToMessage(m["eml"].GetString())
+						message, err := mapToMessage(m)
 						if err != nil {
-							log.Printf("Error with eml file: %v\n", err)
+							log.Printf("Error building message object: %v\n", err)
 							continue
 						}
 						result, err := gsmgmail.ImportMessage(m["userId"].GetString(), m["internalDateSource"].GetString(), m["fields"].GetString(), message, m["deleted"].GetBool(), m["neverMarkSpam"].GetBool(), m["processForCalendar"].GetBool())
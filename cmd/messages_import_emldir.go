@@ -0,0 +1,71 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+)
+
+// messagesImportEmlDirCmd represents the emlDir command
+var messagesImportEmlDirCmd = &cobra.Command{
+	Use:   "emlDir",
+	Short: "Imports all .eml files in a directory",
+	Long: `Imports every .eml file in a directory and its subdirectories (e.g. from "gsm messages export --format eml") into the user's mailbox.
+Files in subdirectories are imported with a label named after the subdirectory, e.g. "Projects/Alpha/123.eml" gets the label "Projects/Alpha".
+Missing labels (including nested labels) are created. System folders (e.g. INBOX, SENT) are mapped to the system labels, "Archive" is ignored.
+Files directly in the directory are imported without a label, i.e. they are not shown in the inbox unless you use --addLabelIds INBOX.
+Example: gsm messages import emlDir --userId user@example.org --path ./user@example.org
+
+Implements the API documented at https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.messages/import`,
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := gsmhelpers.FlagsToMap(cmd.Flags())
+		root := flags["path"].GetString()
+		importMailArchive(flags, func(jobs chan<- *mailImportJob) error {
+			return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".eml") {
+					return nil
+				}
+				raw, err := os.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				j := &mailImportJob{source: path, raw: raw}
+				if folder, err := filepath.Rel(root, filepath.Dir(path)); err == nil && folder != "." {
+					j.labels = []string{filepath.ToSlash(folder)}
+				}
+				jobs <- j
+				return nil
+			})
+		})
+	},
+}
+
+func init() {
+	gsmhelpers.InitCommand(messagesImportCmd, messagesImportEmlDirCmd, messageFlags)
+}
@@ -0,0 +1,70 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hanneshayashi/gsm/gsmgmail"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+)
+
+// messagesImportMboxCmd represents the mbox command
+var messagesImportMboxCmd = &cobra.Command{
+	Use:   "mbox",
+	Short: "Imports all messages from an mbox file",
+	Long: `Imports every message of an mbox file (e.g. from Google Takeout or "gsm messages export") into the user's mailbox.
+The file is read one message at a time, so large files are not loaded into memory.
+Lines that are quoted with ">" (">From ") are unquoted (mboxrd).
+Labels from the X-Gmail-Labels header of a message are applied to the imported message. Missing labels (including nested labels) are created.
+Messages without labels are not shown in the inbox. Use --addLabelIds INBOX to add them to the inbox.
+Example: gsm messages import mbox --userId user@example.org --path archive.mbox
+
+Implements the API documented at https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.messages/import`,
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := gsmhelpers.FlagsToMap(cmd.Flags())
+		path := flags["path"].GetString()
+		importMailArchive(flags, func(jobs chan<- *mailImportJob) error {
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer gsmhelpers.CloseLog(f, "mbox")
+			r := gsmgmail.NewMboxReader(f)
+			for {
+				m, err := r.Next()
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				jobs <- &mailImportJob{source: fmt.Sprintf("%s#%d", path, m.Number), raw: m.Raw, labels: m.Labels}
+			}
+		})
+	},
+}
+
+func init() {
+	gsmhelpers.InitCommand(messagesImportCmd, messagesImportMboxCmd, messageFlags)
+}
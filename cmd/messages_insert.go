@@ -41,9 +41,9 @@ Does not send a message.`,
 		if !gsmgmail.InternalDateSourceIsValid(internalDateSource) {
 			log.Fatalf("%s is not a valid value for internalDateSource", internalDateSource)
 		}
-		message, err := emlToMessage(flags["eml"].GetString())
+		message, err := mapToMessage(flags)
 		if err != nil {
-			log.Fatalf("Error with eml file: %v", err)
+			log.Fatalf("Error building message object: %v", err)
 		}
 		result, err := gsmgmail.InsertMessage(flags["userId"].GetString(), internalDateSource, flags["fields"].GetString(), message, flags["deleted"].GetBool())
 		if err != nil {
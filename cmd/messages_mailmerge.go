@@ -0,0 +1,229 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hanneshayashi/gsm/gsmgmail"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/api/gmail/v1"
+)
+
+// messagesMailMergeCmd represents the mailMerge command
+var messagesMailMergeCmd = &cobra.Command{
+	Use:   "mailMerge",
+	Short: "Sends personalized messages (or creates drafts) from templates and a CSV file",
+	Long: `Renders one message for every line of the CSV file (see --data) with Go templates (https://pkg.go.dev/text/template).
+The subject, bodies and attachment paths are templates that can use the columns of the line, e.g. {{.firstName}}.
+Templates that reference a column that doesn't exist fail for that line.
+Use --preview to write the rendered messages to a local directory as .eml files and check them before sending.
+Messages are sent one after another and throttled according to --rate and --dailyLimit to stay within Gmail's sending limits
+(see https://support.google.com/a/answer/166852).
+Example: gsm messages mailMerge --data recipients.csv --subjectTemplate "Welcome, {{.firstName}}!" --htmlTemplate welcome.html --textTemplate welcome.txt --preview ./preview
+
+Implements the APIs documented at:
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.messages/send
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.drafts/create`,
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := gsmhelpers.FlagsToMap(cmd.Flags())
+		delimiter := ';'
+		if flags["delimiter"].IsSet() {
+			delimiter = flags["delimiter"].GetRune()
+		}
+		records, err := gsmhelpers.GetCSVRecords(flags["data"].GetString(), delimiter)
+		if err != nil {
+			log.Fatalf("Error reading data: %v", err)
+		}
+		var attachments []string
+		if flags["attachmentTemplate"].IsSet() {
+			attachments = flags["attachmentTemplate"].GetStringSlice()
+		}
+		var textTemplate, htmlTemplate string
+		if flags["textTemplate"].IsSet() {
+			textTemplate = flags["textTemplate"].GetString()
+		}
+		if flags["htmlTemplate"].IsSet() {
+			htmlTemplate = flags["htmlTemplate"].GetString()
+		}
+		tmpl, err := gsmgmail.NewMailMergeTemplate(flags["subjectTemplate"].GetString(), textTemplate, htmlTemplate, attachments)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		// Custom headers and inline images are the same for every message
+		base, err := mapToMailMessage(flags)
+		if err != nil {
+			log.Fatalf("Error building message object: %v", err)
+		}
+		userID := flags["userId"].GetString()
+		fields := flags["fields"].GetString()
+		draft := flags["draft"].GetBool()
+		var previewDir string
+		if flags["preview"].IsSet() {
+			previewDir = flags["preview"].GetString()
+			err = os.MkdirAll(previewDir, 0o755)
+			if err != nil {
+				log.Fatalf("Error creating preview directory: %v", err)
+			}
+		}
+		var sendAs map[string]bool
+		if previewDir == "" {
+			sendAs, err = mailMergeSendAs(userID)
+			if err != nil {
+				log.Fatalf("Error listing send-as addresses: %v", err)
+			}
+		}
+		dailyLimit := flags["dailyLimit"].GetInt()
+		var ticker *time.Ticker
+		if rate := flags["rate"].GetInt(); rate > 0 && previewDir == "" {
+			ticker = time.NewTicker(time.Minute / time.Duration(rate))
+			defer ticker.Stop()
+		}
+		type mergeResult struct {
+			Line   int    `json:"line"`
+			To     string `json:"to"`
+			Status string `json:"status"`
+			ID     string `json:"id,omitempty"`
+			File   string `json:"file,omitempty"`
+			Error  string `json:"error,omitempty"`
+		}
+		results := make([]*mergeResult, 0, len(records))
+		sent := 0
+		for i, record := range records {
+			// Line 1 is the header of the CSV file
+			r := &mergeResult{Line: i + 2, To: record["to"]}
+			results = append(results, r)
+			fail := func(err error) {
+				log.Printf("Line %d: %v", r.Line, err)
+				r.Status = "failed"
+				r.Error = err.Error()
+			}
+			m, err := tmpl.Render(record)
+			if err != nil {
+				fail(err)
+				continue
+			}
+			m.Headers = base.Headers
+			m.InlineImages = base.InlineImages
+			if previewDir != "" {
+				r.File, err = writeMailMergePreview(previewDir, r.Line, m)
+				if err != nil {
+					fail(err)
+					continue
+				}
+				r.Status = "previewed"
+				continue
+			}
+			if m.From != "" {
+				from, err := mail.ParseAddress(m.From)
+				if err != nil {
+					fail(fmt.Errorf("invalid from address %q: %v", m.From, err))
+					continue
+				}
+				if !sendAs[strings.ToLower(from.Address)] {
+					fail(fmt.Errorf("%s is not a verified send-as address of %s", from.Address, userID))
+					continue
+				}
+			}
+			if dailyLimit > 0 && sent >= dailyLimit {
+				r.Status = "skipped"
+				r.Error = "daily limit reached"
+				continue
+			}
+			raw, err := m.Raw()
+			if err != nil {
+				fail(err)
+				continue
+			}
+			if ticker != nil && sent > 0 {
+				<-ticker.C
+			}
+			sent++
+			if draft {
+				result, err := gsmgmail.CreateDraft(userID, fields, &gmail.Draft{Message: &gmail.Message{Raw: raw}})
+				if err != nil {
+					fail(err)
+					continue
+				}
+				r.Status = "drafted"
+				r.ID = result.Id
+			} else {
+				result, err := gsmgmail.SendMessage(userID, fields, &gmail.Message{Raw: raw})
+				if err != nil {
+					fail(err)
+					continue
+				}
+				r.Status = "sent"
+				r.ID = result.Id
+			}
+		}
+		err = gsmhelpers.Output(results, "json", compressOutput)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+// mailMergeSendAs returns the verified send-as addresses of the user
+func mailMergeSendAs(userID string) (map[string]bool, error) {
+	sendAs, err := gsmgmail.ListSendAs(userID, "sendAs(sendAsEmail,isPrimary,verificationStatus)")
+	if err != nil {
+		return nil, err
+	}
+	addresses := make(map[string]bool)
+	for _, s := range sendAs {
+		if s.IsPrimary || s.VerificationStatus == "" || s.VerificationStatus == "accepted" {
+			addresses[strings.ToLower(s.SendAsEmail)] = true
+		}
+	}
+	return addresses, nil
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9@._-]+`)
+
+// writeMailMergePreview writes the rendered message of a line to the preview directory and returns the path of the file
+func writeMailMergePreview(dir string, line int, m *gsmgmail.MailMessage) (string, error) {
+	b, err := m.Bytes()
+	if err != nil {
+		return "", err
+	}
+	to := m.To
+	if addresses, err := mail.ParseAddressList(m.To); err == nil && len(addresses) > 0 {
+		to = addresses[0].Address
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%04d-%s.eml", line, unsafeFileNameChars.ReplaceAllString(to, "_")))
+	err = os.WriteFile(path, b, 0o644)
+	if err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func init() {
+	gsmhelpers.InitCommand(messagesCmd, messagesMailMergeCmd, messageFlags)
+}
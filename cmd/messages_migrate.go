@@ -0,0 +1,264 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/hanneshayashi/gsm/gsmgmail"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/api/gmail/v1"
+)
+
+// messagesMigrateCmd represents the migrate command
+var messagesMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Copies messages from one user's mailbox to another user's mailbox",
+	Long: `Copies all messages of the source user (or the messages that match --q / --labelIds) to the target user.
+Messages are imported into the target mailbox with their original date, read / starred state and labels.
+Missing labels are created in the target mailbox. Use --labelPrefix to nest them under a common label.
+Messages whose Message-ID already exists in the target mailbox are skipped, so the command can safely be run again.
+Drafts and chats are not migrated.
+Example: gsm messages migrate --sourceUser alice@example.org --targetUser team@example.org --q "label:projects" --labelPrefix "From Alice"
+
+Implements the APIs documented at:
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.messages/list
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.messages/get
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.messages/import`,
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := gsmhelpers.FlagsToMap(cmd.Flags())
+		sourceUser := flags["sourceUser"].GetString()
+		targetUser := flags["targetUser"].GetString()
+		internalDateSource := strings.ToUpper(flags["internalDateSource"].GetString())
+		if !gsmgmail.InternalDateSourceIsValid(internalDateSource) {
+			log.Fatalf("%s is not a valid value for internalDateSource", internalDateSource)
+		}
+		dryRun := flags["dryRun"].GetBool()
+		neverMarkSpam := flags["neverMarkSpam"].GetBool()
+		sourceLabels, err := gsmgmail.ListLabels(sourceUser, "labels(id,name,type)")
+		if err != nil {
+			log.Fatalf("Error listing labels of %s: %v", sourceUser, err)
+		}
+		target, err := gsmgmail.NewLabelCache(targetUser)
+		if err != nil {
+			log.Fatalf("Error listing labels of %s: %v", targetUser, err)
+		}
+		prefix := strings.Trim(flags["labelPrefix"].GetString(), "/ ")
+		var prefixID string
+		if prefix != "" && !dryRun {
+			prefixID, err = target.ID(prefix, true)
+			if err != nil {
+				log.Fatalf("Error creating label %s: %v", prefix, err)
+			}
+		}
+		type migrateResult struct {
+			SourceID  string   `json:"sourceId"`
+			MessageID string   `json:"messageId,omitempty"`
+			TargetID  string   `json:"targetId,omitempty"`
+			Labels    []string `json:"labels,omitempty"`
+			Status    string   `json:"status"`
+			Error     string   `json:"error,omitempty"`
+		}
+		threads := gsmhelpers.MaxThreads(0)
+		messages, listErr := gsmgmail.ListMessages(sourceUser, flags["q"].GetString(), "messages(id),nextPageToken", flags["labelIds"].GetStringSlice(), flags["includeSpamTrash"].GetBool(), threads)
+		results := make(chan *migrateResult, threads)
+		// seen contains the Message-IDs imported in this run, so that duplicates within the source mailbox are not imported twice.
+		// Messages with the same Message-ID are migrated one after another (see messageIDLocks).
+		seen := make(map[string]bool)
+		messageIDLocks := make(map[string]*sync.Mutex)
+		var mu sync.Mutex
+		markSeen := func(key string) {
+			if key != "" {
+				mu.Lock()
+				seen[key] = true
+				mu.Unlock()
+			}
+		}
+		migrate := func(m *gmail.Message) *migrateResult {
+			r := &migrateResult{SourceID: m.Id}
+			fail := func(err error) *migrateResult {
+				log.Printf("Error migrating message %s: %v", m.Id, err)
+				r.Status = "failed"
+				r.Error = err.Error()
+				return r
+			}
+			meta, err := gsmgmail.GetMessage(sourceUser, m.Id, "METADATA", "Message-ID", "labelIds,payload/headers")
+			if err != nil {
+				return fail(err)
+			}
+			if gsmhelpers.Contains("DRAFT", meta.LabelIds) || gsmhelpers.Contains("CHAT", meta.LabelIds) {
+				r.Status = "skipped"
+				return r
+			}
+			r.MessageID = messageHeader(meta, "Message-ID")
+			var key string
+			if r.MessageID != "" {
+				key = strings.ToLower(r.MessageID)
+				mu.Lock()
+				l, ok := messageIDLocks[key]
+				if !ok {
+					l = &sync.Mutex{}
+					messageIDLocks[key] = l
+				}
+				mu.Unlock()
+				l.Lock()
+				defer l.Unlock()
+				mu.Lock()
+				duplicate := seen[key]
+				mu.Unlock()
+				if !duplicate {
+					duplicate, err = messageIDExists(targetUser, r.MessageID)
+					if err != nil {
+						return fail(err)
+					}
+				}
+				if duplicate {
+					r.Status = "duplicate"
+					return r
+				}
+			}
+			var labelIds []string
+			if prefixID != "" {
+				labelIds = append(labelIds, prefixID)
+			}
+			for _, id := range meta.LabelIds {
+				name, system := migrateLabelName(id, sourceLabels)
+				if name == "" {
+					continue
+				}
+				if !system && prefix != "" {
+					name = prefix + "/" + name
+				}
+				r.Labels = append(r.Labels, name)
+				if dryRun {
+					continue
+				}
+				if system {
+					labelIds = append(labelIds, id)
+					continue
+				}
+				id, err = target.ID(name, true)
+				if err != nil {
+					return fail(fmt.Errorf("error creating label: %v", err))
+				}
+				labelIds = append(labelIds, id)
+			}
+			if dryRun {
+				markSeen(key)
+				r.Status = "wouldMigrate"
+				return r
+			}
+			raw, err := gsmgmail.GetMessage(sourceUser, m.Id, "RAW", "", "raw")
+			if err != nil {
+				return fail(err)
+			}
+			result, err := gsmgmail.ImportMessage(targetUser, internalDateSource, "id", &gmail.Message{Raw: raw.Raw, LabelIds: labelIds}, false, neverMarkSpam, false)
+			if err != nil {
+				return fail(err)
+			}
+			markSeen(key)
+			r.TargetID = result.Id
+			r.Status = "migrated"
+			return r
+		}
+		var wg sync.WaitGroup
+		for range threads {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for m := range messages {
+					results <- migrate(m)
+				}
+			}()
+		}
+		go func() {
+			wg.Wait()
+			close(results)
+		}()
+		if streamOutput {
+			enc := gsmhelpers.GetJSONEncoder(false)
+			for r := range results {
+				err := enc.Encode(r)
+				if err != nil {
+					log.Println(err)
+				}
+			}
+		} else {
+			final := []*migrateResult{}
+			for r := range results {
+				final = append(final, r)
+			}
+			err := gsmhelpers.Output(final, "json", compressOutput)
+			if err != nil {
+				log.Fatalln(err)
+			}
+		}
+		if e := <-listErr; e != nil {
+			log.Fatalf("Error listing messages: %v", e)
+		}
+	},
+}
+
+// messageHeader returns the value of the first header with the given name
+func messageHeader(m *gmail.Message, name string) string {
+	if m.Payload == nil {
+		return ""
+	}
+	for _, h := range m.Payload.Headers {
+		if strings.EqualFold(h.Name, name) {
+			return strings.TrimSpace(h.Value)
+		}
+	}
+	return ""
+}
+
+// messageIDExists checks if the user's mailbox (including spam and trash) contains a message with the given Message-ID header
+func messageIDExists(userID, messageID string) (bool, error) {
+	q := "rfc822msgid:" + strings.Trim(messageID, "<>")
+	messages, err := gsmgmail.ListMessages(userID, q, "messages(id),nextPageToken", nil, true, 1)
+	exists := false
+	for range messages {
+		exists = true
+	}
+	return exists, <-err
+}
+
+// migrateLabelName returns the name of a source label that should be applied in the target mailbox.
+// System labels that describe the state of a message (e.g. UNREAD, STARRED, INBOX) are returned with system set to true.
+// An empty name is returned for labels that can't be applied to imported messages.
+func migrateLabelName(id string, labels []*gmail.Label) (name string, system bool) {
+	for _, l := range labels {
+		if l.Id != id {
+			continue
+		}
+		if l.Type == "system" {
+			if id == "DRAFT" || id == "CHAT" {
+				return "", true
+			}
+			return id, true
+		}
+		return l.Name, false
+	}
+	return "", false
+}
@@ -256,6 +256,16 @@ func auth() {
 	}
 	gsmadmin.SetClient(client)
 	gsmgmail.SetClient(client)
+	switch mode {
+	case "dwd":
+		gsmgmail.SetSubjectClientFunc(func(s string) (*http.Client, error) {
+			return gsmauth.GetClient(s, credentials, viper.GetStringSlice("scopes")...)
+		})
+	case "adc":
+		gsmgmail.SetSubjectClientFunc(func(s string) (*http.Client, error) {
+			return gsmauth.GetClientADC(s, viper.GetString("serviceAccount"), viper.GetStringSlice("scopes")...)
+		})
+	}
 	gsmci.SetClient(client)
 	gsmdrive.SetClient(client)
 	gsmgroupssettings.SetClient(client)
@@ -46,16 +46,35 @@ Implements the API documented at https://developers.google.com/workspace/gmail/a
 
 var sendAsFlags map[string]*gsmhelpers.Flag = map[string]*gsmhelpers.Flag{
 	"userId": {
-		AvailableFor: []string{"create", "delete", "get", "list", "patch", "verify"},
+		AvailableFor: []string{"create", "delete", "get", "list", "patch", "setSignature", "verify"},
 		Type:         "string",
 		Description:  "The user's email address. The special value me can be used to indicate the authenticated user.",
-		Defaults:     map[string]any{"create": "me", "delete": "me", "get": "me", "list": "me", "patch": "me", "verify": "me"},
+		Defaults:     map[string]any{"create": "me", "delete": "me", "get": "me", "list": "me", "patch": "me", "setSignature": "me", "verify": "me"},
 	},
 	"sendAsEmail": {
-		AvailableFor: []string{"create", "delete", "get", "patch", "verify"},
+		AvailableFor: []string{"create", "delete", "get", "patch", "setSignature", "verify"},
 		Type:         "string",
-		Description:  `The email address that appears in the "From:" header for mail sent using this alias.`,
-		Required:     []string{"create", "delete", "patch", "verify"},
+		Description: `The email address that appears in the "From:" header for mail sent using this alias.
+For setSignature, the signature of the user's primary address is set if this is not set.`,
+		Required:  []string{"create", "delete", "patch", "verify"},
+		Recursive: []string{"setSignature"},
+	},
+	"template": {
+		AvailableFor: []string{"setSignature"},
+		Type:         "string",
+		Description: `Path to an HTML template file (see https://pkg.go.dev/html/template) for the signature.
+The template can use the following fields of the user:
+{{.primaryEmail}}, {{.fullName}}, {{.givenName}}, {{.familyName}}, {{.title}}, {{.department}}, {{.costCenter}},
+{{.employeeId}}, {{.workPhone}}, {{.mobilePhone}}, {{.manager}}, {{.orgUnitPath}}, {{.sendAsEmail}}, {{.displayName}},
+custom schema fields, e.g. {{.customSchemas.Employment.Location}} and the complete user object, e.g. {{.user.ThumbnailPhotoUrl}}.`,
+		Required:  []string{"setSignature"},
+		Recursive: []string{"setSignature"},
+	},
+	"dryRun": {
+		AvailableFor: []string{"setSignature"},
+		Type:         "bool",
+		Description:  `Only render the signatures and show which users would be updated.`,
+		Recursive:    []string{"setSignature"},
 	},
 	"displayName": {
 		AvailableFor: []string{"create", "patch"},
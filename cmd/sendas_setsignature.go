@@ -0,0 +1,129 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"strings"
+
+	"github.com/hanneshayashi/gsm/gsmadmin"
+	"github.com/hanneshayashi/gsm/gsmgmail"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/api/gmail/v1"
+)
+
+// sendAsSetSignatureCmd represents the setSignature command
+var sendAsSetSignatureCmd = &cobra.Command{
+	Use:   "setSignature",
+	Short: "Sets the signature of a send-as alias from a template and the user's directory data",
+	Long: `Renders an HTML template with the user's data from the directory (name, title, phone numbers, department, custom schema fields, etc.)
+and sets the result as the signature of the user's primary address (or the address specified by --sendAsEmail).
+Scripts, styles, forms, event handlers and comments are removed from the rendered signature.
+Users whose current signature already matches the rendered signature are not updated.
+Example: gsm sendAs setSignature --userId user@example.org --template signature.html
+
+Implements the APIs documented at:
+https://developers.google.com/workspace/admin/directory/reference/rest/v1/users/get
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.settings.sendAs/patch`,
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := gsmhelpers.FlagsToMap(cmd.Flags())
+		tmpl, err := gsmgmail.ParseSignatureTemplate(flags["template"].GetString())
+		if err != nil {
+			log.Fatalf("Error parsing template: %v", err)
+		}
+		result := setSignature(flags["userId"].GetString(), flags["sendAsEmail"].GetString(), tmpl, flags["dryRun"].GetBool())
+		err = gsmhelpers.Output(result, "json", compressOutput)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+type setSignatureResult struct {
+	UserID      string `json:"userId"`
+	SendAsEmail string `json:"sendAsEmail,omitempty"`
+	Status      string `json:"status"`
+	Signature   string `json:"signature,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// setSignature renders the signature template for a user and sets it for the send-as address (or the primary address, if sendAsEmail is empty)
+func setSignature(userID, sendAsEmail string, tmpl *htmltemplate.Template, dryRun bool) *setSignatureResult {
+	r := &setSignatureResult{UserID: userID, SendAsEmail: sendAsEmail, Status: "failed"}
+	fail := func(err error) *setSignatureResult {
+		log.Printf("Error setting signature for %s: %v", userID, err)
+		r.Error = err.Error()
+		return r
+	}
+	if !strings.Contains(userID, "@") {
+		profile, err := gsmgmail.GetUserProfile(userID, "emailAddress")
+		if err != nil {
+			return fail(err)
+		}
+		userID = profile.EmailAddress
+		r.UserID = userID
+	}
+	user, err := gsmadmin.GetUser(userID, "", "full", "", "admin_view")
+	if err != nil {
+		return fail(fmt.Errorf("error getting user: %v", err))
+	}
+	sendAs, err := gsmgmail.ListSendAs(userID, "sendAs(sendAsEmail,displayName,isPrimary,signature)")
+	if err != nil {
+		return fail(fmt.Errorf("error listing send-as addresses: %v", err))
+	}
+	var alias *gmail.SendAs
+	for _, s := range sendAs {
+		if sendAsEmail == "" && s.IsPrimary || sendAsEmail != "" && strings.EqualFold(s.SendAsEmail, sendAsEmail) {
+			alias = s
+			break
+		}
+	}
+	if alias == nil {
+		return fail(fmt.Errorf("send-as address %s not found", sendAsEmail))
+	}
+	r.SendAsEmail = alias.SendAsEmail
+	data := gsmadmin.UserTemplateData(user)
+	data["sendAsEmail"] = alias.SendAsEmail
+	data["displayName"] = alias.DisplayName
+	r.Signature, err = gsmgmail.RenderSignature(tmpl, data)
+	if err != nil {
+		return fail(fmt.Errorf("error rendering signature: %v", err))
+	}
+	switch {
+	case gsmgmail.SignatureEqual(alias.Signature, r.Signature):
+		r.Status = "unchanged"
+	case dryRun:
+		r.Status = "wouldUpdate"
+	default:
+		_, err = gsmgmail.PatchSendAs(userID, alias.SendAsEmail, "sendAsEmail", &gmail.SendAs{Signature: r.Signature})
+		if err != nil {
+			return fail(err)
+		}
+		r.Status = "updated"
+	}
+	return r
+}
+
+func init() {
+	gsmhelpers.InitCommand(sendAsCmd, sendAsSetSignatureCmd, sendAsFlags)
+}
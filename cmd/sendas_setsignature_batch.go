@@ -0,0 +1,103 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	htmltemplate "html/template"
+	"log"
+	"sync"
+
+	"github.com/hanneshayashi/gsm/gsmgmail"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+)
+
+// sendAsSetSignatureBatchCmd represents the batch command
+var sendAsSetSignatureBatchCmd = &cobra.Command{
+	Use:   "batch",
+	Short: "Batch sets signatures from templates using a CSV file as input.",
+	Long: `Example: gsm sendAs setSignature batch --path users.csv --userId 1 --template_ALL signature.html
+Implements the API documented at https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.settings.sendAs/patch`,
+	Annotations: map[string]string{
+		"crescendoAttachToParent": "true",
+	},
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		maps, err := gsmhelpers.GetBatchMaps(cmd, sendAsFlags)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		// Templates are only parsed once, even if they are used for many lines
+		templates := make(map[string]*htmltemplate.Template)
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		cap := cap(maps)
+		results := make(chan *setSignatureResult, cap)
+		go func() {
+			for i := 0; i < cap; i++ {
+				wg.Add(1)
+				go func() {
+					for m := range maps {
+						path := m["template"].GetString()
+						var err error
+						mu.Lock()
+						tmpl, ok := templates[path]
+						if !ok {
+							tmpl, err = gsmgmail.ParseSignatureTemplate(path)
+							if err == nil {
+								templates[path] = tmpl
+							}
+						}
+						mu.Unlock()
+						if err != nil {
+							log.Printf("Error parsing template %s: %v\n", path, err)
+							continue
+						}
+						results <- setSignature(m["userId"].GetString(), m["sendAsEmail"].GetString(), tmpl, m["dryRun"].GetBool())
+					}
+					wg.Done()
+				}()
+			}
+			wg.Wait()
+			close(results)
+		}()
+		if streamOutput {
+			enc := gsmhelpers.GetJSONEncoder(false)
+			for r := range results {
+				err := enc.Encode(r)
+				if err != nil {
+					log.Println(err)
+				}
+			}
+		} else {
+			final := []*setSignatureResult{}
+			for res := range results {
+				final = append(final, res)
+			}
+			err := gsmhelpers.Output(final, "json", compressOutput)
+			if err != nil {
+				log.Fatalln(err)
+			}
+		}
+	},
+}
+
+func init() {
+	gsmhelpers.InitBatchCommand(sendAsSetSignatureCmd, sendAsSetSignatureBatchCmd, sendAsFlags, sendAsFlagsALL, batchFlags)
+}
@@ -42,10 +42,33 @@ var sharedContactsCmd = &cobra.Command{
 
 var sharedContactFlags map[string]*gsmhelpers.Flag = map[string]*gsmhelpers.Flag{
 	"domain": {
-		AvailableFor: []string{"create", "delete", "get", "list"},
+		AvailableFor: []string{"create", "delete", "export", "get", "import", "list"},
 		Type:         "string",
 		Description:  "DNS domain of the shared contact",
-		Required:     []string{"create", "delete", "get", "list"},
+		Required:     []string{"create", "delete", "export", "get", "import", "list"},
+	},
+	"file": {
+		AvailableFor: []string{"import"},
+		Type:         "string",
+		Description:  "Path to a vCard (.vcf) file containing one or more vCards (version 3.0 or 4.0).",
+		Required:     []string{"import"},
+	},
+	"update": {
+		AvailableFor: []string{"import"},
+		Type:         "bool",
+		Description: `Update existing shared contacts that share an email address with a vCard.
+By default, these vCards are skipped.`,
+	},
+	"dryRun": {
+		AvailableFor: []string{"import"},
+		Type:         "bool",
+		Description:  "Only report which shared contacts would be created or updated.",
+	},
+	"vCardVersion": {
+		AvailableFor: []string{"export"},
+		Type:         "string",
+		Description:  "vCard version to export [3.0|4.0].",
+		Defaults:     map[string]any{"export": "3.0"},
 	},
 	"givenName": {
 		AvailableFor: []string{"create", "update"},
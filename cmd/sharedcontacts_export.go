@@ -0,0 +1,55 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"log"
+	"os"
+
+	"github.com/hanneshayashi/gsm/gsmadmin"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+)
+
+// sharedContactsExportCmd represents the export command
+var sharedContactsExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Exports all Domain Shared Contacts as vCards",
+	Long: `Writes all shared contacts of the domain to stdout as vCards (version 3.0 or 4.0).
+The id of each shared contact is exported as the UID of the vCard.
+Example: gsm sharedContacts export --domain "example.org" --vCardVersion 4.0 > contacts.vcf
+
+Implements the API documented at https://developers.google.com/workspace/admin/domain-shared-contacts/get-shared-contacts#get_all_shared_contacts`,
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := gsmhelpers.FlagsToMap(cmd.Flags())
+		entries, err := gsmadmin.ListSharedContacts(flags["domain"].GetString())
+		if err != nil {
+			log.Fatalf("Error listing shared contacts: %v", err)
+		}
+		err = gsmadmin.WriteVCards(os.Stdout, entries, flags["vCardVersion"].GetString())
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+func init() {
+	gsmhelpers.InitCommand(sharedContactsCmd, sharedContactsExportCmd, sharedContactFlags)
+}
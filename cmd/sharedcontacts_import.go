@@ -0,0 +1,167 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"log"
+	"os"
+	"sync"
+
+	"github.com/hanneshayashi/gsm/gsmadmin"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+)
+
+// sharedContactsImportCmd represents the import command
+var sharedContactsImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Imports Domain Shared Contacts from a vCard file",
+	Long: `Creates a shared contact for every vCard in the file.
+Names, organizations, email addresses, phone numbers, addresses, websites and notes are imported.
+vCards that share an email address with an existing shared contact (or with a previous vCard in the file) are skipped,
+unless --update is set, in which case the existing contact is updated with the properties of the vCard.
+vCards without an email address can't be de-duplicated and are always created.
+Example: gsm sharedContacts import --domain "example.org" --file contacts.vcf --update
+
+Implements the APIs documented at:
+https://developers.google.com/workspace/admin/domain-shared-contacts/create-shared-contacts
+https://developers.google.com/workspace/admin/domain-shared-contacts/update-delete-shared-contacts#update_a_shared_contact`,
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := gsmhelpers.FlagsToMap(cmd.Flags())
+		domain := flags["domain"].GetString()
+		f, err := os.Open(flags["file"].GetString())
+		if err != nil {
+			log.Fatalf("Error opening vCard file: %v", err)
+		}
+		cards, err := gsmadmin.ParseVCards(f)
+		gsmhelpers.CloseLog(f, "vCardFile")
+		if err != nil {
+			log.Fatalf("Error parsing vCard file: %v", err)
+		}
+		existing, err := gsmadmin.ListSharedContacts(domain)
+		if err != nil {
+			log.Fatalf("Error listing shared contacts: %v", err)
+		}
+		byEmail := make(map[string]*gsmadmin.Entry)
+		for i := range existing {
+			for _, e := range gsmadmin.SharedContactEmails(&existing[i]) {
+				byEmail[e] = &existing[i]
+			}
+		}
+		type importResult struct {
+			FullName string   `json:"fullName,omitempty"`
+			Emails   []string `json:"emails,omitempty"`
+			Status   string   `json:"status"`
+			URL      string   `json:"url,omitempty"`
+			Error    string   `json:"error,omitempty"`
+		}
+		type job struct {
+			result *importResult
+			entry  *gsmadmin.Entry
+			url    string
+		}
+		update := flags["update"].GetBool()
+		dryRun := flags["dryRun"].GetBool()
+		seen := make(map[string]bool)
+		matched := make(map[*gsmadmin.Entry]bool)
+		results := make([]*importResult, 0, len(cards))
+		var jobs []*job
+		for _, card := range cards {
+			emails := gsmadmin.SharedContactEmails(card)
+			r := &importResult{FullName: card.Name.FullName, Emails: emails}
+			results = append(results, r)
+			var match *gsmadmin.Entry
+			duplicate := false
+			for _, e := range emails {
+				if seen[e] {
+					duplicate = true
+				}
+				if match == nil {
+					match = byEmail[e]
+				}
+			}
+			for _, e := range emails {
+				seen[e] = true
+			}
+			if match != nil {
+				duplicate = duplicate || matched[match]
+				matched[match] = true
+			}
+			switch {
+			case duplicate:
+				r.Status = "duplicate"
+			case match == nil:
+				r.Status = "wouldCreate"
+				jobs = append(jobs, &job{result: r, entry: card})
+			case !update:
+				r.Status = "exists"
+				r.URL = gsmadmin.SharedContactURL(match)
+			default:
+				r.Status = "wouldUpdate"
+				r.URL = gsmadmin.SharedContactURL(match)
+				gsmadmin.MergeSharedContact(match, card)
+				jobs = append(jobs, &job{result: r, entry: match, url: r.URL})
+			}
+		}
+		if !dryRun {
+			jobChan := make(chan *job)
+			var wg sync.WaitGroup
+			for range gsmhelpers.MaxThreads(0) {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := range jobChan {
+						var result *gsmadmin.Entry
+						var err error
+						if j.url == "" {
+							result, err = gsmadmin.CreateSharedContact(domain, j.entry)
+							j.result.Status = "created"
+						} else {
+							result, err = gsmadmin.UpdateSharedContact(j.url, j.entry)
+							j.result.Status = "updated"
+						}
+						if err != nil {
+							log.Println(err)
+							j.result.Status = "failed"
+							j.result.Error = err.Error()
+							continue
+						}
+						if j.url == "" {
+							j.result.URL = gsmadmin.SharedContactURL(result)
+						}
+					}
+				}()
+			}
+			for _, j := range jobs {
+				jobChan <- j
+			}
+			close(jobChan)
+			wg.Wait()
+		}
+		err = gsmhelpers.Output(results, "json", compressOutput)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+func init() {
+	gsmhelpers.InitCommand(sharedContactsCmd, sharedContactsImportCmd, sharedContactFlags)
+}
@@ -45,10 +45,10 @@ var smimeInfoCmd = &cobra.Command{
 
 var smimeInfoFlags map[string]*gsmhelpers.Flag = map[string]*gsmhelpers.Flag{
 	"userId": {
-		AvailableFor: []string{"delete", "get", "insert", "list", "setDefault"},
+		AvailableFor: []string{"delete", "expiryReport", "get", "insert", "list", "setDefault", "sync"},
 		Type:         "string",
 		Description:  "The user's email address. The special value me can be used to indicate the authenticated user.",
-		Defaults:     map[string]any{"delete": "me", "get": "me", "insert": "me", "list": "me", "setDefault": "me"},
+		Defaults:     map[string]any{"delete": "me", "expiryReport": "me", "get": "me", "insert": "me", "list": "me", "setDefault": "me", "sync": "me"},
 	},
 	"sendAsEmail": {
 		AvailableFor: []string{"delete", "get", "insert", "list", "setDefault"},
@@ -64,9 +64,40 @@ var smimeInfoFlags map[string]*gsmhelpers.Flag = map[string]*gsmhelpers.Flag{
 		ExcludeFromAll: true,
 	},
 	"encryptedKeyPassword": {
-		AvailableFor: []string{"insert"},
+		AvailableFor: []string{"insert", "sync"},
+		Type:         "string",
+		Description: `Encrypted key password, when key is encrypted.
+For sync, the password is also used to read the certificates locally and must be the same for all files.`,
+		Recursive: []string{"sync"},
+	},
+	"certDir": {
+		AvailableFor: []string{"sync"},
 		Type:         "string",
-		Description:  `Encrypted key password, when key is encrypted.`,
+		Description: `Path to a directory (including subdirectories) with PKCS#12 files (.p12 or .pfx).
+Files named after a send-as address (e.g. alice@example.org.p12) are used for that address.
+Other files are used for the email addresses in the certificate.
+The email address of the certificate must match the send-as address.`,
+		Required:  []string{"sync"},
+		Recursive: []string{"sync"},
+	},
+	"keepExpired": {
+		AvailableFor: []string{"sync"},
+		Type:         "bool",
+		Description:  `Don't delete expired S/MIME configs of the user's send-as addresses.`,
+		Recursive:    []string{"sync"},
+	},
+	"dryRun": {
+		AvailableFor: []string{"sync"},
+		Type:         "bool",
+		Description:  `Only show which certificates would be uploaded, set as default or deleted.`,
+		Recursive:    []string{"sync"},
+	},
+	"days": {
+		AvailableFor: []string{"expiryReport"},
+		Type:         "int",
+		Description:  `Report certificates that expire within this number of days (including certificates that are already expired).`,
+		Defaults:     map[string]any{"expiryReport": 30},
+		Recursive:    []string{"expiryReport"},
 	},
 	"pkcs12": {
 		AvailableFor: []string{"insert"},
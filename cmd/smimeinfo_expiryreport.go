@@ -0,0 +1,100 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"log"
+	"time"
+
+	"github.com/hanneshayashi/gsm/gsmgmail"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+)
+
+// smimeInfoExpiryReportCmd represents the expiryReport command
+var smimeInfoExpiryReportCmd = &cobra.Command{
+	Use:   "expiryReport",
+	Short: "Lists the S/MIME configs of a user's send-as addresses that expire within the given number of days.",
+	Long: `Example: gsm smimeInfo expiryReport --userId alice@example.org --days 60
+Implements the APIs documented at:
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.settings.sendAs/list
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.settings.sendAs.smimeInfo/list`,
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := gsmhelpers.FlagsToMap(cmd.Flags())
+		results := smimeExpiryReport(flags["userId"].GetString(), flags["days"].GetInt())
+		err := gsmhelpers.Output(results, "json", compressOutput)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+type smimeExpiryResult struct {
+	UserID      string `json:"userId"`
+	SendAsEmail string `json:"sendAsEmail,omitempty"`
+	ID          string `json:"id,omitempty"`
+	IssuerCn    string `json:"issuerCn,omitempty"`
+	IsDefault   bool   `json:"isDefault,omitempty"`
+	Expiration  string `json:"expiration,omitempty"`
+	DaysLeft    int    `json:"daysLeft"`
+	Expired     bool   `json:"expired"`
+	Error       string `json:"error,omitempty"`
+}
+
+// smimeExpiryReport returns the S/MIME configs of the user's send-as addresses that expire within the given number of days
+func smimeExpiryReport(userID string, days int) []*smimeExpiryResult {
+	results := []*smimeExpiryResult{}
+	sendAs, err := gsmgmail.ListSendAs(userID, "sendAs(sendAsEmail)")
+	if err != nil {
+		log.Printf("Error listing send-as addresses for user %s: %v", userID, err)
+		return append(results, &smimeExpiryResult{UserID: userID, Error: err.Error()})
+	}
+	now := time.Now()
+	limit := now.AddDate(0, 0, days).UnixMilli()
+	for _, s := range sendAs {
+		infos, err := gsmgmail.ListSmimeInfo(userID, s.SendAsEmail, "smimeInfo(id,issuerCn,expiration,isDefault)")
+		if err != nil {
+			log.Printf("Error listing S/MIME configs of %s for user %s: %v", s.SendAsEmail, userID, err)
+			results = append(results, &smimeExpiryResult{UserID: userID, SendAsEmail: s.SendAsEmail, Error: err.Error()})
+			continue
+		}
+		for _, i := range infos {
+			if i.Expiration > limit {
+				continue
+			}
+			expiration := time.UnixMilli(i.Expiration)
+			results = append(results, &smimeExpiryResult{
+				UserID:      userID,
+				SendAsEmail: s.SendAsEmail,
+				ID:          i.Id,
+				IssuerCn:    i.IssuerCn,
+				IsDefault:   i.IsDefault,
+				Expiration:  expiration.Format(time.RFC3339),
+				DaysLeft:    int(expiration.Sub(now).Hours() / 24),
+				Expired:     expiration.Before(now),
+			})
+		}
+	}
+	return results
+}
+
+func init() {
+	gsmhelpers.InitCommand(smimeInfoCmd, smimeInfoExpiryReportCmd, smimeInfoFlags)
+}
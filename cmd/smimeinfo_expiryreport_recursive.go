@@ -0,0 +1,87 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"log"
+	"sync"
+
+	"github.com/hanneshayashi/gsm/gsmadmin"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+)
+
+// smimeInfoExpiryReportRecursiveCmd represents the recursive command
+var smimeInfoExpiryReportRecursiveCmd = &cobra.Command{
+	Use:   "recursive",
+	Short: "Lists the S/MIME configs of users' send-as addresses that expire within the given number of days by referencing one or more organizational units and/or groups.",
+	Long: `Example: gsm smimeInfo expiryReport recursive --orgUnit / --days 30
+Implements the APIs documented at:
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.settings.sendAs/list
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.settings.sendAs.smimeInfo/list`,
+	Annotations: map[string]string{
+		"crescendoAttachToParent": "true",
+	},
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := gsmhelpers.FlagsToMap(cmd.Flags())
+		days := flags["days"].GetInt()
+		threads := gsmhelpers.MaxThreads(flags["batchThreads"].GetInt())
+		results := make(chan *smimeExpiryResult, threads)
+		var wg sync.WaitGroup
+		userKeysUnique, _ := gsmadmin.GetUniqueUsersChannelRecursive(flags["orgUnit"].GetStringSlice(), flags["groupEmail"].GetStringSlice(), threads)
+		go func() {
+			for i := 0; i < threads; i++ {
+				wg.Add(1)
+				go func() {
+					for uk := range userKeysUnique {
+						for _, r := range smimeExpiryReport(uk, days) {
+							results <- r
+						}
+					}
+					wg.Done()
+				}()
+			}
+			wg.Wait()
+			close(results)
+		}()
+		if streamOutput {
+			enc := gsmhelpers.GetJSONEncoder(false)
+			for r := range results {
+				err := enc.Encode(r)
+				if err != nil {
+					log.Println(err)
+				}
+			}
+		} else {
+			final := []*smimeExpiryResult{}
+			for r := range results {
+				final = append(final, r)
+			}
+			err := gsmhelpers.Output(final, "json", compressOutput)
+			if err != nil {
+				log.Fatalln(err)
+			}
+		}
+	},
+}
+
+func init() {
+	gsmhelpers.InitRecursiveCommand(smimeInfoExpiryReportCmd, smimeInfoExpiryReportRecursiveCmd, smimeInfoFlags, recursiveUserFlags)
+}
@@ -0,0 +1,235 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hanneshayashi/gsm/gsmgmail"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+)
+
+// smimeInfoSyncCmd represents the sync command
+var smimeInfoSyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Uploads the certificates from a directory for a user's send-as addresses.",
+	Long: `Matches the PKCS#12 files in --certDir to the send-as addresses of the user and checks the email address and expiry of the certificates.
+For each send-as address, the newest valid certificate is uploaded (unless it already exists) and set as the default S/MIME config.
+Expired S/MIME configs are deleted from all send-as addresses (including addresses without a valid certificate in --certDir), unless --keepExpired is set.
+Files that can't be decoded (e.g. because of a wrong password) or that don't match their file name are reported as "failed".
+Example: gsm smimeInfo sync --userId alice@example.org --certDir ./certs --encryptedKeyPassword secret --dryRun
+
+Implements the APIs documented at:
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.settings.sendAs.smimeInfo/list
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.settings.sendAs.smimeInfo/insert
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.settings.sendAs.smimeInfo/setDefault
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.settings.sendAs.smimeInfo/delete`,
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := gsmhelpers.FlagsToMap(cmd.Flags())
+		password := flags["encryptedKeyPassword"].GetString()
+		certs, results, err := readSmimeCertDir(flags["certDir"].GetString(), password)
+		if err != nil {
+			log.Fatalf("Error reading certificates: %v", err)
+		}
+		results = append(results, syncSmimeInfo(flags["userId"].GetString(), certs, password, flags["keepExpired"].GetBool(), flags["dryRun"].GetBool())...)
+		err = gsmhelpers.Output(results, "json", compressOutput)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+type smimeSyncResult struct {
+	UserID      string   `json:"userId,omitempty"`
+	SendAsEmail string   `json:"sendAsEmail,omitempty"`
+	File        string   `json:"file,omitempty"`
+	Expiration  string   `json:"expiration,omitempty"`
+	Actions     []string `json:"actions,omitempty"`
+	Status      string   `json:"status"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// readSmimeCertDir reads all PKCS#12 files in the directory and returns the certificates by (lower case) email address.
+// Files that can't be read or whose name doesn't match the email address of the certificate are returned as failed results.
+func readSmimeCertDir(dir, password string) (map[string][]*gsmgmail.SmimeCertificate, []*smimeSyncResult, error) {
+	certs := make(map[string][]*gsmgmail.SmimeCertificate)
+	failed := []*smimeSyncResult{}
+	fail := func(path, msg string) {
+		log.Printf("Skipping %s: %s", path, msg)
+		failed = append(failed, &smimeSyncResult{File: path, Status: "failed", Error: msg})
+	}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if d.IsDir() || (ext != ".p12" && ext != ".pfx") {
+			return nil
+		}
+		cert, err := gsmgmail.ReadSmimeCertificate(path, password)
+		if err != nil {
+			fail(path, err.Error())
+			return nil
+		}
+		emails := cert.Emails
+		if name := strings.ToLower(strings.TrimSuffix(d.Name(), filepath.Ext(path))); strings.Contains(name, "@") {
+			if !gsmgmail.HasSmimeEmail(cert.Emails, name) {
+				fail(path, fmt.Sprintf("the certificate is issued for %s, not %s", strings.Join(cert.Emails, ", "), name))
+				return nil
+			}
+			emails = []string{name}
+		}
+		if len(emails) == 0 {
+			fail(path, "the certificate doesn't contain an email address")
+			return nil
+		}
+		for _, e := range emails {
+			certs[e] = append(certs[e], cert)
+		}
+		return nil
+	})
+	return certs, failed, err
+}
+
+// syncSmimeInfo uploads the newest valid certificate for each send-as address of the user that has certificates,
+// sets it as the default and deletes expired S/MIME configs of all send-as addresses (unless keepExpired is set)
+func syncSmimeInfo(userID string, certs map[string][]*gsmgmail.SmimeCertificate, password string, keepExpired, dryRun bool) []*smimeSyncResult {
+	sendAs, err := gsmgmail.ListSendAs(userID, "sendAs(sendAsEmail)")
+	if err != nil {
+		log.Printf("Error listing send-as addresses for user %s: %v", userID, err)
+		return []*smimeSyncResult{{UserID: userID, Status: "failed", Error: err.Error()}}
+	}
+	results := []*smimeSyncResult{}
+	now := time.Now()
+	for _, s := range sendAs {
+		r := syncSmimeSendAs(userID, s.SendAsEmail, certs[strings.ToLower(s.SendAsEmail)], password, keepExpired, dryRun, now)
+		if r != nil {
+			results = append(results, r)
+		}
+	}
+	return results
+}
+
+// syncSmimeSendAs syncs the S/MIME configs of a single send-as address.
+// It returns nil if the address has no certificates and nothing had to be done.
+func syncSmimeSendAs(userID, sendAsEmail string, candidates []*gsmgmail.SmimeCertificate, password string, keepExpired, dryRun bool, now time.Time) *smimeSyncResult {
+	r := &smimeSyncResult{UserID: userID, SendAsEmail: sendAsEmail}
+	var cert *gsmgmail.SmimeCertificate
+	for _, c := range candidates {
+		if !c.Expired(now) && (cert == nil || c.NotAfter.After(cert.NotAfter)) {
+			cert = c
+		}
+	}
+	switch {
+	case cert != nil:
+		r.File = cert.Path
+		r.Expiration = cert.NotAfter.Format(time.RFC3339)
+	case len(candidates) > 0:
+		r.File = candidates[0].Path
+	}
+	skipped := func() *smimeSyncResult {
+		if len(candidates) == 0 {
+			return nil
+		}
+		r.Status = "skipped"
+		r.Error = "all certificates for this address are expired"
+		return r
+	}
+	// Without a valid certificate, the existing configs only need to be checked if expired configs are deleted
+	if cert == nil && keepExpired {
+		return skipped()
+	}
+	fail := func(err error) *smimeSyncResult {
+		log.Printf("Error syncing S/MIME config of %s for user %s: %v", sendAsEmail, userID, err)
+		r.Status = "failed"
+		r.Error = err.Error()
+		return r
+	}
+	infos, err := gsmgmail.ListSmimeInfo(userID, sendAsEmail, "smimeInfo(id,issuerCn,expiration,isDefault)")
+	if err != nil {
+		return fail(err)
+	}
+	var id string
+	if cert != nil {
+		isDefault := false
+		for _, i := range infos {
+			if cert.Uploaded(i) {
+				id = i.Id
+				isDefault = i.IsDefault
+				break
+			}
+		}
+		if id == "" {
+			r.Actions = append(r.Actions, "insert")
+			if !dryRun {
+				inserted, err := gsmgmail.InsertSmimeInfo(userID, sendAsEmail, "id", cert.SmimeInfo(password))
+				if err != nil {
+					return fail(err)
+				}
+				id = inserted.Id
+			}
+		}
+		if !isDefault {
+			r.Actions = append(r.Actions, "setDefault")
+			if !dryRun {
+				_, err = gsmgmail.SetDefaultSmimeInfo(userID, sendAsEmail, id)
+				if err != nil {
+					return fail(err)
+				}
+			}
+		}
+	}
+	if !keepExpired {
+		for _, i := range infos {
+			if (id != "" && i.Id == id) || i.Expiration > now.UnixMilli() {
+				continue
+			}
+			r.Actions = append(r.Actions, "delete:"+i.Id)
+			if dryRun {
+				continue
+			}
+			_, err = gsmgmail.DeleteSmimeInfo(userID, sendAsEmail, i.Id)
+			if err != nil {
+				return fail(fmt.Errorf("error deleting expired S/MIME config %s: %v", i.Id, err))
+			}
+		}
+	}
+	switch {
+	case len(r.Actions) > 0 && dryRun:
+		r.Status = "wouldUpdate"
+	case len(r.Actions) > 0:
+		r.Status = "updated"
+	case cert == nil:
+		return skipped()
+	default:
+		r.Status = "unchanged"
+	}
+	return r
+}
+
+func init() {
+	gsmhelpers.InitCommand(smimeInfoCmd, smimeInfoSyncCmd, smimeInfoFlags)
+}
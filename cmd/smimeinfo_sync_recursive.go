@@ -0,0 +1,101 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"log"
+	"sync"
+
+	"github.com/hanneshayashi/gsm/gsmadmin"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+)
+
+// smimeInfoSyncRecursiveCmd represents the recursive command
+var smimeInfoSyncRecursiveCmd = &cobra.Command{
+	Use:   "recursive",
+	Short: "Uploads the certificates from a directory for the send-as addresses of users by referencing one or more organizational units and/or groups.",
+	Long: `Example: gsm smimeInfo sync recursive --orgUnit /Legal --certDir ./certs --encryptedKeyPassword secret
+Implements the APIs documented at:
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.settings.sendAs.smimeInfo/list
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.settings.sendAs.smimeInfo/insert
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.settings.sendAs.smimeInfo/setDefault
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.settings.sendAs.smimeInfo/delete`,
+	Annotations: map[string]string{
+		"crescendoAttachToParent": "true",
+	},
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := gsmhelpers.FlagsToMap(cmd.Flags())
+		password := flags["encryptedKeyPassword"].GetString()
+		certs, failed, err := readSmimeCertDir(flags["certDir"].GetString(), password)
+		if err != nil {
+			log.Fatalf("Error reading certificates: %v", err)
+		}
+		keepExpired := flags["keepExpired"].GetBool()
+		dryRun := flags["dryRun"].GetBool()
+		threads := gsmhelpers.MaxThreads(flags["batchThreads"].GetInt())
+		results := make(chan *smimeSyncResult, threads)
+		var wg sync.WaitGroup
+		userKeysUnique, _ := gsmadmin.GetUniqueUsersChannelRecursive(flags["orgUnit"].GetStringSlice(), flags["groupEmail"].GetStringSlice(), threads)
+		go func() {
+			for i := 0; i < threads; i++ {
+				wg.Add(1)
+				go func() {
+					for uk := range userKeysUnique {
+						for _, r := range syncSmimeInfo(uk, certs, password, keepExpired, dryRun) {
+							results <- r
+						}
+					}
+					wg.Done()
+				}()
+			}
+			wg.Wait()
+			close(results)
+		}()
+		if streamOutput {
+			enc := gsmhelpers.GetJSONEncoder(false)
+			for _, r := range failed {
+				err := enc.Encode(r)
+				if err != nil {
+					log.Println(err)
+				}
+			}
+			for r := range results {
+				err := enc.Encode(r)
+				if err != nil {
+					log.Println(err)
+				}
+			}
+		} else {
+			final := failed
+			for r := range results {
+				final = append(final, r)
+			}
+			err := gsmhelpers.Output(final, "json", compressOutput)
+			if err != nil {
+				log.Fatalln(err)
+			}
+		}
+	},
+}
+
+func init() {
+	gsmhelpers.InitRecursiveCommand(smimeInfoSyncCmd, smimeInfoSyncRecursiveCmd, smimeInfoFlags, recursiveUserFlags)
+}
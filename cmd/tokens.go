@@ -61,6 +61,46 @@ The value can be the user's primary email address, alias email address, or uniqu
 See https://developers.google.com/gdata/docs/2.0/basics#PartialResponse for more information.`,
 		Recursive: []string{"list"},
 	},
+	"orgUnit": {
+		AvailableFor: []string{"inventory"},
+		Type:         "stringSlice",
+		Description: `Path of an orgUnit. Can be used multiple times. Note that an orgUnit always includes all of its children!
+If neither orgUnit nor groupEmail is set, all users of the customer are included.`,
+	},
+	"groupEmail": {
+		AvailableFor: []string{"inventory"},
+		Type:         "stringSlice",
+		Description:  `An email address of a group. Can be used multiple times. Note that a group will include recursive memberships!`,
+	},
+	"customer": {
+		AvailableFor: []string{"inventory"},
+		Type:         "string",
+		Description:  "The unique ID for the customer's Workspace account. Used if neither orgUnit nor groupEmail is set.",
+		Defaults:     map[string]any{"inventory": "my_customer"},
+	},
+	"allowlist": {
+		AvailableFor: []string{"inventory"},
+		Type:         "string",
+		Description: `Path to a file containing the client IDs of allowed applications (one per line).
+Empty lines and everything after a "#" are ignored.
+If set, the output contains a plan to revoke the tokens of all applications that are not allowed.`,
+	},
+	"highRiskOnly": {
+		AvailableFor: []string{"inventory"},
+		Type:         "bool",
+		Description:  `Only plan to revoke the tokens of applications that have been granted high-risk scopes.`,
+	},
+	"revoke": {
+		AvailableFor: []string{"inventory"},
+		Type:         "bool",
+		Description: `Execute the revoke plan, i.e. delete the tokens of all applications in the plan. Requires allowlist.
+You are asked for confirmation before any token is revoked, unless --confirm is used.`,
+	},
+	"confirm": {
+		AvailableFor: []string{"inventory"},
+		Type:         "bool",
+		Description:  "Execute the revoke plan without asking for confirmation.",
+	},
 }
 var tokenFlagsALL = gsmhelpers.GetAllFlags(tokenFlags)
 
@@ -0,0 +1,171 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/hanneshayashi/gsm/gsmadmin"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// tokensInventoryCmd represents the inventory command
+var tokensInventoryCmd = &cobra.Command{
+	Use:   "inventory",
+	Short: "Creates an inventory of the applications users have granted access to.",
+	Long: `Lists the tokens of all users (or the users in the specified orgUnits and groups) and aggregates them by the client ID of the application.
+For every application, the number of users and the granted scopes are reported.
+Scopes that grant full access to Gmail (https://mail.google.com/) or Drive (https://www.googleapis.com/auth/drive) and Admin SDK scopes are classified as high-risk.
+If an allowlist is specified, the output contains a plan to revoke the tokens of all other applications, which can be executed with --revoke.
+You are asked for confirmation before any token is revoked, unless --confirm is used.
+Implements the APIs documented at:
+https://developers.google.com/workspace/admin/directory/reference/rest/v1/tokens/list
+https://developers.google.com/workspace/admin/directory/reference/rest/v1/tokens/delete`,
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := gsmhelpers.FlagsToMap(cmd.Flags())
+		threads := gsmhelpers.MaxThreads(0)
+		var allowlist map[string]bool
+		var err error
+		if flags["allowlist"].IsSet() {
+			allowlist, err = gsmadmin.LoadTokenAllowlist(flags["allowlist"].GetString())
+			if err != nil {
+				log.Fatalf("Error loading allowlist: %v", err)
+			}
+		} else if flags["revoke"].GetBool() {
+			log.Fatalln("--revoke requires --allowlist")
+		}
+		orgUnits := flags["orgUnit"].GetStringSlice()
+		groupEmails := flags["groupEmail"].GetStringSlice()
+		var userKeys <-chan string
+		if len(orgUnits) == 0 && len(groupEmails) == 0 {
+			users, errChan := gsmadmin.ListUsers(false, "", "", flags["customer"].GetString(), "users(primaryEmail),nextPageToken", "", "", "", "", "", threads)
+			keys := make(chan string, threads)
+			go func() {
+				for u := range users {
+					keys <- u.PrimaryEmail
+				}
+				if e := <-errChan; e != nil {
+					log.Printf("Error listing users: %v\n", e)
+				}
+				close(keys)
+			}()
+			userKeys = keys
+		} else {
+			userKeys, _ = gsmadmin.GetUniqueUsersChannelRecursive(orgUnits, groupEmails, threads)
+		}
+		tokens := make(map[string][]*admin.Token)
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for range threads {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for uk := range userKeys {
+					t, err := gsmadmin.ListTokens(uk, "items(clientId,displayText,scopes,anonymous,nativeApp)")
+					if err != nil {
+						log.Println(err)
+						continue
+					}
+					mu.Lock()
+					tokens[uk] = t
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		type revocationStruct struct {
+			ClientID string `json:"clientId"`
+			UserKey  string `json:"userKey"`
+			Result   bool   `json:"result"`
+		}
+		type planStruct struct {
+			ClientID    string   `json:"clientId"`
+			DisplayName string   `json:"displayText,omitempty"`
+			HighRisk    bool     `json:"highRisk"`
+			Users       []string `json:"users"`
+		}
+		result := struct {
+			Users       int                  `json:"users"`
+			Apps        []*gsmadmin.TokenApp `json:"apps"`
+			Plan        []*planStruct        `json:"plan,omitempty"`
+			Revocations []*revocationStruct  `json:"revocations,omitempty"`
+		}{
+			Users: len(tokens),
+			Apps:  gsmadmin.AggregateTokens(tokens, allowlist),
+		}
+		if allowlist != nil {
+			highRiskOnly := flags["highRiskOnly"].GetBool()
+			for _, app := range result.Apps {
+				if app.Allowed || (highRiskOnly && !app.HighRisk) {
+					continue
+				}
+				result.Plan = append(result.Plan, &planStruct{ClientID: app.ClientID, DisplayName: app.DisplayName, HighRisk: app.HighRisk, Users: app.Users})
+			}
+		}
+		if flags["revoke"].GetBool() {
+			revocations := make(chan *revocationStruct, threads)
+			for _, p := range result.Plan {
+				for _, u := range p.Users {
+					result.Revocations = append(result.Revocations, &revocationStruct{ClientID: p.ClientID, UserKey: u})
+				}
+			}
+			if len(result.Revocations) > 0 && !flags["confirm"].GetBool() {
+				fmt.Fprintf(os.Stderr, "About to revoke %d tokens of %d applications. Type 'yes' to continue: ", len(result.Revocations), len(result.Plan))
+				answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+				if strings.TrimSpace(strings.ToLower(answer)) != "yes" {
+					log.Fatalln("Aborted")
+				}
+			}
+			for range threads {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for r := range revocations {
+						var err error
+						r.Result, err = gsmadmin.DeleteToken(r.UserKey, r.ClientID)
+						if err != nil {
+							log.Println(err)
+						}
+					}
+				}()
+			}
+			for _, r := range result.Revocations {
+				revocations <- r
+			}
+			close(revocations)
+			wg.Wait()
+		}
+		err = gsmhelpers.Output(result, "json", compressOutput)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+func init() {
+	gsmhelpers.InitCommand(tokensCmd, tokensInventoryCmd, tokenFlags)
+}
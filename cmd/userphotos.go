@@ -67,6 +67,54 @@ Allowed formats are: jpeg, png, gif, bmp and tiff.`,
 See https://developers.google.com/gdata/docs/2.0/basics#PartialResponse for more information.`,
 		Recursive: []string{"get", "update"},
 	},
+	"dir": {
+		AvailableFor: []string{"sync"},
+		Type:         "string",
+		Description: `Path to a directory containing the photos.
+Files are matched to users by their name without the extension, which can be the user's email address (or an alias) or the local part of the email address.
+Allowed formats are: jpeg, png and gif.`,
+		Required: []string{"sync"},
+	},
+	"customer": {
+		AvailableFor: []string{"sync"},
+		Type:         "string",
+		Description:  "The unique ID for the customer's Workspace account that is used to look up users.",
+		Defaults:     map[string]any{"sync": "my_customer"},
+	},
+	"domain": {
+		AvailableFor: []string{"sync"},
+		Type:         "string",
+		Description:  "Only match files to users in this domain.",
+	},
+	"query": {
+		AvailableFor: []string{"sync"},
+		Type:         "string",
+		Description: `Only match files to users that match this query.
+See https://developers.google.com/workspace/admin/directory/v1/guides/search-users`,
+	},
+	"maxDimension": {
+		AvailableFor: []string{"sync"},
+		Type:         "int",
+		Description:  "Photos with a width or height larger than this value (in pixels) are scaled down.",
+		Defaults:     map[string]any{"sync": 512},
+	},
+	"force": {
+		AvailableFor: []string{"sync"},
+		Type:         "bool",
+		Description:  "Update all photos, even if the current photo is identical.",
+	},
+	"stateFile": {
+		AvailableFor: []string{"sync"},
+		Type:         "string",
+		Description: `Path to a JSON file that caches the hash of the photo that was last uploaded for each user and the ETag of the resulting user photo.
+It is used to detect unchanged photos if the directory stores the photo in a different encoding.
+Defaults to ".gsm-photo-sync.json" in dir.`,
+	},
+	"dryRun": {
+		AvailableFor: []string{"sync"},
+		Type:         "bool",
+		Description:  "Only report which photos would be updated.",
+	},
 }
 var userPhotoFlagsALL = gsmhelpers.GetAllFlags(userPhotoFlags)
 
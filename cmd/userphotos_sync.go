@@ -0,0 +1,271 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hanneshayashi/gsm/gsmadmin"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// userPhotoMaxBytes is the maximum size of a photo that is uploaded by the sync command
+const userPhotoMaxBytes = 1 << 20
+
+// userPhotosSyncCmd represents the sync command
+var userPhotosSyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Updates the photos of users from a local directory.",
+	Long: `Matches the files in a directory to users by their file name and updates the users' photos.
+File names can be the user's email address (or an alias), e.g. "alice@example.com.png", or the local part of the email address, e.g. "alice.jpg".
+All photos are scaled down to maxDimension and converted to JPEG before they are uploaded.
+Before uploading, the current photo of the user is retrieved and compared to the converted photo. Users whose photo is identical are skipped, unless --force is used.
+Because the directory may store photos in a different encoding, the hash of every uploaded photo and the ETag of the resulting user photo are cached in a state file (see --stateFile).
+If neither the local file nor the photo in the directory have changed since the last sync, the user is skipped as well.
+Without the state file, photos that can't be compared directly are uploaded again.
+Files that can't be matched to exactly one user are reported as "unmatched" or "ambiguous".
+Implements the API documented at https://developers.google.com/workspace/admin/directory/reference/rest/v1/users.photos/update`,
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := gsmhelpers.FlagsToMap(cmd.Flags())
+		dir := flags["dir"].GetString()
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			log.Fatalf("Error reading directory: %v", err)
+		}
+		threads := gsmhelpers.MaxThreads(0)
+		users, errChan := gsmadmin.ListUsers(false, flags["query"].GetString(), flags["domain"].GetString(), flags["customer"].GetString(), "users(primaryEmail,aliases),nextPageToken", "", "", "", "", "", threads)
+		byEmail := make(map[string]string)
+		byLocalPart := make(map[string][]string)
+		for u := range users {
+			primaryEmail := strings.ToLower(u.PrimaryEmail)
+			for _, e := range append([]string{primaryEmail}, u.Aliases...) {
+				e = strings.ToLower(e)
+				byEmail[e] = primaryEmail
+				local := e[:strings.LastIndex(e, "@")+1]
+				if !gsmhelpers.Contains(primaryEmail, byLocalPart[local]) {
+					byLocalPart[local] = append(byLocalPart[local], primaryEmail)
+				}
+			}
+		}
+		if e := <-errChan; e != nil {
+			log.Fatalf("Error listing users: %v", e)
+		}
+		type resultStruct struct {
+			File       string   `json:"file"`
+			UserKey    string   `json:"userKey,omitempty"`
+			Status     string   `json:"status"`
+			Candidates []string `json:"candidates,omitempty"`
+			Error      string   `json:"error,omitempty"`
+		}
+		var results []*resultStruct
+		var matched []*resultStruct
+		claimed := make(map[string]string)
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			ext := strings.ToLower(filepath.Ext(entry.Name()))
+			if ext != ".jpg" && ext != ".jpeg" && ext != ".png" && ext != ".gif" {
+				continue
+			}
+			r := &resultStruct{File: entry.Name()}
+			results = append(results, r)
+			name := strings.ToLower(strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())))
+			var candidates []string
+			if strings.Contains(name, "@") {
+				if u, ok := byEmail[name]; ok {
+					candidates = []string{u}
+				}
+			} else {
+				candidates = byLocalPart[name+"@"]
+			}
+			switch {
+			case len(candidates) == 0:
+				r.Status = "unmatched"
+			case len(candidates) > 1:
+				r.Status = "ambiguous"
+				r.Candidates = candidates
+			case claimed[candidates[0]] != "":
+				r.Status = "duplicate"
+				r.UserKey = candidates[0]
+				r.Error = "user already matched by " + claimed[candidates[0]]
+			default:
+				r.UserKey = candidates[0]
+				claimed[r.UserKey] = r.File
+				matched = append(matched, r)
+			}
+		}
+		maxDimension := flags["maxDimension"].GetInt()
+		force := flags["force"].GetBool()
+		dryRun := flags["dryRun"].GetBool()
+		statePath := flags["stateFile"].GetString()
+		if statePath == "" {
+			statePath = filepath.Join(dir, ".gsm-photo-sync.json")
+		}
+		state, err := readUserPhotoSyncState(statePath)
+		if err != nil {
+			log.Fatalf("Error reading state file: %v", err)
+		}
+		var stateMutex sync.Mutex
+		work := make(chan *resultStruct, threads)
+		var wg sync.WaitGroup
+		for range threads {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for r := range work {
+					f, err := os.Open(filepath.Join(dir, r.File))
+					if err != nil {
+						r.Status, r.Error = "error", err.Error()
+						continue
+					}
+					data, err := gsmhelpers.NormalizeImageToJPEG(f, maxDimension, userPhotoMaxBytes, 90)
+					f.Close()
+					if err != nil {
+						r.Status, r.Error = "error", err.Error()
+						continue
+					}
+					sum := sha256.Sum256(data)
+					hash := hex.EncodeToString(sum[:])
+					if !force {
+						current, err := gsmadmin.GetUserPhoto(r.UserKey, "etag,photoData")
+						if err != nil && !gsmhelpers.IsNotFound(err) {
+							r.Status, r.Error = "error", err.Error()
+							continue
+						}
+						if err == nil {
+							stateMutex.Lock()
+							cached, found := state[r.UserKey]
+							stateMutex.Unlock()
+							if userPhotoHash(current) == hash || (found && cached.Hash == hash && cached.Etag == current.Etag) {
+								r.Status = "unchanged"
+								continue
+							}
+						}
+					}
+					if dryRun {
+						r.Status = "wouldUpdate"
+						continue
+					}
+					_, err = gsmadmin.UpdateUserPhoto(r.UserKey, "primaryEmail", &admin.UserPhoto{PhotoData: base64.RawURLEncoding.EncodeToString(data), MimeType: "image/jpeg"})
+					if err != nil {
+						r.Status, r.Error = "error", err.Error()
+						continue
+					}
+					r.Status = "updated"
+					// The ETag of the stored photo is cached, so that the next sync can detect changes made in the directory
+					updated, err := gsmadmin.GetUserPhoto(r.UserKey, "etag")
+					if err != nil {
+						log.Printf("Error getting the photo of %s after the update: %v\n", r.UserKey, err)
+						continue
+					}
+					stateMutex.Lock()
+					state[r.UserKey] = &userPhotoSyncState{Hash: hash, Etag: updated.Etag}
+					stateMutex.Unlock()
+				}
+			}()
+		}
+		for _, r := range matched {
+			work <- r
+		}
+		close(work)
+		wg.Wait()
+		if !dryRun {
+			err = writeUserPhotoSyncState(statePath, state)
+			if err != nil {
+				log.Printf("Error writing state file: %v\n", err)
+			}
+		}
+		sort.Slice(results, func(i, j int) bool {
+			return results[i].File < results[j].File
+		})
+		for _, r := range results {
+			if r.Error != "" {
+				log.Printf("%s: %s\n", r.File, r.Error)
+			}
+		}
+		err = gsmhelpers.Output(results, "json", compressOutput)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+// userPhotoSyncState is the cached state of a user's photo after it was uploaded by a sync
+type userPhotoSyncState struct {
+	// Hash is the SHA-256 hash of the uploaded (converted) photo
+	Hash string `json:"hash"`
+	// Etag is the ETag of the user photo after the upload
+	Etag string `json:"etag"`
+}
+
+// userPhotoHash returns the SHA-256 hash of the photo data of a user photo
+func userPhotoHash(photo *admin.UserPhoto) string {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(photo.PhotoData, "="))
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// readUserPhotoSyncState reads the state of the photos uploaded by previous syncs. A missing file results in an empty state.
+func readUserPhotoSyncState(path string) (map[string]*userPhotoSyncState, error) {
+	state := make(map[string]*userPhotoSyncState)
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, &state)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	return state, nil
+}
+
+// writeUserPhotoSyncState writes the state of the uploaded photos to the state file
+func writeUserPhotoSyncState(path string, state map[string]*userPhotoSyncState) error {
+	b, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return gsmhelpers.WriteFileAtomic(path, b)
+}
+
+func init() {
+	gsmhelpers.InitCommand(userPhotosCmd, userPhotosSyncCmd, userPhotoFlags)
+}
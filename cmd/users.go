@@ -18,12 +18,21 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 package cmd
 
 import (
+	"encoding/json"
+	"fmt"
 	"log"
+	"net/mail"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/hanneshayashi/gsm/gsmadmin"
 	"github.com/hanneshayashi/gsm/gsmhelpers"
 
 	"github.com/spf13/cobra"
 	admin "google.golang.org/api/admin/directory/v1"
+	"google.golang.org/api/googleapi"
 )
 
 // usersCmd represents the users command
@@ -42,11 +51,11 @@ var usersCmd = &cobra.Command{
 
 var userFlags map[string]*gsmhelpers.Flag = map[string]*gsmhelpers.Flag{
 	"userKey": {
-		AvailableFor: []string{"delete", "get", "makeAdmin", "update", "signOut", "undelete"},
+		AvailableFor: []string{"delete", "get", "makeAdmin", "rename", "setSchema", "update", "signOut", "undelete"},
 		Type:         "string",
 		Description: `Identifies the user in the API request.
 The value can be the user's primary email address, alias email address, or unique user ID.`,
-		Required:       []string{"delete", "get", "makeAdmin", "update", "signOut", "undelete"},
+		Required:       []string{"delete", "get", "makeAdmin", "rename", "setSchema", "update", "signOut", "undelete"},
 		ExcludeFromAll: true,
 	},
 	"customFieldMask": {
@@ -80,7 +89,7 @@ domain_public  - Results only include fields for the user that are publicly visi
 		Recursive: []string{"get"},
 	},
 	"fields": {
-		AvailableFor: []string{"get", "insert", "list", "update"},
+		AvailableFor: []string{"get", "insert", "list", "setSchema", "update"},
 		Type:         "string",
 		Description: `Fields allows partial responses to be retrieved.
 See https://developers.google.com/gdata/docs/2.0/basics#PartialResponse for more information.`,
@@ -346,7 +355,7 @@ text_html`,
 		Recursive:    []string{"update"},
 	},
 	"orgUnitPath": {
-		AvailableFor: []string{"insert", "update", "undelete"},
+		AvailableFor: []string{"import", "insert", "sync", "update", "undelete"},
 		Type:         "string",
 		Description: `The full path of the parent organization associated with the user.
 If the parent organization is the top-level, it is represented as a forward slash (/).`,
@@ -533,17 +542,17 @@ value       - The URL of the website.`,
 		Recursive: []string{"update"},
 	},
 	"customer": {
-		AvailableFor: []string{"list"},
+		AvailableFor: []string{"export", "list", "sync"},
 		Type:         "string",
 		Description: `The unique ID for the customer's Workspace account.
 In case of a multi-domain account, to fetch all groups for a customer, fill this field instead of domain.
 You can also use the my_customer alias to represent your account's customerId.
 The customerId is also returned as part of the Users resource.
 Either the customer or the domain parameter must be provided.`,
-		Defaults: map[string]any{"list": "my_customer"},
+		Defaults: map[string]any{"export": "my_customer", "list": "my_customer", "sync": "my_customer"},
 	},
 	"domain": {
-		AvailableFor: []string{"list"},
+		AvailableFor: []string{"export", "list", "sync"},
 		Type:         "string",
 		Description: `The domain name.
 Use this field to get fields from only one domain.
@@ -560,10 +569,150 @@ familyName  - User's family name.
 givenName   - User's given name.`,
 	},
 	"query": {
-		AvailableFor: []string{"list"},
+		AvailableFor: []string{"export", "list", "sync"},
 		Type:         "string",
 		Description: `Query string for searching user fields.
 For more information on constructing user queries, see https://developers.google.com/admin-sdk/directory/v1/guides/search-users`,
+	},
+	"format": {
+		AvailableFor: []string{"export"},
+		Type:         "string",
+		Description: `The format of the export.
+Acceptable values are:
+ldif  - LDAP Data Interchange Format (RFC 2849). Users are exported as inetOrgPerson entries.`,
+		Defaults: map[string]any{"export": "ldif"},
+	},
+	"attributeMap": {
+		AvailableFor: []string{"export", "import"},
+		Type:         "string",
+		Description: `Path to a YAML file that maps LDAP attributes to user properties.
+If set, the file replaces the default map, which is:
+cn: fullName
+sn: familyName
+givenName: givenName
+mail: primaryEmail
+telephoneNumber: workPhone
+mobile: mobilePhone
+title: title
+department: department
+employeeNumber: employeeId
+manager: manager
+memberOf: memberOf
+The following user properties are available:
+primaryEmail, fullName, givenName, familyName, workPhone, mobilePhone, title, department, costCenter, employeeId, manager, memberOf, orgUnitPath, recoveryEmail`,
+	},
+	"baseDn": {
+		AvailableFor: []string{"export"},
+		Type:         "string",
+		Description: `The base DN of the exported users. Users will be exported as "uid=<local part>,<baseDn>".
+If not set, the base DN is derived from the domain of each user (e.g. "dc=example,dc=com").`,
+	},
+	"groupBaseDn": {
+		AvailableFor: []string{"export"},
+		Type:         "string",
+		Description: `The base DN of the exported groups. Groups will be exported as "cn=<local part>,<groupBaseDn>".
+If not set, the base DN is derived from the domain of each group (e.g. "dc=example,dc=com").`,
+	},
+	"includeGroups": {
+		AvailableFor: []string{"export"},
+		Type:         "bool",
+		Description: `Include the group memberships of the users (memberOf) and export the groups as groupOfNames entries.
+Note that this requires an additional API call per user.`,
+	},
+	"ldif": {
+		AvailableFor: []string{"import"},
+		Type:         "string",
+		Description:  `Path to the LDIF file.`,
+		Required:     []string{"import"},
+	},
+	"dnDomain": {
+		AvailableFor: []string{"import"},
+		Type:         "string",
+		Description: `Domain used to resolve DNs that do not belong to an entry in the LDIF file.
+For example, with "--dnDomain example.com", "uid=jdoe,ou=people,dc=example,dc=org" is resolved to "jdoe@example.com".
+If not set, such DNs can not be resolved.`,
+	},
+	"dryRun": {
+		AvailableFor: []string{"import", "sync"},
+		Type:         "bool",
+		Description:  `Only show the changes that would be made, without applying them.`,
+	},
+	"source": {
+		AvailableFor: []string{"sync"},
+		Type:         "string",
+		Description: `Path to the HR export.
+Files with the extension ".json" must contain an array of flat objects.
+All other files are read as CSV files with a header.`,
+		Required: []string{"sync"},
+	},
+	"delimiter": {
+		AvailableFor: []string{"sync"},
+		Type:         "string",
+		Description:  "Delimiter to use for CSV columns. Must be exactly one character. Default is ';'",
+	},
+	"mapping": {
+		AvailableFor: []string{"sync"},
+		Type:         "string",
+		Description: `Path to a YAML file that maps the fields of the source to user properties and lists protected accounts, e.g.:
+attributes:
+  Email: primaryEmail
+  First Name: givenName
+  Last Name: familyName
+  Job Title: title
+  Department: department
+  Manager: manager
+  Phone: workPhone
+  OU: orgUnitPath
+protected:
+  - ceo@example.com
+  - /Service Accounts
+The following user properties are available:
+primaryEmail, fullName, givenName, familyName, workPhone, mobilePhone, title, department, costCenter, employeeId, manager, orgUnitPath, recoveryEmail
+Managers can be specified by their email address or by their employeeId (if employeeId is mapped).
+Protected accounts (email addresses or orgUnit paths including all children) are never changed.
+Super administrators and delegated administrators are always protected.`,
+		Required: []string{"sync"},
+	},
+	"maxSuspendPercent": {
+		AvailableFor: []string{"sync"},
+		Type:         "float64",
+		Description: `Abort the sync if more than this percentage of the active (not suspended and not protected) users in scope would be suspended.
+This protects against incomplete or empty source files.`,
+		Defaults: map[string]any{"sync": 5.0},
+	},
+	"clearEmpty": {
+		AvailableFor: []string{"sync"},
+		Type:         "bool",
+		Description:  `Clear properties that are empty in the source. By default, empty values are ignored.`,
+	},
+	"unsuspend": {
+		AvailableFor: []string{"sync"},
+		Type:         "bool",
+		Description:  `Unsuspend suspended users that are present in the source.`,
+	},
+	"newPrimaryEmail": {
+		AvailableFor: []string{"rename"},
+		Type:         "string",
+		Description: `The new primary email address of the user.
+The old primary email address is kept as an alias.`,
+		Required: []string{"rename"},
+	},
+	"skipSendAs": {
+		AvailableFor: []string{"rename"},
+		Type:         "bool",
+		Description: `Do not update the default send-as address of the user.
+Updating the send-as address requires access to the user's mailbox.`,
+	},
+	"skipReport": {
+		AvailableFor: []string{"rename"},
+		Type:         "bool",
+		Description:  `Do not report the groups and calendar ACLs of the user after the rename.`,
+	},
+	"calendarIds": {
+		AvailableFor: []string{"rename"},
+		Type:         "stringSlice",
+		Description: `IDs of calendars (e.g. shared or resource calendars) whose ACLs should be searched for rules that still refer to the old email address.
+Can be used multiple times.`,
 	},
 	"showDeleted": {
 		AvailableFor: []string{"list"},
@@ -584,6 +733,25 @@ DESCENDING  - Descending order.`,
 		Description:  `Use to remove admin access.`,
 		Recursive:    []string{"makeAdmin"},
 	},
+	"schemaValues": {
+		AvailableFor: []string{"setSchema"},
+		Type:         "stringArray",
+		Description: `A custom schema value in the form of "schemaName.fieldName=value".
+Can be used multiple times. Only the specified fields are changed.
+Multi-valued fields can be set by using the flag multiple times for the same field or by separating the values with ",".
+Each value of a multi-valued field may optionally specify a type in the form of "value=...;type=...;customType=...".
+When using the batch command, every column with a header in the form of "schemaName.fieldName" is treated as a custom schema value.
+Empty cells are ignored.`,
+	},
+	"readAccessType": {
+		AvailableFor: []string{"setSchema"},
+		Type:         "string",
+		Description: `If set, values are only written to fields with the specified read access type.
+Use this to make sure that no values are accidentally written to fields that are visible to the whole domain.
+Acceptable values are:
+ADMINS_AND_SELF   - Only administrators and the user can view the values.
+ALL_DOMAIN_USERS  - All users in the domain can view the values.`,
+	},
 }
 var userFlagsALL = gsmhelpers.GetAllFlags(userFlags)
 
@@ -815,3 +983,142 @@ func mapToUser(flags map[string]*gsmhelpers.Value) (*admin.User, error) {
 	}
 	return user, nil
 }
+
+// getSchemaFieldSpecs returns the field specifications of a custom schema by field name.
+// The schema is retrieved with GetSchema on first use and then taken from the cache.
+func getSchemaFieldSpecs(schemaName string, cache map[string]map[string]*admin.SchemaFieldSpec, mu *sync.Mutex) (map[string]*admin.SchemaFieldSpec, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if specs, found := cache[schemaName]; found {
+		return specs, nil
+	}
+	schema, err := gsmadmin.GetSchema("my_customer", schemaName, "schemaName,fields")
+	if err != nil {
+		return nil, err
+	}
+	specs := make(map[string]*admin.SchemaFieldSpec)
+	for i := range schema.Fields {
+		specs[schema.Fields[i].FieldName] = schema.Fields[i]
+	}
+	cache[schemaName] = specs
+	return specs, nil
+}
+
+// convertSchemaValue converts a string to the type of the custom schema field
+func convertSchemaValue(spec *admin.SchemaFieldSpec, value string) (any, error) {
+	switch spec.FieldType {
+	case "INT64":
+		return strconv.ParseInt(value, 10, 64)
+	case "DOUBLE":
+		return strconv.ParseFloat(value, 64)
+	case "BOOL":
+		return strconv.ParseBool(value)
+	case "DATE":
+		_, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a valid date (YYYY-MM-DD)", value)
+		}
+	case "EMAIL":
+		_, err := mail.ParseAddress(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a valid email address: %v", value, err)
+		}
+	}
+	return value, nil
+}
+
+// getCustomSchemaValues returns the raw custom schema values from the schemaValues flag and from CSV columns with a "schemaName.fieldName" header
+func getCustomSchemaValues(flags map[string]*gsmhelpers.Value) (map[string][]string, error) {
+	values := make(map[string][]string)
+	if flags["schemaValues"].IsSet() {
+		schemaValues := flags["schemaValues"].GetStringSlice()
+		for i := range schemaValues {
+			kv := strings.SplitN(schemaValues[i], "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("%s is not in the form of schemaName.fieldName=value", schemaValues[i])
+			}
+			values[kv[0]] = append(values[kv[0]], kv[1])
+		}
+	}
+	for k := range flags {
+		if strings.Contains(k, ".") && flags[k].IsSet() {
+			values[k] = append(values[k], flags[k].GetString())
+		}
+	}
+	return values, nil
+}
+
+// mapToCustomSchemas validates custom schema values against their schema definitions and converts them to the customSchemas property of a user
+func mapToCustomSchemas(flags map[string]*gsmhelpers.Value, cache map[string]map[string]*admin.SchemaFieldSpec, mu *sync.Mutex) (map[string]googleapi.RawMessage, error) {
+	values, err := getCustomSchemaValues(flags)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("no custom schema values specified")
+	}
+	readAccessType := flags["readAccessType"].GetString()
+	schemas := make(map[string]map[string]any)
+	for k := range values {
+		schemaName, fieldName, ok := strings.Cut(k, ".")
+		if !ok || schemaName == "" || fieldName == "" {
+			return nil, fmt.Errorf("%s is not in the form of schemaName.fieldName", k)
+		}
+		specs, err := getSchemaFieldSpecs(schemaName, cache, mu)
+		if err != nil {
+			return nil, err
+		}
+		spec, found := specs[fieldName]
+		if !found {
+			return nil, fmt.Errorf("schema %s has no field %s", schemaName, fieldName)
+		}
+		if readAccessType != "" && spec.ReadAccessType != readAccessType {
+			return nil, fmt.Errorf("%s has read access type %s, not %s", k, spec.ReadAccessType, readAccessType)
+		}
+		if schemas[schemaName] == nil {
+			schemas[schemaName] = make(map[string]any)
+		}
+		if !spec.MultiValued {
+			if len(values[k]) != 1 {
+				return nil, fmt.Errorf("%s is not a multi-valued field", k)
+			}
+			v, err := convertSchemaValue(spec, values[k][0])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", k, err)
+			}
+			schemas[schemaName][fieldName] = v
+			continue
+		}
+		multiValues := []map[string]any{}
+		for i := range values[k] {
+			for _, element := range strings.Split(values[k][i], ",") {
+				m := map[string]string{"value": element}
+				if strings.Contains(element, "value=") {
+					m = gsmhelpers.FlagToMap(element)
+				}
+				v, err := convertSchemaValue(spec, m["value"])
+				if err != nil {
+					return nil, fmt.Errorf("%s: %v", k, err)
+				}
+				mv := map[string]any{"value": v}
+				if m["type"] != "" {
+					mv["type"] = m["type"]
+				}
+				if m["customType"] != "" {
+					mv["customType"] = m["customType"]
+				}
+				multiValues = append(multiValues, mv)
+			}
+		}
+		schemas[schemaName][fieldName] = multiValues
+	}
+	customSchemas := make(map[string]googleapi.RawMessage)
+	for schemaName := range schemas {
+		raw, err := json.Marshal(schemas[schemaName])
+		if err != nil {
+			return nil, err
+		}
+		customSchemas[schemaName] = raw
+	}
+	return customSchemas, nil
+}
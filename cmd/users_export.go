@@ -0,0 +1,126 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"log"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/hanneshayashi/gsm/gsmadmin"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// usersExportCmd represents the export command
+var usersExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Exports users (and optionally their groups) to a file format that can be used by other directories",
+	Long: `The export is written to stdout.
+LDAP attributes are mapped to user properties with the default attribute map or a custom one (see --attributeMap).
+Example: gsm users export --format ldif --query "orgUnitPath=/Sales" --includeGroups > sales.ldif`,
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := gsmhelpers.FlagsToMap(cmd.Flags())
+		format := flags["format"].GetString()
+		if format != "ldif" {
+			log.Fatalf("Unsupported format %q. Must be 'ldif'", format)
+		}
+		attributeMap := gsmadmin.DefaultLDIFAttributeMap
+		var err error
+		if flags["attributeMap"].IsSet() {
+			attributeMap, err = gsmadmin.LoadLDIFAttributeMap(flags["attributeMap"].GetString())
+			if err != nil {
+				log.Fatalf("Error loading attribute map: %v", err)
+			}
+		}
+		baseDn := flags["baseDn"].GetString()
+		groupBaseDn := flags["groupBaseDn"].GetString()
+		userDN := func(email string) string {
+			return gsmadmin.EmailToDN(email, "uid", baseDn)
+		}
+		groupDN := func(email string) string {
+			return gsmadmin.EmailToDN(email, "cn", groupBaseDn)
+		}
+		threads := gsmhelpers.MaxThreads(0)
+		users, errChan := gsmadmin.ListUsers(false, flags["query"].GetString(), flags["domain"].GetString(), flags["customer"].GetString(), "", "", "", "", "", "", threads)
+		type groupInfo struct {
+			name    string
+			members []string
+		}
+		groups := make(map[string]*groupInfo)
+		var entries []*gsmhelpers.LDIFEntry
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		includeGroups := flags["includeGroups"].GetBool()
+		for i := 0; i < threads; i++ {
+			wg.Add(1)
+			go func() {
+				for u := range users {
+					var userGroups []string
+					if includeGroups {
+						gs, er := gsmadmin.ListGroups("", u.PrimaryEmail, "", "", "groups(email,name),nextPageToken", threads)
+						var memberOf []*admin.Group
+						for g := range gs {
+							memberOf = append(memberOf, g)
+						}
+						if e := <-er; e != nil {
+							log.Printf("Error listing groups of %s: %v\n", u.PrimaryEmail, e)
+						}
+						mu.Lock()
+						for _, g := range memberOf {
+							userGroups = append(userGroups, g.Email)
+							if groups[g.Email] == nil {
+								groups[g.Email] = &groupInfo{name: g.Name}
+							}
+							groups[g.Email].members = append(groups[g.Email].members, userDN(u.PrimaryEmail))
+						}
+						mu.Unlock()
+					}
+					entry := gsmadmin.UserToLDIFEntry(u, userGroups, attributeMap, userDN, groupDN)
+					mu.Lock()
+					entries = append(entries, entry)
+					mu.Unlock()
+				}
+				wg.Done()
+			}()
+		}
+		wg.Wait()
+		if e := <-errChan; e != nil {
+			log.Fatalf("Error listing users: %v", e)
+		}
+		gsmhelpers.SortLDIFEntries(entries)
+		var groupEntries []*gsmhelpers.LDIFEntry
+		for email := range groups {
+			sort.Strings(groups[email].members)
+			groupEntries = append(groupEntries, gsmadmin.GroupToLDIFEntry(email, groups[email].name, groups[email].members, groupDN))
+		}
+		gsmhelpers.SortLDIFEntries(groupEntries)
+		err = gsmhelpers.WriteLDIF(os.Stdout, append(entries, groupEntries...))
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+func init() {
+	gsmhelpers.InitCommand(usersCmd, usersExportCmd, userFlags)
+}
@@ -0,0 +1,213 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/hanneshayashi/gsm/gsmadmin"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// usersImportCmd represents the import command
+var usersImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Creates or updates users and their group memberships from an LDIF file",
+	Long: `Every entry that has a value for the attribute mapped to primaryEmail is imported as a user.
+Users that don't exist yet are created with a random password that must be changed at the next login.
+Existing users are updated. Note that list properties (phones, organizations, externalIds and relations) are replaced.
+Group memberships are read from the attribute mapped to memberOf of the users and from the "member" and "uniqueMember" attributes of group entries.
+DNs are resolved to email addresses by looking up the entry in the LDIF file. See --dnDomain for DNs that are not part of the file.
+Example: gsm users import --ldif export.ldif --orgUnitPath /Imported --dryRun`,
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := gsmhelpers.FlagsToMap(cmd.Flags())
+		attributeMap := gsmadmin.DefaultLDIFAttributeMap
+		var err error
+		if flags["attributeMap"].IsSet() {
+			attributeMap, err = gsmadmin.LoadLDIFAttributeMap(flags["attributeMap"].GetString())
+			if err != nil {
+				log.Fatalf("Error loading attribute map: %v", err)
+			}
+		}
+		f, err := os.Open(flags["ldif"].GetString())
+		if err != nil {
+			log.Fatalf("Error opening LDIF file: %v", err)
+		}
+		entries, err := gsmhelpers.ParseLDIF(f)
+		gsmhelpers.CloseLog(f, "ldif")
+		if err != nil {
+			log.Fatalf("Error parsing LDIF file: %v", err)
+		}
+		resolveDN := gsmadmin.NewLDIFDNResolver(entries, attributeMap, flags["dnDomain"].GetString())
+		type importResult struct {
+			DN            string      `json:"dn"`
+			Email         string      `json:"email,omitempty"`
+			Action        string      `json:"action,omitempty"`
+			User          *admin.User `json:"user,omitempty"`
+			AddedToGroups []string    `json:"addedToGroups,omitempty"`
+			AddedMembers  []string    `json:"addedMembers,omitempty"`
+			UnresolvedDNs []string    `json:"unresolvedDns,omitempty"`
+			Errors        []string    `json:"errors,omitempty"`
+		}
+		type membership struct {
+			group  string
+			member string
+			result *importResult
+		}
+		var results []*importResult
+		var users []*importResult
+		var memberships []membership
+		seen := make(map[string]bool)
+		addMembership := func(group, member string, result *importResult) {
+			k := strings.ToLower(group + "|" + member)
+			if !seen[k] {
+				seen[k] = true
+				memberships = append(memberships, membership{group: group, member: member, result: result})
+			}
+		}
+		emailAttribute := gsmadmin.MappedAttribute(attributeMap, "primaryEmail")
+		for i := range entries {
+			if entries[i].GetFirst(emailAttribute) != "" && !isLDIFGroup(entries[i]) {
+				user, groups, unresolved := gsmadmin.LDIFEntryToUser(entries[i], attributeMap, resolveDN)
+				r := &importResult{DN: entries[i].DN, Email: user.PrimaryEmail, User: user, UnresolvedDNs: unresolved}
+				for j := range groups {
+					addMembership(groups[j], user.PrimaryEmail, r)
+				}
+				results = append(results, r)
+				users = append(users, r)
+				continue
+			}
+			if !isLDIFGroup(entries[i]) {
+				continue
+			}
+			r := &importResult{DN: entries[i].DN, Email: resolveDN(entries[i].DN), Action: "memberships"}
+			if r.Email == "" {
+				r.Errors = append(r.Errors, "unable to resolve the group's email address")
+				results = append(results, r)
+				continue
+			}
+			for _, dn := range append(entries[i].Get("member"), entries[i].Get("uniqueMember")...) {
+				if email := resolveDN(dn); email != "" {
+					addMembership(r.Email, email, r)
+				} else {
+					r.UnresolvedDNs = append(r.UnresolvedDNs, dn)
+				}
+			}
+			results = append(results, r)
+		}
+		dryRun := flags["dryRun"].GetBool()
+		orgUnitPath := flags["orgUnitPath"].GetString()
+		threads := gsmhelpers.MaxThreads(0)
+		userResults := make(chan *importResult, threads)
+		var wg sync.WaitGroup
+		for i := 0; i < threads; i++ {
+			wg.Add(1)
+			go func() {
+				for r := range userResults {
+					_, err := gsmadmin.GetUser(r.Email, "primaryEmail", "", "", "")
+					switch {
+					case err == nil:
+						r.Action = "update"
+					case gsmhelpers.IsNotFound(err):
+						r.Action = "create"
+					default:
+						r.Errors = append(r.Errors, err.Error())
+						continue
+					}
+					if r.Action == "create" {
+						if r.User.Name == nil || r.User.Name.GivenName == "" || r.User.Name.FamilyName == "" {
+							r.Errors = append(r.Errors, "given name and family name are required to create a user")
+							continue
+						}
+						if r.User.OrgUnitPath == "" {
+							r.User.OrgUnitPath = orgUnitPath
+						}
+					}
+					if dryRun {
+						continue
+					}
+					var result *admin.User
+					if r.Action == "create" {
+						r.User.Password, err = gsmhelpers.RandomPassword(24)
+						if err != nil {
+							r.Errors = append(r.Errors, err.Error())
+							continue
+						}
+						r.User.ChangePasswordAtNextLogin = true
+						result, err = gsmadmin.InsertUser(r.User, "")
+					} else {
+						result, err = gsmadmin.UpdateUser(r.Email, "", r.User)
+					}
+					if err != nil {
+						r.Errors = append(r.Errors, err.Error())
+						continue
+					}
+					r.User = result
+				}
+				wg.Done()
+			}()
+		}
+		for i := range users {
+			userResults <- users[i]
+		}
+		close(userResults)
+		wg.Wait()
+		for i := range memberships {
+			m := memberships[i]
+			if !dryRun {
+				_, err := gsmadmin.InsertMember(m.group, "email", &admin.Member{Email: m.member, Role: "MEMBER"})
+				if err != nil && gsmhelpers.ErrorCode(err) != 409 {
+					m.result.Errors = append(m.result.Errors, err.Error())
+					continue
+				}
+			}
+			if m.result.Action == "memberships" {
+				m.result.AddedMembers = append(m.result.AddedMembers, m.member)
+			} else {
+				m.result.AddedToGroups = append(m.result.AddedToGroups, m.group)
+			}
+		}
+		err = gsmhelpers.Output(results, "json", compressOutput)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+// isLDIFGroup returns true if an LDIF entry represents a group
+func isLDIFGroup(entry *gsmhelpers.LDIFEntry) bool {
+	objectClasses := entry.Get("objectClass")
+	for i := range objectClasses {
+		switch strings.ToLower(objectClasses[i]) {
+		case "groupofnames", "groupofuniquenames", "group":
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	gsmhelpers.InitCommand(usersCmd, usersImportCmd, userFlags)
+}
@@ -0,0 +1,217 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hanneshayashi/gsm/gsmadmin"
+	"github.com/hanneshayashi/gsm/gsmcalendar"
+	"github.com/hanneshayashi/gsm/gsmgmail"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+	admin "google.golang.org/api/admin/directory/v1"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/gmail/v1"
+)
+
+type userRenameResult struct {
+	UserKey         string        `json:"userKey,omitempty"`
+	ID              string        `json:"id,omitempty"`
+	OldPrimaryEmail string        `json:"oldPrimaryEmail,omitempty"`
+	NewPrimaryEmail string        `json:"newPrimaryEmail,omitempty"`
+	Renamed         bool          `json:"renamed"`
+	Alias           string        `json:"alias,omitempty"`
+	DefaultSendAs   *gmail.SendAs `json:"defaultSendAs,omitempty"`
+	Groups          []string      `json:"groups,omitempty"`
+	// PrimaryCalendarACLs contains the ACL rules of the user's own primary calendar (i.e., who the user shares the calendar with)
+	PrimaryCalendarACLs []*calendar.AclRule `json:"primaryCalendarAcls,omitempty"`
+	// OldAddressCalendarACLs contains the ACL rules of the calendars specified with --calendarIds that grant access to the old email address
+	OldAddressCalendarACLs []*calendarACLMatch `json:"oldAddressCalendarAcls,omitempty"`
+	Errors                 []string            `json:"errors,omitempty"`
+}
+
+type calendarACLMatch struct {
+	CalendarID string            `json:"calendarId"`
+	Rule       *calendar.AclRule `json:"rule"`
+}
+
+// usersRenameCmd represents the rename command
+var usersRenameCmd = &cobra.Command{
+	Use:   "rename",
+	Short: "Changes the primary email address of a user and cleans up after the rename.",
+	Long: `Changes the primary email address of a user, makes sure that the old address is kept as an alias and updates the default send-as address.
+Afterwards, the groups of the user and the ACL rules of the user's own primary calendar (i.e., who the user shares their calendar with) are reported, so that memberships and sharing settings can be verified.
+Sharing settings of other calendars are not part of this report. Use --calendarIds to search the ACLs of specific calendars (e.g. shared or resource calendars) for rules that still refer to the old email address.
+Running the command again for a user that has already been renamed (using the old address as the userKey) only performs the follow-up steps.
+Updating the send-as address requires access to the user's mailbox. When using domain-wide delegation, the user is impersonated for this step.
+Errors in these steps are reported but do not undo the rename.
+Implements the APIs documented at:
+https://developers.google.com/workspace/admin/directory/reference/rest/v1/users/update
+https://developers.google.com/workspace/admin/directory/reference/rest/v1/users.aliases/insert
+https://developers.google.com/workspace/gmail/api/reference/rest/v1/users.settings.sendAs/patch
+https://developers.google.com/workspace/calendar/api/v3/reference/acl/list`,
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := gsmhelpers.FlagsToMap(cmd.Flags())
+		result, err := renameUser(flags["userKey"].GetString(), flags["newPrimaryEmail"].GetString(), flags["skipSendAs"].GetBool(), flags["skipReport"].GetBool(), flags["calendarIds"].GetStringSlice())
+		if err != nil {
+			log.Fatalf("Error renaming user: %v", err)
+		}
+		err = gsmhelpers.Output(result, "json", compressOutput)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+func init() {
+	gsmhelpers.InitCommand(usersCmd, usersRenameCmd, userFlags)
+}
+
+// renameUser changes the primary email address of a user and performs the follow-up steps.
+// An error is only returned if the rename itself fails. Errors in the follow-up steps are added to the result.
+func renameUser(userKey, newPrimaryEmail string, skipSendAs, skipReport bool, calendarIDs []string) (*userRenameResult, error) {
+	result := &userRenameResult{UserKey: userKey, NewPrimaryEmail: newPrimaryEmail}
+	user, err := gsmadmin.GetUser(userKey, "id,primaryEmail", "", "", "")
+	if err != nil {
+		return result, err
+	}
+	result.ID = user.Id
+	if strings.EqualFold(user.PrimaryEmail, newPrimaryEmail) {
+		if !strings.Contains(userKey, "@") || strings.EqualFold(userKey, newPrimaryEmail) {
+			return result, fmt.Errorf("%s is already the primary email address of the user. Use the old email address as the userKey to repeat the follow-up steps", newPrimaryEmail)
+		}
+		result.OldPrimaryEmail = userKey
+	} else {
+		result.OldPrimaryEmail = user.PrimaryEmail
+		_, err = gsmadmin.UpdateUser(user.Id, "id,primaryEmail", &admin.User{PrimaryEmail: newPrimaryEmail})
+		if err != nil {
+			return result, err
+		}
+		result.Renamed = true
+	}
+	err = ensureUserAlias(user.Id, result.OldPrimaryEmail)
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("Error adding alias: %v", err))
+	} else {
+		result.Alias = result.OldPrimaryEmail
+	}
+	if !skipSendAs {
+		result.DefaultSendAs, err = updateDefaultSendAs(newPrimaryEmail, result.OldPrimaryEmail)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("Error updating send-as: %v", err))
+		}
+	}
+	if skipReport {
+		return result, nil
+	}
+	groups, errs := gsmadmin.ListGroups("", user.Id, "", "", "groups(email),nextPageToken", gsmhelpers.MaxThreads(0))
+	for g := range groups {
+		result.Groups = append(result.Groups, g.Email)
+	}
+	if e := <-errs; e != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("Error listing groups: %v", e))
+	}
+	acls, errs := gsmcalendar.ListACLs(newPrimaryEmail, "items(id,role,scope),nextPageToken", false, gsmhelpers.MaxThreads(0))
+	for a := range acls {
+		result.PrimaryCalendarACLs = append(result.PrimaryCalendarACLs, a)
+	}
+	if e := <-errs; e != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("Error listing calendar ACLs: %v", e))
+	}
+	for _, calendarID := range calendarIDs {
+		acls, errs := gsmcalendar.ListACLs(calendarID, "items(id,role,scope),nextPageToken", false, gsmhelpers.MaxThreads(0))
+		for a := range acls {
+			if a.Scope != nil && strings.EqualFold(a.Scope.Value, result.OldPrimaryEmail) {
+				result.OldAddressCalendarACLs = append(result.OldAddressCalendarACLs, &calendarACLMatch{CalendarID: calendarID, Rule: a})
+			}
+		}
+		if e := <-errs; e != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("Error listing ACLs of calendar %s: %v", calendarID, e))
+		}
+	}
+	return result, nil
+}
+
+// ensureUserAlias adds alias to the user, unless it is already present
+func ensureUserAlias(userKey, alias string) error {
+	aliases, err := gsmadmin.ListUserAliases(userKey, "aliases(alias)")
+	if err != nil {
+		return err
+	}
+	if gsmadmin.AliasesContain(aliases, alias) {
+		return nil
+	}
+	_, err = gsmadmin.InsertUserAlias(userKey, "alias", &admin.Alias{Alias: alias})
+	if err != nil && gsmhelpers.ErrorCode(err) != 409 {
+		return err
+	}
+	return nil
+}
+
+// updateDefaultSendAs makes the primary send-as address of the user the default,
+// if the current default is the old primary email address.
+// The display name and signature of the old address are carried over, if the primary address doesn't have any.
+// When using domain-wide delegation, the user is impersonated for this step.
+func updateDefaultSendAs(userID, oldPrimaryEmail string) (*gmail.SendAs, error) {
+	sendAs, err := gsmgmail.ListSendAsAsUser(userID, "sendAs(sendAsEmail,displayName,signature,isDefault,isPrimary)")
+	if err != nil {
+		return nil, err
+	}
+	var primary, def, old *gmail.SendAs
+	for _, s := range sendAs {
+		if s.IsPrimary {
+			primary = s
+		}
+		if s.IsDefault {
+			def = s
+		}
+		if strings.EqualFold(s.SendAsEmail, oldPrimaryEmail) {
+			old = s
+		}
+	}
+	if primary == nil {
+		return nil, fmt.Errorf("no primary send-as address found")
+	}
+	if !strings.EqualFold(primary.SendAsEmail, userID) {
+		return nil, fmt.Errorf("primary send-as address is still %s. The change may not have propagated yet", primary.SendAsEmail)
+	}
+	if def != nil && def != old && def != primary {
+		return def, nil
+	}
+	patch := &gmail.SendAs{}
+	if !primary.IsDefault {
+		patch.IsDefault = true
+	}
+	if old != nil && old != primary {
+		if primary.DisplayName == "" {
+			patch.DisplayName = old.DisplayName
+		}
+		if primary.Signature == "" {
+			patch.Signature = old.Signature
+		}
+	}
+	if !patch.IsDefault && patch.DisplayName == "" && patch.Signature == "" {
+		return primary, nil
+	}
+	return gsmgmail.PatchSendAsAsUser(userID, primary.SendAsEmail, "sendAsEmail,displayName,signature,isDefault,isPrimary", patch)
+}
@@ -0,0 +1,88 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"log"
+	"sync"
+
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+)
+
+// usersRenameBatchCmd represents the batch command
+var usersRenameBatchCmd = &cobra.Command{
+	Use:   "batch",
+	Short: "Batch renames users using a CSV file as input.",
+	Long: `Changes the primary email address of users, makes sure that the old addresses are kept as aliases and updates the default send-as addresses.
+See "gsm users rename --help" for details.`,
+	Annotations: map[string]string{
+		"crescendoAttachToParent": "true",
+	},
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		maps, err := gsmhelpers.GetBatchMaps(cmd, userFlags)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		var wg sync.WaitGroup
+		cap := cap(maps)
+		results := make(chan *userRenameResult, cap)
+		go func() {
+			for i := 0; i < cap; i++ {
+				wg.Add(1)
+				go func() {
+					for m := range maps {
+						result, err := renameUser(m["userKey"].GetString(), m["newPrimaryEmail"].GetString(), m["skipSendAs"].GetBool(), m["skipReport"].GetBool(), m["calendarIds"].GetStringSlice())
+						if err != nil {
+							log.Println(err)
+							result.Errors = append(result.Errors, err.Error())
+						}
+						results <- result
+					}
+					wg.Done()
+				}()
+			}
+			wg.Wait()
+			close(results)
+		}()
+		if streamOutput {
+			enc := gsmhelpers.GetJSONEncoder(false)
+			for r := range results {
+				err := enc.Encode(r)
+				if err != nil {
+					log.Println(err)
+				}
+			}
+		} else {
+			final := []*userRenameResult{}
+			for res := range results {
+				final = append(final, res)
+			}
+			err := gsmhelpers.Output(final, "json", compressOutput)
+			if err != nil {
+				log.Fatalln(err)
+			}
+		}
+	},
+}
+
+func init() {
+	gsmhelpers.InitBatchCommand(usersRenameCmd, usersRenameBatchCmd, userFlags, userFlagsALL, batchFlags)
+}
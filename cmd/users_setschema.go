@@ -0,0 +1,58 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"log"
+	"sync"
+
+	"github.com/hanneshayashi/gsm/gsmadmin"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// usersSetSchemaCmd represents the setSchema command
+var usersSetSchemaCmd = &cobra.Command{
+	Use:   "setSchema",
+	Short: "Sets custom schema values for a user",
+	Long: `The values are validated against the schema definitions (field type, multi-valued, read access) before the user is updated.
+Only the specified fields are changed.
+Implements the API documented at https://developers.google.com/workspace/admin/directory/reference/rest/v1/users/update`,
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := gsmhelpers.FlagsToMap(cmd.Flags())
+		customSchemas, err := mapToCustomSchemas(flags, map[string]map[string]*admin.SchemaFieldSpec{}, &sync.Mutex{})
+		if err != nil {
+			log.Fatalf("Error building custom schema values: %v", err)
+		}
+		result, err := gsmadmin.UpdateUser(flags["userKey"].GetString(), flags["fields"].GetString(), &admin.User{CustomSchemas: customSchemas})
+		if err != nil {
+			log.Fatalf("Error updating user: %v", err)
+		}
+		err = gsmhelpers.Output(result, "json", compressOutput)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+func init() {
+	gsmhelpers.InitCommand(usersCmd, usersSetSchemaCmd, userFlags)
+}
@@ -0,0 +1,100 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"log"
+	"sync"
+
+	"github.com/hanneshayashi/gsm/gsmadmin"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// usersSetSchemaBatchCmd represents the batch command
+var usersSetSchemaBatchCmd = &cobra.Command{
+	Use:   "batch",
+	Short: "Batch sets custom schema values for users using a CSV file as input",
+	Long: `The first line of the CSV file must be a header.
+Every column with a header in the form of "schemaName.fieldName" is treated as a custom schema value.
+Empty cells are ignored.
+Implements the API documented at https://developers.google.com/workspace/admin/directory/reference/rest/v1/users/update`,
+	Annotations: map[string]string{
+		"crescendoAttachToParent": "true",
+	},
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		maps, err := gsmhelpers.GetBatchMapsWithHeader(cmd, userFlags)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		schemaCache := map[string]map[string]*admin.SchemaFieldSpec{}
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		cap := cap(maps)
+		results := make(chan *admin.User, cap)
+		go func() {
+			for i := 0; i < cap; i++ {
+				wg.Add(1)
+				go func() {
+					for m := range maps {
+						userKey := m["userKey"].GetString()
+						customSchemas, err := mapToCustomSchemas(m, schemaCache, &mu)
+						if err != nil {
+							log.Printf("Error building custom schema values for %s: %v\n", userKey, err)
+							continue
+						}
+						result, err := gsmadmin.UpdateUser(userKey, m["fields"].GetString(), &admin.User{CustomSchemas: customSchemas})
+						if err != nil {
+							log.Println(err)
+						} else {
+							results <- result
+						}
+					}
+					wg.Done()
+				}()
+			}
+			wg.Wait()
+			close(results)
+		}()
+		if streamOutput {
+			enc := gsmhelpers.GetJSONEncoder(false)
+			for r := range results {
+				err := enc.Encode(r)
+				if err != nil {
+					log.Println(err)
+				}
+			}
+		} else {
+			final := []*admin.User{}
+			for res := range results {
+				final = append(final, res)
+			}
+			err := gsmhelpers.Output(final, "json", compressOutput)
+			if err != nil {
+				log.Fatalln(err)
+			}
+		}
+	},
+}
+
+func init() {
+	gsmhelpers.InitBatchCommand(usersSetSchemaCmd, usersSetSchemaBatchCmd, userFlags, userFlagsALL, batchFlags)
+}
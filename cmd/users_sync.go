@@ -0,0 +1,209 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/hanneshayashi/gsm/gsmadmin"
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	"github.com/spf13/cobra"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// usersSyncCmd represents the sync command
+var usersSyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Synchronizes users with an HR export (CSV or JSON)",
+	Long: `Compares the records of the source to the users in scope (see --query and --domain) and
+  - creates users that are missing in the directory (with a random password that must be changed at the next login)
+  - updates the mapped properties of existing users that differ from the source
+  - suspends users that are not present in the source
+Source records of users that exist outside of the scope are reported as "outOfScope" and are not changed.
+Protected accounts are never changed (see --mapping).
+Use --dryRun to only output the plan. Every applied change is logged.
+Example: gsm users sync --source hr.csv --mapping mapping.yaml --query "orgUnitPath=/Employees" --dryRun`,
+	DisableAutoGenTag: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := gsmhelpers.FlagsToMap(cmd.Flags())
+		mapping, err := gsmadmin.LoadUserSyncMapping(flags["mapping"].GetString())
+		if err != nil {
+			log.Fatalf("Error loading mapping: %v", err)
+		}
+		sourcePath := flags["source"].GetString()
+		var records []map[string]string
+		if strings.HasSuffix(strings.ToLower(sourcePath), ".json") {
+			records, err = gsmhelpers.GetJSONRecords(sourcePath)
+		} else {
+			delimiter := ';'
+			if flags["delimiter"].IsSet() {
+				delimiter = flags["delimiter"].GetRune()
+			}
+			records, err = gsmhelpers.GetCSVRecords(sourcePath, delimiter)
+		}
+		if err != nil {
+			log.Fatalf("Error reading source: %v", err)
+		}
+		threads := gsmhelpers.MaxThreads(0)
+		usersChan, errChan := gsmadmin.ListUsers(false, flags["query"].GetString(), flags["domain"].GetString(), flags["customer"].GetString(), "", "", "", "", "", "", threads)
+		var users []*admin.User
+		for u := range usersChan {
+			users = append(users, u)
+		}
+		if e := <-errChan; e != nil {
+			log.Fatalf("Error listing users: %v", e)
+		}
+		plan := gsmadmin.PlanUserSync(records, users, mapping, flags["clearEmpty"].GetBool(), flags["unsuspend"].GetBool())
+		markOutOfScopeUsers(plan, threads)
+		type summary struct {
+			SourceRecords  int  `json:"sourceRecords"`
+			DirectoryUsers int  `json:"directoryUsers"`
+			Protected      int  `json:"protected"`
+			Active         int  `json:"active"`
+			OutOfScope     int  `json:"outOfScope"`
+			Create         int  `json:"create"`
+			Update         int  `json:"update"`
+			Suspend        int  `json:"suspend"`
+			Failed         int  `json:"failed"`
+			DryRun         bool `json:"dryRun"`
+		}
+		type resultStruct struct {
+			Summary summary                    `json:"summary"`
+			Changes []*gsmadmin.UserSyncChange `json:"changes"`
+			Errors  []string                   `json:"errors,omitempty"`
+		}
+		result := resultStruct{
+			Summary: summary{
+				SourceRecords:  plan.SourceRecords,
+				DirectoryUsers: plan.DirectoryUsers,
+				Protected:      plan.Protected,
+				Active:         plan.Active,
+				OutOfScope:     plan.Count("outOfScope"),
+				Create:         plan.Count("create"),
+				Update:         plan.Count("update"),
+				Suspend:        plan.Count("suspend"),
+				DryRun:         flags["dryRun"].GetBool(),
+			},
+			Changes: plan.Changes,
+			Errors:  plan.Errors,
+		}
+		// The ratio is calculated against the users that can actually be suspended, so that already suspended or protected users don't dilute it
+		active := plan.Active
+		maxSuspendPercent := flags["maxSuspendPercent"].GetFloat64()
+		if active > 0 && float64(result.Summary.Suspend)*100/float64(active) > maxSuspendPercent && !result.Summary.DryRun {
+			err = gsmhelpers.Output(result, "json", compressOutput)
+			if err != nil {
+				log.Println(err)
+			}
+			log.Fatalf("Aborting: %d of %d active users in scope would be suspended, which is more than %.1f%%. Check the source or increase --maxSuspendPercent", result.Summary.Suspend, active, maxSuspendPercent)
+		}
+		if !result.Summary.DryRun {
+			orgUnitPath := flags["orgUnitPath"].GetString()
+			changes := make(chan *gsmadmin.UserSyncChange, threads)
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			for i := 0; i < threads; i++ {
+				wg.Add(1)
+				go func() {
+					for c := range changes {
+						u := gsmadmin.UserSyncChangeToUser(c)
+						var er error
+						if c.Action == "create" {
+							if u.OrgUnitPath == "" {
+								u.OrgUnitPath = orgUnitPath
+							}
+							u.ChangePasswordAtNextLogin = true
+							u.Password, er = gsmhelpers.RandomPassword(24)
+							if er == nil {
+								_, er = gsmadmin.InsertUser(u, "primaryEmail")
+							}
+						} else {
+							_, er = gsmadmin.UpdateUser(c.PrimaryEmail, "primaryEmail", u)
+						}
+						if er != nil {
+							c.Error = er.Error()
+							log.Printf("Error applying change %s: %v\n", c, er)
+							mu.Lock()
+							result.Summary.Failed++
+							mu.Unlock()
+							continue
+						}
+						log.Printf("Applied change %s\n", c)
+					}
+					wg.Done()
+				}()
+			}
+			for _, c := range plan.Changes {
+				if c.Action == "outOfScope" {
+					continue
+				}
+				if c.Error != "" {
+					mu.Lock()
+					result.Summary.Failed++
+					mu.Unlock()
+					continue
+				}
+				changes <- c
+			}
+			close(changes)
+			wg.Wait()
+		}
+		err = gsmhelpers.Output(result, "json", compressOutput)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+func init() {
+	gsmhelpers.InitCommand(usersCmd, usersSyncCmd, userFlags)
+}
+
+// markOutOfScopeUsers checks if the users that are planned to be created already exist outside of the scope of the sync.
+// Creating these users would fail, so their changes are marked as "outOfScope" instead.
+func markOutOfScopeUsers(plan *gsmadmin.UserSyncPlan, threads int) {
+	changes := make(chan *gsmadmin.UserSyncChange, threads)
+	var wg sync.WaitGroup
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			for c := range changes {
+				_, err := gsmadmin.GetUser(c.PrimaryEmail, "primaryEmail", "", "", "")
+				if err == nil {
+					c.Action = "outOfScope"
+					c.Changes = nil
+					c.Error = ""
+				} else if !gsmhelpers.IsNotFound(err) {
+					c.Error = fmt.Sprintf("unable to check if the user exists: %v", err)
+				}
+			}
+			wg.Done()
+		}()
+	}
+	for _, c := range plan.Changes {
+		if c.Action == "create" {
+			changes <- c
+		}
+	}
+	close(changes)
+	wg.Wait()
+}
@@ -14,6 +14,7 @@ require (
 	golang.org/x/oauth2 v0.30.0
 	google.golang.org/api v0.246.0
 	gopkg.in/yaml.v3 v3.0.1
+	software.sslmate.com/src/go-pkcs12 v0.5.0
 )
 
 require (
@@ -0,0 +1,170 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gsmadmin
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+)
+
+// ManagedDomain is a domain or domain alias of a customer
+type ManagedDomain struct {
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+	ParentDomain string `json:"parentDomain,omitempty"`
+}
+
+// AddressCheckResult describes whether an email address is available and, if not, which object owns it
+type AddressCheckResult struct {
+	Address        string `json:"address"`
+	Available      bool   `json:"available"`
+	DomainType     string `json:"domainType,omitempty"`
+	Type           string `json:"type,omitempty"`
+	OwnerID        string `json:"ownerId,omitempty"`
+	OwnerEmail     string `json:"ownerEmail,omitempty"`
+	MatchedAddress string `json:"matchedAddress,omitempty"`
+	Duplicate      bool   `json:"duplicate,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// GetManagedDomains returns the domains and domain aliases of a customer, keyed by their (lower case) name
+func GetManagedDomains(customerID string) (map[string]*ManagedDomain, error) {
+	domains, err := ListDomains(customerID, "domains(domainName,isPrimary)")
+	if err != nil {
+		return nil, err
+	}
+	managed := make(map[string]*ManagedDomain)
+	for _, d := range domains {
+		t := "secondary"
+		if d.IsPrimary {
+			t = "primary"
+		}
+		name := strings.ToLower(d.DomainName)
+		managed[name] = &ManagedDomain{Name: name, Type: t}
+	}
+	aliases, err := ListDomainAliases(customerID, "", "domainAliases(domainAliasName,parentDomainName)")
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range aliases {
+		aliasName := strings.ToLower(a.DomainAliasName)
+		managed[aliasName] = &ManagedDomain{Name: aliasName, Type: "domainAlias", ParentDomain: strings.ToLower(a.ParentDomainName)}
+	}
+	return managed, nil
+}
+
+// AliasesContain returns true if the list of aliases returned by ListUserAliases or ListGroupAliases contains address
+func AliasesContain(aliases []any, address string) bool {
+	for i := range aliases {
+		a, ok := aliases[i].(map[string]any)
+		if !ok {
+			continue
+		}
+		if s, ok := a["alias"].(string); ok && strings.EqualFold(s, address) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAddressOwner looks for a user or group that owns address.
+// Returns false if the address is not used by any user or group.
+func checkAddressOwner(address string, result *AddressCheckResult) (bool, error) {
+	user, err := GetUser(address, "id,primaryEmail", "", "", "")
+	if err == nil {
+		result.Type = "user"
+		result.OwnerID = user.Id
+		result.OwnerEmail = user.PrimaryEmail
+		if !strings.EqualFold(user.PrimaryEmail, address) {
+			aliases, err := ListUserAliases(user.Id, "aliases(alias)")
+			if err != nil {
+				return true, err
+			}
+			if AliasesContain(aliases, address) {
+				result.Type = "userAlias"
+			} else {
+				result.Type = "userNonEditableAlias"
+			}
+		}
+		return true, nil
+	}
+	if !gsmhelpers.IsNotFound(err) {
+		return false, err
+	}
+	group, err := GetGroup(address, "id,email")
+	if err == nil {
+		result.Type = "group"
+		result.OwnerID = group.Id
+		result.OwnerEmail = group.Email
+		if !strings.EqualFold(group.Email, address) {
+			aliases, err := ListGroupAliases(group.Id, "aliases(alias)")
+			if err != nil {
+				return true, err
+			}
+			if AliasesContain(aliases, address) {
+				result.Type = "groupAlias"
+			} else {
+				result.Type = "groupNonEditableAlias"
+			}
+		}
+		return true, nil
+	}
+	if !gsmhelpers.IsNotFound(err) {
+		return false, err
+	}
+	return false, nil
+}
+
+// CheckAddress checks if an email address is already taken by a user, a group, a user alias or a group alias.
+// If the domain of the address is a domain alias, the corresponding address in the parent domain is checked as well.
+// Addresses in domains that are not managed by the customer are never available.
+func CheckAddress(address string, domains map[string]*ManagedDomain) *AddressCheckResult {
+	address = strings.ToLower(strings.TrimSpace(address))
+	result := &AddressCheckResult{Address: address}
+	at := strings.LastIndex(address, "@")
+	if at < 1 || at == len(address)-1 {
+		result.Error = fmt.Sprintf("%s is not a valid email address", address)
+		return result
+	}
+	domain, ok := domains[address[at+1:]]
+	if !ok {
+		result.DomainType = "unmanaged"
+		result.Error = fmt.Sprintf("%s is not a domain or domain alias of the customer", address[at+1:])
+		return result
+	}
+	result.DomainType = domain.Type
+	candidates := []string{address}
+	if domain.Type == "domainAlias" {
+		candidates = append(candidates, address[:at+1]+domain.ParentDomain)
+	}
+	for _, c := range candidates {
+		taken, err := checkAddressOwner(c, result)
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		if taken {
+			result.MatchedAddress = c
+			return result
+		}
+	}
+	result.Available = true
+	return result
+}
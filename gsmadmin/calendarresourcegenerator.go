@@ -0,0 +1,305 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gsmadmin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	admin "google.golang.org/api/admin/directory/v1"
+	"gopkg.in/yaml.v3"
+)
+
+// CalendarResourceRoom is a room in a calendar resource definition
+type CalendarResourceRoom struct {
+	Name                   string   `yaml:"name"`
+	Capacity               int64    `yaml:"capacity"`
+	Section                string   `yaml:"section"`
+	Features               []string `yaml:"features"`
+	Category               string   `yaml:"category"`
+	Type                   string   `yaml:"type"`
+	Description            string   `yaml:"description"`
+	UserVisibleDescription string   `yaml:"userVisibleDescription"`
+}
+
+// CalendarResourceFloor is a floor in a calendar resource definition
+type CalendarResourceFloor struct {
+	Name  string                  `yaml:"name"`
+	Rooms []*CalendarResourceRoom `yaml:"rooms"`
+}
+
+// CalendarResourceBuilding is a building in a calendar resource definition
+type CalendarResourceBuilding struct {
+	BuildingID string                   `yaml:"buildingId"`
+	Floors     []*CalendarResourceFloor `yaml:"floors"`
+}
+
+// CalendarResourceDefinition describes the rooms of one or more buildings, e.g.:
+//
+//	buildings:
+//	- buildingId: MUC1
+//	  floors:
+//	  - name: "3"
+//	    rooms:
+//	    - name: Alpspitze
+//	      capacity: 8
+//	      features: [Projector, Video conferencing]
+type CalendarResourceDefinition struct {
+	Buildings []*CalendarResourceBuilding `yaml:"buildings"`
+}
+
+// CalendarResourceTemplateData is passed to the name and ID templates of generated calendar resources
+type CalendarResourceTemplateData struct {
+	Building     string
+	BuildingName string
+	Floor        string
+	Section      string
+	Name         string
+	Capacity     int64
+	Category     string
+	Type         string
+}
+
+var calendarResourceSlugCharacters = regexp.MustCompile(`[^a-z0-9]+`)
+
+// calendarResourceTemplateFuncs are the functions that can be used in name and ID templates
+var calendarResourceTemplateFuncs = template.FuncMap{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"slug": func(s string) string {
+		return strings.Trim(calendarResourceSlugCharacters.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	},
+}
+
+// LoadCalendarResourceDefinition reads a calendar resource definition from a YAML (or JSON) file
+func LoadCalendarResourceDefinition(path string) (*CalendarResourceDefinition, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	definition := &CalendarResourceDefinition{}
+	err = yaml.Unmarshal(content, definition)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing definition %s: %v", path, err)
+	}
+	return definition, nil
+}
+
+// BuildingIDs returns the unique building IDs of the definition
+func (d *CalendarResourceDefinition) BuildingIDs() []string {
+	var ids []string
+	for _, b := range d.Buildings {
+		if !gsmhelpers.Contains(b.BuildingID, ids) {
+			ids = append(ids, b.BuildingID)
+		}
+	}
+	return ids
+}
+
+// FeatureNames returns the unique names of all features used in the definition
+func (d *CalendarResourceDefinition) FeatureNames() []string {
+	var names []string
+	for _, b := range d.Buildings {
+		for _, f := range b.Floors {
+			for _, r := range f.Rooms {
+				for _, n := range r.Features {
+					if !gsmhelpers.Contains(n, names) {
+						names = append(names, n)
+					}
+				}
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func executeCalendarResourceTemplate(t *template.Template, data *CalendarResourceTemplateData) (string, error) {
+	var buf bytes.Buffer
+	err := t.Execute(&buf, data)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// calendarResourceFeatureInstances returns the featureInstances property for the specified feature names
+func calendarResourceFeatureInstances(names []string) []map[string]any {
+	instances := make([]map[string]any, len(names))
+	for i := range names {
+		instances[i] = map[string]any{"feature": map[string]any{"name": names[i]}}
+	}
+	return instances
+}
+
+// CalendarResourceFeatureNames returns the sorted feature names of a calendar resource
+func CalendarResourceFeatureNames(r *admin.CalendarResource) []string {
+	if r.FeatureInstances == nil {
+		return nil
+	}
+	b, err := json.Marshal(r.FeatureInstances)
+	if err != nil {
+		return nil
+	}
+	var instances []struct {
+		Feature struct {
+			Name string `json:"name"`
+		} `json:"feature"`
+	}
+	if json.Unmarshal(b, &instances) != nil {
+		return nil
+	}
+	var names []string
+	for i := range instances {
+		names = append(names, instances[i].Feature.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GenerateCalendarResources validates the definition against the specified buildings and returns the calendar resources it describes.
+// nameTemplate and idTemplate are Go templates that are executed with a CalendarResourceTemplateData.
+// All validation errors are returned together.
+func GenerateCalendarResources(definition *CalendarResourceDefinition, buildings map[string]*admin.Building, nameTemplate, idTemplate string) ([]*admin.CalendarResource, error) {
+	nameT, err := template.New("name").Funcs(calendarResourceTemplateFuncs).Parse(nameTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing name template: %v", err)
+	}
+	idT, err := template.New("id").Funcs(calendarResourceTemplateFuncs).Parse(idTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing ID template: %v", err)
+	}
+	var errs []error
+	var resources []*admin.CalendarResource
+	ids := make(map[string]bool)
+	for _, b := range definition.Buildings {
+		building, ok := buildings[b.BuildingID]
+		if !ok {
+			errs = append(errs, fmt.Errorf("building %s does not exist", b.BuildingID))
+			continue
+		}
+		for _, f := range b.Floors {
+			if !gsmhelpers.Contains(f.Name, building.FloorNames) {
+				errs = append(errs, fmt.Errorf("floor %s does not exist in building %s. Floors: %s", f.Name, b.BuildingID, strings.Join(building.FloorNames, ", ")))
+				continue
+			}
+			for _, r := range f.Rooms {
+				data := &CalendarResourceTemplateData{
+					Building:     b.BuildingID,
+					BuildingName: building.BuildingName,
+					Floor:        f.Name,
+					Section:      r.Section,
+					Name:         r.Name,
+					Capacity:     r.Capacity,
+					Category:     r.Category,
+					Type:         r.Type,
+				}
+				resource := &admin.CalendarResource{
+					BuildingId:             b.BuildingID,
+					FloorName:              f.Name,
+					FloorSection:           r.Section,
+					Capacity:               r.Capacity,
+					ResourceCategory:       r.Category,
+					ResourceType:           r.Type,
+					ResourceDescription:    r.Description,
+					UserVisibleDescription: r.UserVisibleDescription,
+				}
+				if resource.ResourceCategory == "" {
+					resource.ResourceCategory = "CONFERENCE_ROOM"
+				}
+				features := slices.Clone(r.Features)
+				sort.Strings(features)
+				resource.FeatureInstances = calendarResourceFeatureInstances(features)
+				resource.ResourceName, err = executeCalendarResourceTemplate(nameT, data)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("error executing name template for room %s: %v", r.Name, err))
+					continue
+				}
+				resource.ResourceId, err = executeCalendarResourceTemplate(idT, data)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("error executing ID template for room %s: %v", r.Name, err))
+					continue
+				}
+				if resource.ResourceId == "" || resource.ResourceName == "" {
+					errs = append(errs, fmt.Errorf("room %s on floor %s in building %s has an empty name or ID", r.Name, f.Name, b.BuildingID))
+					continue
+				}
+				if ids[resource.ResourceId] {
+					errs = append(errs, fmt.Errorf("resource ID %s is generated more than once", resource.ResourceId))
+					continue
+				}
+				ids[resource.ResourceId] = true
+				resources = append(resources, resource)
+			}
+		}
+	}
+	return resources, errors.Join(errs...)
+}
+
+// CalendarResourcePatch compares an existing calendar resource with a generated one.
+// It returns a patch containing only the properties that differ and the names of these properties.
+// Optional properties that are empty in the generated resource are left unchanged.
+func CalendarResourcePatch(existing, desired *admin.CalendarResource) (*admin.CalendarResource, []string) {
+	patch := &admin.CalendarResource{}
+	var changes []string
+	compare := func(property string, old, new string, set func(string)) {
+		if old != new {
+			set(new)
+			changes = append(changes, property)
+		}
+	}
+	compare("resourceName", existing.ResourceName, desired.ResourceName, func(s string) { patch.ResourceName = s })
+	compare("buildingId", existing.BuildingId, desired.BuildingId, func(s string) { patch.BuildingId = s })
+	compare("floorName", existing.FloorName, desired.FloorName, func(s string) { patch.FloorName = s })
+	compare("resourceCategory", existing.ResourceCategory, desired.ResourceCategory, func(s string) { patch.ResourceCategory = s })
+	optional := []struct {
+		property string
+		old, new string
+		set      func(string)
+	}{
+		{"floorSection", existing.FloorSection, desired.FloorSection, func(s string) { patch.FloorSection = s }},
+		{"resourceType", existing.ResourceType, desired.ResourceType, func(s string) { patch.ResourceType = s }},
+		{"resourceDescription", existing.ResourceDescription, desired.ResourceDescription, func(s string) { patch.ResourceDescription = s }},
+		{"userVisibleDescription", existing.UserVisibleDescription, desired.UserVisibleDescription, func(s string) { patch.UserVisibleDescription = s }},
+	}
+	for _, o := range optional {
+		if o.new != "" {
+			compare(o.property, o.old, o.new, o.set)
+		}
+	}
+	if desired.Capacity != 0 && existing.Capacity != desired.Capacity {
+		patch.Capacity = desired.Capacity
+		changes = append(changes, "capacity")
+	}
+	desiredFeatures := CalendarResourceFeatureNames(desired)
+	if !slices.Equal(CalendarResourceFeatureNames(existing), desiredFeatures) {
+		patch.FeatureInstances = calendarResourceFeatureInstances(desiredFeatures)
+		changes = append(changes, "featureInstances")
+	}
+	return patch, changes
+}
@@ -18,11 +18,14 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 package gsmadmin
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/hanneshayashi/gsm/gsmhelpers"
 
+	"github.com/cenkalti/backoff/v4"
 	admin "google.golang.org/api/admin/directory/v1"
 	"google.golang.org/api/googleapi"
 )
@@ -46,3 +49,41 @@ func GetCommand(customerID, deviceID, fields string, commandID int64) (*admin.Di
 	}
 	return r, nil
 }
+
+// IsCommandStateTerminal returns true if a command with the specified state will not change anymore
+func IsCommandStateTerminal(state string) bool {
+	return state == "EXECUTED_BY_CLIENT" || state == "EXPIRED" || state == "CANCELLED"
+}
+
+// WaitForCommand polls the state of a command with an exponential backoff until it is in a terminal state or the timeout is reached.
+// If the timeout is reached, the last retrieved command is returned together with an error.
+// Rate limit errors and server errors are retried until the timeout is reached.
+func WaitForCommand(customerID, deviceID string, commandID int64, timeout time.Duration) (*admin.DirectoryChromeosdevicesCommand, error) {
+	if timeout <= 0 {
+		return nil, fmt.Errorf("timeout must be greater than 0")
+	}
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = 5 * time.Second
+	b.MaxInterval = time.Minute
+	b.MaxElapsedTime = timeout
+	var command *admin.DirectoryChromeosdevicesCommand
+	errNotTerminal := errors.New("command is not in a terminal state")
+	err := backoff.Retry(func() error {
+		c, err := GetCommand(customerID, deviceID, "", commandID)
+		if err != nil {
+			if code := gsmhelpers.ErrorCode(err); code == 429 || code >= 500 {
+				return err
+			}
+			return backoff.Permanent(err)
+		}
+		command = c
+		if !IsCommandStateTerminal(c.State) {
+			return errNotTerminal
+		}
+		return nil
+	}, b)
+	if errors.Is(err, errNotTerminal) && command != nil {
+		return command, fmt.Errorf("timed out after %v waiting for command %d on device %s (state: %s)", timeout, commandID, deviceID, command.State)
+	}
+	return command, err
+}
@@ -0,0 +1,120 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gsmadmin
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// ChromeOsDeviceResolver resolves serial numbers, asset IDs and MAC addresses of Chrome OS devices to device IDs.
+// Results are cached, so every value is only looked up once per run.
+type ChromeOsDeviceResolver struct {
+	mu    sync.Mutex
+	cache map[string]*chromeOsDeviceLookup
+}
+
+type chromeOsDeviceLookup struct {
+	once     sync.Once
+	deviceID string
+	err      error
+}
+
+// NewChromeOsDeviceResolver returns a new ChromeOsDeviceResolver with an empty cache
+func NewChromeOsDeviceResolver() *ChromeOsDeviceResolver {
+	return &ChromeOsDeviceResolver{cache: make(map[string]*chromeOsDeviceLookup)}
+}
+
+func normalizeMacAddress(mac string) string {
+	return strings.ToLower(strings.NewReplacer(":", "", "-", "", ".", "").Replace(mac))
+}
+
+// chromeOsDeviceMatches returns true if the specified property of the device is equal to value.
+// The list queries also return partial matches, so every result has to be checked.
+func chromeOsDeviceMatches(d *admin.ChromeOsDevice, idType, value string) bool {
+	switch idType {
+	case "serialNumber":
+		return strings.EqualFold(d.SerialNumber, value)
+	case "assetId":
+		return strings.EqualFold(d.AnnotatedAssetId, value)
+	case "macAddress":
+		v := normalizeMacAddress(value)
+		return normalizeMacAddress(d.MacAddress) == v || normalizeMacAddress(d.EthernetMacAddress) == v
+	}
+	return false
+}
+
+func (r *ChromeOsDeviceResolver) lookup(customerID, idType, value string) (string, error) {
+	var queries []string
+	switch idType {
+	case "serialNumber":
+		queries = []string{"id:" + value}
+	case "assetId":
+		queries = []string{"asset_id:" + value}
+	case "macAddress":
+		v := normalizeMacAddress(value)
+		queries = []string{"wifi_mac:" + v, "ethernet_mac:" + v}
+	default:
+		return "", fmt.Errorf("unknown idType %s. Must be one of deviceId, serialNumber, assetId or macAddress", idType)
+	}
+	var matches []string
+	for _, q := range queries {
+		devices, errs := ListChromeOsDevices(customerID, q, "", "chromeosdevices(deviceId,serialNumber,annotatedAssetId,macAddress,ethernetMacAddress),nextPageToken", "FULL", 1)
+		for d := range devices {
+			if chromeOsDeviceMatches(d, idType, value) && !gsmhelpers.Contains(d.DeviceId, matches) {
+				matches = append(matches, d.DeviceId)
+			}
+		}
+		if err := <-errs; err != nil {
+			return "", err
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no device found with %s %s", idType, value)
+	case 1:
+		return matches[0], nil
+	}
+	return "", fmt.Errorf("%s %s is ambiguous. Matching devices: %s", idType, value, strings.Join(matches, ", "))
+}
+
+// Resolve returns the deviceId of the device with the specified serialNumber, assetId or macAddress.
+// If idType is "deviceId" or empty, value is returned unchanged.
+// An error is returned if no device or more than one device matches.
+func (r *ChromeOsDeviceResolver) Resolve(customerID, idType, value string) (string, error) {
+	if idType == "" || idType == "deviceId" {
+		return value, nil
+	}
+	key := customerID + "\x00" + idType + "\x00" + strings.ToLower(value)
+	r.mu.Lock()
+	l, ok := r.cache[key]
+	if !ok {
+		l = &chromeOsDeviceLookup{}
+		r.cache[key] = l
+	}
+	r.mu.Unlock()
+	l.once.Do(func() {
+		l.deviceID, l.err = r.lookup(customerID, idType, value)
+	})
+	return l.deviceID, l.err
+}
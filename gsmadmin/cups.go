@@ -0,0 +1,228 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gsmadmin
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// CUPSPrinter is a print queue from a CUPS printers.conf file
+type CUPSPrinter struct {
+	Name      string `json:"name"`
+	Info      string `json:"info,omitempty"`
+	Location  string `json:"location,omitempty"`
+	MakeModel string `json:"makeModel,omitempty"`
+	DeviceURI string `json:"deviceUri,omitempty"`
+}
+
+// supportedPrinterURISchemes are the URI schemes that can be used for Chrome printers
+var supportedPrinterURISchemes = []string{"ipp", "ipps", "http", "https", "lpd", "socket"}
+
+// ParseCUPSPrinters parses the <Printer> and <DefaultPrinter> sections of a CUPS printers.conf file.
+// Classes and all other sections are ignored.
+func ParseCUPSPrinters(r io.Reader) ([]*CUPSPrinter, error) {
+	var printers []*CUPSPrinter
+	var current *CUPSPrinter
+	scanner := bufio.NewScanner(r)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "<") && strings.HasSuffix(line, ">") {
+			section := strings.TrimSpace(line[1 : len(line)-1])
+			if strings.HasPrefix(section, "/") {
+				if current != nil {
+					printers = append(printers, current)
+					current = nil
+				}
+				continue
+			}
+			kind, name, _ := strings.Cut(section, " ")
+			if kind == "Printer" || kind == "DefaultPrinter" {
+				name = strings.TrimSpace(name)
+				if name == "" {
+					return nil, fmt.Errorf("line %d: printer without a name", n)
+				}
+				current = &CUPSPrinter{Name: name}
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		key, value, _ := strings.Cut(line, " ")
+		value = strings.TrimSpace(value)
+		switch key {
+		case "Info":
+			current.Info = value
+		case "Location":
+			current.Location = value
+		case "MakeModel":
+			current.MakeModel = value
+		case "DeviceURI":
+			current.DeviceURI = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if current != nil {
+		return nil, fmt.Errorf("section of printer %s is not closed", current.Name)
+	}
+	return printers, nil
+}
+
+// ReadPPDModel returns the *NickName (or *ModelName) from a PPD file
+func ReadPPDModel(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	var modelName string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		switch key {
+		case "*NickName":
+			return value, nil
+		case "*ModelName":
+			modelName = value
+		}
+	}
+	return modelName, scanner.Err()
+}
+
+// ApplyPPDModels replaces the MakeModel of the printers with the model from the PPD file of the same name in dir, if such a file exists
+func ApplyPPDModels(printers []*CUPSPrinter, dir string) error {
+	for _, p := range printers {
+		model, err := ReadPPDModel(filepath.Join(dir, p.Name+".ppd"))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		if model != "" {
+			p.MakeModel = model
+		}
+	}
+	return nil
+}
+
+var (
+	printerModelNoise      = regexp.MustCompile(`(?i)\s*(\(recommended\)|,?\s*driverless.*|,\s*using\b.*|\s-\s.*|\bfoomatic/.*|\bcups\+gutenprint.*|\b(postscript|pcl ?\d*e?|ps|series|br-script\d*)\b)`)
+	printerModelCharacters = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// normalizePrinterModel removes driver information and punctuation from a make and model string, so that CUPS models can be compared to Chrome printer models
+func normalizePrinterModel(model string) string {
+	model = printerModelNoise.ReplaceAllString(model, " ")
+	return strings.TrimSpace(printerModelCharacters.ReplaceAllString(strings.ToLower(model), " "))
+}
+
+// PrinterModelMatcher matches CUPS models to Chrome printer models
+type PrinterModelMatcher struct {
+	models map[string]string
+}
+
+// NewPrinterModelMatcher returns a PrinterModelMatcher for the specified Chrome printer models
+func NewPrinterModelMatcher(models []*admin.PrinterModel) *PrinterModelMatcher {
+	m := &PrinterModelMatcher{models: make(map[string]string)}
+	for _, model := range models {
+		m.models[normalizePrinterModel(model.MakeAndModel)] = model.MakeAndModel
+	}
+	return m
+}
+
+// Match returns the Chrome printer model for a CUPS model.
+// If there is no exact match, the longest model that is a prefix of the CUPS model (on a word boundary) is returned.
+// Returns an empty string if no model matches.
+func (m *PrinterModelMatcher) Match(model string) string {
+	n := normalizePrinterModel(model)
+	if n == "" {
+		return ""
+	}
+	if match, ok := m.models[n]; ok {
+		return match
+	}
+	var best, bestNormalized string
+	for normalized, original := range m.models {
+		if normalized == "" || len(normalized) <= len(bestNormalized) {
+			continue
+		}
+		if strings.HasPrefix(n, normalized+" ") {
+			best, bestNormalized = original, normalized
+		}
+	}
+	return best
+}
+
+// CUPSPrinterURISupported returns true if the device URI of the printer can be used for a Chrome printer
+func CUPSPrinterURISupported(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	for _, s := range supportedPrinterURISchemes {
+		if strings.EqualFold(u.Scheme, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// CUPSPrinterToPrinter converts a CUPS print queue to a Chrome printer
+func CUPSPrinterToPrinter(p *CUPSPrinter, makeAndModel, orgUnitID string) *admin.Printer {
+	description := p.Info
+	if p.Location != "" {
+		if description != "" {
+			description += " (" + p.Location + ")"
+		} else {
+			description = p.Location
+		}
+	}
+	printer := &admin.Printer{
+		DisplayName:  p.Name,
+		Description:  description,
+		Uri:          p.DeviceURI,
+		MakeAndModel: makeAndModel,
+		OrgUnitId:    orgUnitID,
+	}
+	if makeAndModel == "" {
+		printer.UseDriverlessConfig = true
+	}
+	return printer
+}
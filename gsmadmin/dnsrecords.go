@@ -0,0 +1,316 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gsmadmin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DNSRecord is a DNS record that is required to use a domain with Google Workspace
+type DNSRecord struct {
+	Name     string `json:"name"`
+	FQDN     string `json:"fqdn"`
+	Type     string `json:"type"`
+	TTL      int64  `json:"ttl"`
+	Priority int64  `json:"priority,omitempty"`
+	Value    string `json:"value"`
+	Purpose  string `json:"purpose"`
+}
+
+// DNSRecordOptions control which records are generated by GenerateDNSRecords
+type DNSRecordOptions struct {
+	TTL               int64
+	VerificationToken string
+	DKIMSelector      string
+	DKIMKey           string
+	DMARCPolicy       string
+	DMARCReport       string
+	CNAMEs            []string
+}
+
+// DNSRecordCheck is the result of comparing a DNS record with the answers of a resolver
+type DNSRecordCheck struct {
+	FQDN     string   `json:"fqdn"`
+	Type     string   `json:"type"`
+	Purpose  string   `json:"purpose"`
+	Expected string   `json:"expected"`
+	Status   string   `json:"status"`
+	Actual   []string `json:"actual,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// CloudflareDNSRecord is a DNS record in the format of the Cloudflare API
+type CloudflareDNSRecord struct {
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	Content  string `json:"content"`
+	TTL      int64  `json:"ttl"`
+	Priority int64  `json:"priority,omitempty"`
+	Proxied  bool   `json:"proxied"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+const (
+	googleMXHost     = "smtp.google.com."
+	googleSPFInclude = "include:_spf.google.com"
+	googleCNAMEHost  = "ghs.googlehosted.com."
+)
+
+// GenerateDNSRecords returns the MX, SPF, DMARC and (optionally) verification, DKIM and CNAME records for a domain
+func GenerateDNSRecords(domain string, opts *DNSRecordOptions) []*DNSRecord {
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	record := func(name, recordType, value, purpose string) *DNSRecord {
+		fqdn := domain + "."
+		if name != "@" {
+			fqdn = name + "." + fqdn
+		}
+		return &DNSRecord{Name: name, FQDN: fqdn, Type: recordType, TTL: opts.TTL, Value: value, Purpose: purpose}
+	}
+	var records []*DNSRecord
+	if opts.VerificationToken != "" {
+		token := opts.VerificationToken
+		if !strings.HasPrefix(token, "google-site-verification=") {
+			token = "google-site-verification=" + token
+		}
+		records = append(records, record("@", "TXT", token, "verification"))
+	}
+	mx := record("@", "MX", googleMXHost, "mail")
+	mx.Priority = 1
+	records = append(records, mx, record("@", "TXT", "v=spf1 "+googleSPFInclude+" ~all", "spf"))
+	if opts.DKIMKey != "" {
+		key := strings.Join(strings.Fields(opts.DKIMKey), "")
+		if !strings.HasPrefix(key, "v=DKIM1") {
+			key = "v=DKIM1; k=rsa; p=" + key
+		}
+		selector := opts.DKIMSelector
+		if selector == "" {
+			selector = "google"
+		}
+		records = append(records, record(selector+"._domainkey", "TXT", key, "dkim"))
+	}
+	dmarc := "v=DMARC1; p=" + opts.DMARCPolicy
+	if opts.DMARCReport != "" {
+		dmarc += "; rua=mailto:" + opts.DMARCReport
+	}
+	records = append(records, record("_dmarc", "TXT", dmarc, "dmarc"))
+	for _, c := range opts.CNAMEs {
+		records = append(records, record(c, "CNAME", googleCNAMEHost, "cname"))
+	}
+	return records
+}
+
+// splitTXT splits a TXT value into strings of at most 255 characters, as required by the DNS protocol
+func splitTXT(value string) []string {
+	var parts []string
+	for len(value) > 255 {
+		parts = append(parts, value[:255])
+		value = value[255:]
+	}
+	return append(parts, value)
+}
+
+func quoteTXT(value string) string {
+	parts := splitTXT(value)
+	for i := range parts {
+		parts[i] = strconv.Quote(parts[i])
+	}
+	return strings.Join(parts, " ")
+}
+
+// WriteDNSRecordsBIND writes the records as a BIND zone file snippet
+func WriteDNSRecordsBIND(w io.Writer, domain string, records []*DNSRecord) error {
+	_, err := fmt.Fprintf(w, "$ORIGIN %s.\n", strings.TrimSuffix(domain, "."))
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		value := r.Value
+		switch r.Type {
+		case "MX":
+			value = fmt.Sprintf("%d %s", r.Priority, r.Value)
+		case "TXT":
+			value = quoteTXT(r.Value)
+		}
+		_, err = fmt.Fprintf(w, "%-24s %d IN %-5s %s\n", r.Name, r.TTL, r.Type, value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var terraformResourceCharacters = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+
+// WriteDNSRecordsTerraform writes the records as Terraform google_dns_record_set resources.
+// Records with the same name and type are combined into a single record set.
+func WriteDNSRecordsTerraform(w io.Writer, managedZone string, records []*DNSRecord) error {
+	type recordSet struct {
+		record  *DNSRecord
+		rrdatas []string
+	}
+	var sets []*recordSet
+	index := make(map[string]*recordSet)
+	for _, r := range records {
+		key := r.FQDN + " " + r.Type
+		value := r.Value
+		switch r.Type {
+		case "MX":
+			value = fmt.Sprintf("%d %s", r.Priority, r.Value)
+		case "TXT":
+			// Terraform expects each string of a TXT record to be quoted
+			value = strings.ReplaceAll(quoteTXT(r.Value), `"`, `\"`)
+		}
+		s, ok := index[key]
+		if !ok {
+			s = &recordSet{record: r}
+			index[key] = s
+			sets = append(sets, s)
+		}
+		s.rrdatas = append(s.rrdatas, value)
+	}
+	for _, s := range sets {
+		name := strings.ToLower(terraformResourceCharacters.ReplaceAllString(strings.TrimSuffix(s.record.FQDN, ".")+"_"+s.record.Type, "_"))
+		rrdatas := make([]string, len(s.rrdatas))
+		for i := range s.rrdatas {
+			rrdatas[i] = `"` + s.rrdatas[i] + `"`
+		}
+		_, err := fmt.Fprintf(w, "resource \"google_dns_record_set\" %q {\n  managed_zone = %q\n  name         = %q\n  type         = %q\n  ttl          = %d\n  rrdatas      = [%s]\n}\n\n",
+			name, managedZone, s.record.FQDN, s.record.Type, s.record.TTL, strings.Join(rrdatas, ", "))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CloudflareDNSRecords converts the records to the format of the Cloudflare API
+func CloudflareDNSRecords(records []*DNSRecord) []*CloudflareDNSRecord {
+	result := make([]*CloudflareDNSRecord, len(records))
+	for i, r := range records {
+		result[i] = &CloudflareDNSRecord{
+			Type:     r.Type,
+			Name:     strings.TrimSuffix(r.FQDN, "."),
+			Content:  strings.TrimSuffix(r.Value, "."),
+			TTL:      r.TTL,
+			Priority: r.Priority,
+			Comment:  "Google Workspace " + r.Purpose,
+		}
+	}
+	return result
+}
+
+// NewDNSResolver returns a resolver that sends all queries to the specified server (host or host:port).
+// If server is empty, the system resolver is returned.
+func NewDNSResolver(server string) *net.Resolver {
+	if server == "" {
+		return net.DefaultResolver
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			d := net.Dialer{Timeout: 5 * time.Second}
+			return d.DialContext(ctx, network, server)
+		},
+	}
+}
+
+func normalizeDNSName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, ".")) + "."
+}
+
+// dnsRecordMatches returns true if one of the answers satisfies the expected record
+func dnsRecordMatches(r *DNSRecord, answers []string) bool {
+	for _, a := range answers {
+		switch {
+		case r.Type == "MX" || r.Type == "CNAME":
+			if strings.HasSuffix(a, normalizeDNSName(r.Value)) && (r.Type == "CNAME" || strings.HasPrefix(a, strconv.FormatInt(r.Priority, 10)+" ")) {
+				return true
+			}
+		case r.Purpose == "spf":
+			if strings.HasPrefix(a, "v=spf1") && slices.Contains(strings.Fields(a), googleSPFInclude) {
+				return true
+			}
+		case r.Purpose == "dmarc":
+			if strings.HasPrefix(a, "v=DMARC1") {
+				return true
+			}
+		default:
+			if strings.Join(strings.Fields(a), "") == strings.Join(strings.Fields(r.Value), "") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CheckDNSRecords queries the resolver for every record and reports whether the current answers match.
+// SPF and DMARC records are considered correct if a policy exists that includes Google (SPF) or any DMARC policy exists,
+// because existing policies are usually customized.
+func CheckDNSRecords(ctx context.Context, resolver *net.Resolver, records []*DNSRecord) []*DNSRecordCheck {
+	checks := make([]*DNSRecordCheck, len(records))
+	for i, r := range records {
+		c := &DNSRecordCheck{FQDN: r.FQDN, Type: r.Type, Purpose: r.Purpose, Expected: r.Value}
+		checks[i] = c
+		var err error
+		switch r.Type {
+		case "MX":
+			var mx []*net.MX
+			mx, err = resolver.LookupMX(ctx, r.FQDN)
+			for _, m := range mx {
+				c.Actual = append(c.Actual, fmt.Sprintf("%d %s", m.Pref, normalizeDNSName(m.Host)))
+			}
+		case "TXT":
+			c.Actual, err = resolver.LookupTXT(ctx, r.FQDN)
+		case "CNAME":
+			var cname string
+			cname, err = resolver.LookupCNAME(ctx, r.FQDN)
+			if cname != "" && normalizeDNSName(cname) != normalizeDNSName(r.FQDN) {
+				c.Actual = []string{normalizeDNSName(cname)}
+			}
+		}
+		if err != nil {
+			var dnsErr *net.DNSError
+			if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+				c.Status = "error"
+				c.Error = err.Error()
+				continue
+			}
+		}
+		switch {
+		case dnsRecordMatches(r, c.Actual):
+			c.Status = "ok"
+		case len(c.Actual) == 0:
+			c.Status = "missing"
+		default:
+			c.Status = "different"
+		}
+	}
+	return checks
+}
@@ -0,0 +1,324 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gsmadmin
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// GroupTreeNode is a member of a group in a group tree.
+// Members of type GROUP contain their own members, unless they would create a cycle.
+type GroupTreeNode struct {
+	Email   string           `json:"email,omitempty"`
+	ID      string           `json:"id,omitempty"`
+	Type    string           `json:"type"`
+	Role    string           `json:"role,omitempty"`
+	Status  string           `json:"status,omitempty"`
+	Depth   int              `json:"depth"`
+	Cycle   bool             `json:"cycle,omitempty"`
+	Error   string           `json:"error,omitempty"`
+	Members []*GroupTreeNode `json:"members,omitempty"`
+}
+
+// GroupTree is the nested membership tree of a group
+type GroupTree struct {
+	Root     *GroupTreeNode        `json:"root"`
+	MaxDepth int                   `json:"maxDepth"`
+	Groups   int                   `json:"groups"`
+	Users    int                   `json:"users"`
+	Cycles   [][]string            `json:"cycles,omitempty"`
+	Paths    map[string][][]string `json:"paths,omitempty"`
+}
+
+type groupTreeBuilder struct {
+	tree    *GroupTree
+	members map[string][]*admin.Member
+	threads int
+}
+
+func (b *groupTreeBuilder) listMembers(groupKey string) ([]*admin.Member, error) {
+	if m, ok := b.members[groupKey]; ok {
+		return m, nil
+	}
+	var members []*admin.Member
+	ms, errs := ListMembers(groupKey, "", "members(id,email,role,type,status),nextPageToken", false, b.threads)
+	for m := range ms {
+		members = append(members, m)
+	}
+	if err := <-errs; err != nil {
+		return nil, err
+	}
+	b.members[groupKey] = members
+	return members, nil
+}
+
+// walk adds the members of node to the tree. path contains the email addresses of all groups from the root to node.
+func (b *groupTreeBuilder) walk(node *GroupTreeNode, path []string) {
+	members, err := b.listMembers(node.ID)
+	if err != nil {
+		node.Error = err.Error()
+		return
+	}
+	for _, m := range members {
+		child := &GroupTreeNode{Email: strings.ToLower(m.Email), ID: m.Id, Type: m.Type, Role: m.Role, Status: m.Status, Depth: node.Depth + 1}
+		node.Members = append(node.Members, child)
+		if child.Depth > b.tree.MaxDepth {
+			b.tree.MaxDepth = child.Depth
+		}
+		switch child.Type {
+		case "GROUP":
+			if i := indexOf(path, child.Email); i >= 0 {
+				child.Cycle = true
+				cycle := append([]string{}, path[i:]...)
+				b.tree.Cycles = append(b.tree.Cycles, append(cycle, child.Email))
+				continue
+			}
+			b.walk(child, append(path[:len(path):len(path)], child.Email))
+		case "USER":
+			key := child.Email
+			if key == "" {
+				key = child.ID
+			}
+			b.tree.Paths[key] = append(b.tree.Paths[key], append(path[:len(path):len(path)], key))
+		}
+	}
+}
+
+func indexOf(s []string, v string) int {
+	for i := range s {
+		if s[i] == v {
+			return i
+		}
+	}
+	return -1
+}
+
+// BuildGroupTree recursively lists the members of a group and returns the nested membership tree.
+// Every user is listed with all paths through which they are a member of the group.
+// Groups that are (indirectly) members of themselves are reported as cycles and are not expanded again.
+func BuildGroupTree(groupKey string, threads int) (*GroupTree, error) {
+	group, err := GetGroup(groupKey, "id,email")
+	if err != nil {
+		return nil, err
+	}
+	b := &groupTreeBuilder{
+		tree: &GroupTree{
+			Root:  &GroupTreeNode{Email: strings.ToLower(group.Email), ID: group.Id, Type: "GROUP"},
+			Paths: make(map[string][][]string),
+		},
+		members: make(map[string][]*admin.Member),
+		threads: threads,
+	}
+	b.walk(b.tree.Root, []string{b.tree.Root.Email})
+	b.tree.Groups = len(b.members)
+	b.tree.Users = len(b.tree.Paths)
+	return b.tree, nil
+}
+
+// pruneGroupTreeNode returns a copy of node that only contains the branches that lead to memberKey
+func pruneGroupTreeNode(node *GroupTreeNode, memberKey string) *GroupTreeNode {
+	if node.Email == memberKey || node.ID == memberKey {
+		return node
+	}
+	var members []*GroupTreeNode
+	for _, m := range node.Members {
+		if p := pruneGroupTreeNode(m, memberKey); p != nil {
+			members = append(members, p)
+		}
+	}
+	if members == nil {
+		return nil
+	}
+	pruned := *node
+	pruned.Members = members
+	return &pruned
+}
+
+// Filter returns a copy of the tree that only contains the paths to the specified member
+func (t *GroupTree) Filter(memberKey string) *GroupTree {
+	memberKey = strings.ToLower(memberKey)
+	filtered := &GroupTree{
+		Root:   pruneGroupTreeNode(t.Root, memberKey),
+		Cycles: t.Cycles,
+		Paths:  make(map[string][][]string),
+	}
+	if filtered.Root == nil {
+		filtered.Root = &GroupTreeNode{Email: t.Root.Email, ID: t.Root.ID, Type: t.Root.Type}
+	}
+	if paths, ok := t.Paths[memberKey]; ok {
+		filtered.Paths[memberKey] = paths
+		filtered.Users = 1
+		for _, p := range paths {
+			if len(p)-1 > filtered.MaxDepth {
+				filtered.MaxDepth = len(p) - 1
+			}
+		}
+	}
+	groups := make(map[string]struct{})
+	var count func(n *GroupTreeNode)
+	count = func(n *GroupTreeNode) {
+		if n.Type == "GROUP" {
+			groups[n.Email] = struct{}{}
+		}
+		for _, m := range n.Members {
+			count(m)
+		}
+	}
+	count(filtered.Root)
+	filtered.Groups = len(groups)
+	return filtered
+}
+
+func (n *GroupTreeNode) label() string {
+	if n.Email != "" {
+		return n.Email
+	}
+	if n.Type == "CUSTOMER" {
+		return "All users (" + n.ID + ")"
+	}
+	return n.ID
+}
+
+// WriteText writes the tree as indented text
+func (t *GroupTree) WriteText(w io.Writer) error {
+	var write func(n *GroupTreeNode) error
+	write = func(n *GroupTreeNode) error {
+		line := strings.Repeat("  ", n.Depth) + n.label() + " (" + n.Type
+		if n.Role != "" {
+			line += ", " + n.Role
+		}
+		line += ")"
+		if n.Cycle {
+			line += " [cycle]"
+		}
+		if n.Error != "" {
+			line += " [error: " + n.Error + "]"
+		}
+		_, err := fmt.Fprintln(w, line)
+		if err != nil {
+			return err
+		}
+		for _, m := range n.Members {
+			err = write(m)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	err := write(t.Root)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "\nMaximum depth: %d, groups: %d, users: %d, cycles: %d\n", t.MaxDepth, t.Groups, t.Users, len(t.Cycles))
+	return err
+}
+
+// edges calls f once for every unique parent -> member relationship in the tree
+func (t *GroupTree) edges(f func(parent, member *GroupTreeNode)) {
+	seen := make(map[string]struct{})
+	var walk func(n *GroupTreeNode)
+	walk = func(n *GroupTreeNode) {
+		for _, m := range n.Members {
+			key := n.label() + "\x00" + m.label()
+			if _, ok := seen[key]; !ok {
+				seen[key] = struct{}{}
+				f(n, m)
+			}
+			walk(m)
+		}
+	}
+	walk(t.Root)
+}
+
+// WriteDOT writes the tree as a Graphviz DOT digraph
+func (t *GroupTree) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %q {\n\trankdir=LR;\n", t.Root.label())
+	nodes := make(map[string]struct{})
+	node := func(n *GroupTreeNode) {
+		if _, ok := nodes[n.label()]; ok {
+			return
+		}
+		nodes[n.label()] = struct{}{}
+		shape := "ellipse"
+		if n.Type == "GROUP" {
+			shape = "box"
+		}
+		fmt.Fprintf(&b, "\t%q [shape=%s];\n", n.label(), shape)
+	}
+	node(t.Root)
+	t.edges(func(parent, member *GroupTreeNode) {
+		node(member)
+		var attrs []string
+		if member.Role != "" && member.Role != "MEMBER" {
+			attrs = append(attrs, fmt.Sprintf("label=%q", member.Role))
+		}
+		if member.Cycle {
+			attrs = append(attrs, "style=dashed", "color=red")
+		}
+		if len(attrs) > 0 {
+			fmt.Fprintf(&b, "\t%q -> %q [%s];\n", parent.label(), member.label(), strings.Join(attrs, ", "))
+		} else {
+			fmt.Fprintf(&b, "\t%q -> %q;\n", parent.label(), member.label())
+		}
+	})
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteMermaid writes the tree as a Mermaid flowchart
+func (t *GroupTree) WriteMermaid(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("graph LR\n")
+	ids := make(map[string]string)
+	id := func(n *GroupTreeNode) string {
+		if i, ok := ids[n.label()]; ok {
+			return i
+		}
+		i := fmt.Sprintf("n%d", len(ids))
+		ids[n.label()] = i
+		label := strings.ReplaceAll(n.label(), `"`, "#quot;")
+		if n.Type == "GROUP" {
+			fmt.Fprintf(&b, "\t%s[\"%s\"]\n", i, label)
+		} else {
+			fmt.Fprintf(&b, "\t%s(\"%s\")\n", i, label)
+		}
+		return i
+	}
+	id(t.Root)
+	t.edges(func(parent, member *GroupTreeNode) {
+		p := id(parent)
+		m := id(member)
+		switch {
+		case member.Cycle:
+			fmt.Fprintf(&b, "\t%s -.->|cycle| %s\n", p, m)
+		case member.Role != "" && member.Role != "MEMBER":
+			fmt.Fprintf(&b, "\t%s -->|%s| %s\n", p, member.Role, m)
+		default:
+			fmt.Fprintf(&b, "\t%s --> %s\n", p, m)
+		}
+	})
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,224 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gsmadmin
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	admin "google.golang.org/api/admin/directory/v1"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultLDIFAttributeMap maps common LDAP attributes to the user properties they are imported to and exported from
+var DefaultLDIFAttributeMap = map[string]string{
+	"cn":              "fullName",
+	"sn":              "familyName",
+	"givenName":       "givenName",
+	"mail":            "primaryEmail",
+	"telephoneNumber": "workPhone",
+	"mobile":          "mobilePhone",
+	"title":           "title",
+	"department":      "department",
+	"employeeNumber":  "employeeId",
+	"manager":         "manager",
+	"memberOf":        "memberOf",
+}
+
+// LoadLDIFAttributeMap reads a YAML file that maps LDAP attribute names to user properties, e.g.:
+//
+//	mail: primaryEmail
+//	sn: familyName
+//
+// The map replaces the default map entirely.
+func LoadLDIFAttributeMap(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	attributeMap := map[string]string{}
+	err = yaml.Unmarshal(content, &attributeMap)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing attribute map %s: %v", path, err)
+	}
+	var hasPrimaryEmail bool
+	for k := range attributeMap {
+		if attributeMap[k] != "memberOf" && !gsmhelpers.Contains(attributeMap[k], UserProperties) {
+			return nil, fmt.Errorf("unknown user property %q for LDAP attribute %s. Must be one of %s or memberOf", attributeMap[k], k, strings.Join(UserProperties, ", "))
+		}
+		if attributeMap[k] == "primaryEmail" {
+			hasPrimaryEmail = true
+		}
+	}
+	if !hasPrimaryEmail {
+		return nil, fmt.Errorf("attribute map %s does not map any attribute to primaryEmail", path)
+	}
+	return attributeMap, nil
+}
+
+// sortedLDIFAttributes returns the LDAP attributes of an attribute map in a stable order
+func sortedLDIFAttributes(attributeMap map[string]string) []string {
+	attributes := make([]string, 0, len(attributeMap))
+	for k := range attributeMap {
+		attributes = append(attributes, k)
+	}
+	sort.Strings(attributes)
+	return attributes
+}
+
+// EmailToDN builds a DN for an email address in the form of "<attribute>=<local part>,<baseDN>".
+// If baseDN is empty, it is derived from the domain of the email address (e.g. "dc=example,dc=com").
+func EmailToDN(email, attribute, baseDN string) string {
+	local, domain, _ := strings.Cut(email, "@")
+	if baseDN == "" {
+		labels := strings.Split(domain, ".")
+		for i := range labels {
+			labels[i] = "dc=" + labels[i]
+		}
+		baseDN = strings.Join(labels, ",")
+	}
+	return fmt.Sprintf("%s=%s,%s", attribute, local, baseDN)
+}
+
+// NewLDIFDNResolver returns a function that resolves a DN to an email address.
+// DNs of entries in the LDIF file are resolved to the value of the attribute mapped to primaryEmail (or "mail" for groups).
+// Other DNs are resolved by their first RDN: values that contain an "@" are used as is,
+// all other values are combined with the specified domain. If no domain is specified, the DN can not be resolved.
+func NewLDIFDNResolver(entries []*gsmhelpers.LDIFEntry, attributeMap map[string]string, domain string) func(dn string) string {
+	emailAttribute := MappedAttribute(attributeMap, "primaryEmail")
+	emails := make(map[string]string)
+	for i := range entries {
+		email := entries[i].GetFirst(emailAttribute)
+		if email == "" {
+			email = entries[i].GetFirst("mail")
+		}
+		if email != "" {
+			emails[gsmhelpers.NormalizeDN(entries[i].DN)] = email
+		}
+	}
+	return func(dn string) string {
+		if email, found := emails[gsmhelpers.NormalizeDN(dn)]; found {
+			return email
+		}
+		rdn, _, _ := strings.Cut(dn, ",")
+		_, value, ok := strings.Cut(rdn, "=")
+		value = strings.TrimSpace(value)
+		if !ok || value == "" {
+			return ""
+		}
+		if strings.Contains(value, "@") {
+			return value
+		}
+		if domain == "" {
+			return ""
+		}
+		return value + "@" + domain
+	}
+}
+
+// LDIFEntryToUser converts an LDIF entry to a user object, using the attribute map.
+// DNs (manager, memberOf) are converted to email addresses with resolveDN.
+// The email addresses of the groups the user is a member of (memberOf) are returned separately,
+// as well as the DNs that could not be resolved.
+func LDIFEntryToUser(entry *gsmhelpers.LDIFEntry, attributeMap map[string]string, resolveDN func(dn string) string) (*admin.User, []string, []string) {
+	var groups, unresolved []string
+	var phones []map[string]any
+	properties := make(map[string]string)
+	for _, attribute := range sortedLDIFAttributes(attributeMap) {
+		property := attributeMap[attribute]
+		values := entry.Get(attribute)
+		if len(values) == 0 {
+			continue
+		}
+		switch property {
+		case "memberOf":
+			for i := range values {
+				if email := resolveDN(values[i]); email != "" {
+					groups = append(groups, email)
+				} else {
+					unresolved = append(unresolved, values[i])
+				}
+			}
+		case "manager":
+			if email := resolveDN(values[0]); email != "" {
+				properties[property] = email
+			} else {
+				unresolved = append(unresolved, values[0])
+			}
+		case "workPhone", "mobilePhone":
+			// All values are kept, e.g. for users with multiple telephoneNumber attributes
+			for i := range values {
+				phones = append(phones, map[string]any{"type": strings.TrimSuffix(property, "Phone"), "value": values[i]})
+			}
+		default:
+			if _, found := properties[property]; !found {
+				properties[property] = values[0]
+			}
+		}
+	}
+	user := PatchUserProperties(&admin.User{}, properties)
+	if len(phones) > 0 {
+		phones[0]["primary"] = true
+		user.Phones = phones
+	}
+	return user, groups, unresolved
+}
+
+// UserToLDIFEntry converts a user to an LDIF entry (objectClass inetOrgPerson), using the attribute map.
+// userDN and groupDN are used to convert the email addresses of users (manager) and groups (memberOf) to DNs.
+func UserToLDIFEntry(user *admin.User, groups []string, attributeMap map[string]string, userDN, groupDN func(email string) string) *gsmhelpers.LDIFEntry {
+	entry := &gsmhelpers.LDIFEntry{DN: userDN(user.PrimaryEmail)}
+	entry.Add("objectClass", "top", "person", "organizationalPerson", "inetOrgPerson")
+	attributes := sortedLDIFAttributes(attributeMap)
+	for _, property := range append(UserProperties, "memberOf") {
+		for _, attribute := range attributes {
+			if attributeMap[attribute] != property {
+				continue
+			}
+			switch property {
+			case "manager":
+				if manager := GetUserProperty(user, property); manager != "" {
+					entry.Add(attribute, userDN(manager))
+				}
+			case "memberOf":
+				for i := range groups {
+					entry.Add(attribute, groupDN(groups[i]))
+				}
+			default:
+				entry.Add(attribute, GetUserProperty(user, property))
+			}
+		}
+	}
+	return entry
+}
+
+// GroupToLDIFEntry converts a group and the DNs of its members to an LDIF entry (objectClass groupOfNames)
+func GroupToLDIFEntry(email, name string, memberDNs []string, groupDN func(email string) string) *gsmhelpers.LDIFEntry {
+	entry := &gsmhelpers.LDIFEntry{DN: groupDN(email)}
+	entry.Add("objectClass", "top", "groupOfNames")
+	cn, _, _ := strings.Cut(email, "@")
+	entry.Add("cn", cn)
+	entry.Add("mail", email)
+	entry.Add("description", name)
+	entry.Add("member", memberDNs...)
+	return entry
+}
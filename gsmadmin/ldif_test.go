@@ -0,0 +1,172 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gsmadmin
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+func readLDIFFixture(t *testing.T, name string) []*gsmhelpers.LDIFEntry {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", "ldif", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	entries, err := gsmhelpers.ParseLDIF(f)
+	if err != nil {
+		t.Fatalf("ParseLDIF(%s) error = %v", name, err)
+	}
+	return entries
+}
+
+// assertJSONFixture compares the JSON representation of got to the content of a fixture file
+func assertJSONFixture(t *testing.T, got any, name string) {
+	t.Helper()
+	b, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(filepath.Join("testdata", "ldif", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotValue, wantValue any
+	if err = json.Unmarshal(b, &gotValue); err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal(want, &wantValue); err != nil {
+		t.Fatalf("error parsing %s: %v", name, err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		indented, _ := json.MarshalIndent(gotValue, "", "  ")
+		t.Errorf("result doesn't match %s:\n%s", name, indented)
+	}
+}
+
+func TestEmailToDN(t *testing.T) {
+	tests := []struct {
+		email, attribute, baseDN, want string
+	}{
+		{"alice@example.com", "uid", "", "uid=alice,dc=example,dc=com"},
+		{"alice@sub.example.com", "uid", "", "uid=alice,dc=sub,dc=example,dc=com"},
+		{"team@example.com", "cn", "ou=groups,dc=example,dc=com", "cn=team,ou=groups,dc=example,dc=com"},
+	}
+	for _, tt := range tests {
+		if got := EmailToDN(tt.email, tt.attribute, tt.baseDN); got != tt.want {
+			t.Errorf("EmailToDN(%q, %q, %q) = %q, want %q", tt.email, tt.attribute, tt.baseDN, got, tt.want)
+		}
+	}
+}
+
+func TestNewLDIFDNResolver(t *testing.T) {
+	entries := readLDIFFixture(t, "import.ldif")
+	tests := []struct {
+		dn, domain, want string
+	}{
+		{"uid=alice,ou=people,dc=example,dc=com", "", "alice@example.com"},
+		{"UID=Alice, OU=People, DC=Example, DC=Com", "", "alice@example.com"},
+		{"cn=engineering,ou=groups,dc=example,dc=com", "", "engineering@example.com"},
+		{"uid=bob,ou=people,dc=example,dc=com", "example.com", "bob@example.com"},
+		{"uid=bob,ou=people,dc=example,dc=com", "", ""},
+		{"mail=bob@example.net,ou=people,dc=example,dc=com", "", "bob@example.net"},
+	}
+	for _, tt := range tests {
+		resolve := NewLDIFDNResolver(entries, DefaultLDIFAttributeMap, tt.domain)
+		if got := resolve(tt.dn); got != tt.want {
+			t.Errorf("resolve(%q) with domain %q = %q, want %q", tt.dn, tt.domain, got, tt.want)
+		}
+	}
+}
+
+func TestLDIFEntryToUser(t *testing.T) {
+	type importResult struct {
+		User       *admin.User `json:"user"`
+		Groups     []string    `json:"groups,omitempty"`
+		Unresolved []string    `json:"unresolved,omitempty"`
+	}
+	entries := readLDIFFixture(t, "import.ldif")
+	resolve := NewLDIFDNResolver(entries, DefaultLDIFAttributeMap, "")
+	var results []*importResult
+	for _, entry := range entries {
+		if len(entry.Get("member")) > 0 {
+			continue
+		}
+		user, groups, unresolved := LDIFEntryToUser(entry, DefaultLDIFAttributeMap, resolve)
+		results = append(results, &importResult{User: user, Groups: groups, Unresolved: unresolved})
+	}
+	assertJSONFixture(t, results, "import_expected.json")
+}
+
+func TestUserToLDIFEntry(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join("testdata", "ldif", "export_users.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var users []*admin.User
+	if err = json.Unmarshal(b, &users); err != nil {
+		t.Fatal(err)
+	}
+	userDN := func(email string) string { return EmailToDN(email, "uid", "ou=people,dc=example,dc=com") }
+	groupDN := func(email string) string { return EmailToDN(email, "cn", "ou=groups,dc=example,dc=com") }
+	memberships := map[string][]string{"alice@example.com": {"engineering@example.com"}}
+	var entries []*gsmhelpers.LDIFEntry
+	var memberDNs []string
+	for _, u := range users {
+		entries = append(entries, UserToLDIFEntry(u, memberships[u.PrimaryEmail], DefaultLDIFAttributeMap, userDN, groupDN))
+		memberDNs = append(memberDNs, userDN(u.PrimaryEmail))
+	}
+	entries = append(entries, GroupToLDIFEntry("engineering@example.com", "Engineering", memberDNs, groupDN))
+	var buf bytes.Buffer
+	if err = gsmhelpers.WriteLDIF(&buf, entries); err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(filepath.Join("testdata", "ldif", "export_expected.ldif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != string(want) {
+		t.Fatalf("WriteLDIF() output doesn't match export_expected.ldif:\n%s", buf.String())
+	}
+	// Importing the export must result in the same properties
+	parsed, err := gsmhelpers.ParseLDIF(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolve := NewLDIFDNResolver(parsed, DefaultLDIFAttributeMap, "")
+	for i, u := range users {
+		imported, groups, unresolved := LDIFEntryToUser(parsed[i], DefaultLDIFAttributeMap, resolve)
+		for _, property := range UserProperties {
+			if got, want := GetUserProperty(imported, property), GetUserProperty(u, property); got != want {
+				t.Errorf("%s: %s = %q after round trip, want %q", u.PrimaryEmail, property, got, want)
+			}
+		}
+		if !reflect.DeepEqual(groups, memberships[u.PrimaryEmail]) || len(unresolved) > 0 {
+			t.Errorf("%s: groups = %v, unresolved = %v after round trip", u.PrimaryEmail, groups, unresolved)
+		}
+	}
+}
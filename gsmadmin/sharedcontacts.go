@@ -153,6 +153,15 @@ type StructuredPostalAddress struct {
 	FormattedAddress string `xml:"formattedAddress"`
 }
 
+// Website is a Shared Contact Website
+type Website struct {
+	Text    string `xml:",chardata"`
+	Href    string `xml:"href,attr"`
+	Rel     string `xml:"rel,attr"`
+	Label   string `xml:"label,attr"`
+	Primary string `xml:"primary,attr"`
+}
+
 // EntryLinkEntryLink is a Link in an EntryLinkEntry object
 type EntryLinkEntryLink struct {
 	Text string `xml:",chardata"`
@@ -220,6 +229,7 @@ type Entry struct {
 	Organization            []Organization            `xml:"organization"`
 	StructuredPostalAddress []StructuredPostalAddress `xml:"structuredPostalAddress"`
 	PhoneNumber             []PhoneNumber             `xml:"phoneNumber"`
+	Website                 []Website                 `xml:"website"`
 }
 
 type Feed struct {
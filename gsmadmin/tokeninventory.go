@@ -0,0 +1,133 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gsmadmin
+
+import (
+	"bufio"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// highRiskScopes maps OAuth scopes that grant full access to user data to their risk category.
+// Admin SDK scopes ("https://www.googleapis.com/auth/admin.*") are always considered high-risk.
+var highRiskScopes = map[string]string{
+	"https://mail.google.com/":              "gmail",
+	"https://www.googleapis.com/auth/drive": "drive",
+}
+
+// TokenApp is an application that has been granted access to user data by one or more users
+type TokenApp struct {
+	ClientID       string   `json:"clientId"`
+	DisplayName    string   `json:"displayText,omitempty"`
+	Anonymous      bool     `json:"anonymous,omitempty"`
+	NativeApp      bool     `json:"nativeApp,omitempty"`
+	UserCount      int      `json:"userCount"`
+	HighRisk       bool     `json:"highRisk"`
+	RiskCategories []string `json:"riskCategories,omitempty"`
+	HighRiskScopes []string `json:"highRiskScopes,omitempty"`
+	Scopes         []string `json:"scopes,omitempty"`
+	Allowed        bool     `json:"allowed"`
+	Users          []string `json:"users,omitempty"`
+}
+
+// ScopeRiskCategory returns the risk category ("gmail", "drive" or "admin") of a high-risk scope or an empty string for all other scopes
+func ScopeRiskCategory(scope string) string {
+	if c, ok := highRiskScopes[scope]; ok {
+		return c
+	}
+	if strings.HasPrefix(scope, "https://www.googleapis.com/auth/admin.") {
+		return "admin"
+	}
+	return ""
+}
+
+// LoadTokenAllowlist reads a file containing one client ID per line.
+// Empty lines and everything after a "#" are ignored.
+func LoadTokenAllowlist(path string) (map[string]bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	allowlist := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		line = strings.TrimSpace(line)
+		if line != "" {
+			allowlist[line] = true
+		}
+	}
+	return allowlist, scanner.Err()
+}
+
+// AggregateTokens groups the tokens of users (keyed by user) by the client ID of the application they are issued to.
+// Apps are sorted by the number of users in descending order.
+func AggregateTokens(tokens map[string][]*admin.Token, allowlist map[string]bool) []*TokenApp {
+	apps := make(map[string]*TokenApp)
+	scopes := make(map[string]map[string]struct{})
+	for userKey, userTokens := range tokens {
+		for _, t := range userTokens {
+			app, ok := apps[t.ClientId]
+			if !ok {
+				app = &TokenApp{ClientID: t.ClientId, DisplayName: t.DisplayText, Anonymous: t.Anonymous, NativeApp: t.NativeApp, Allowed: allowlist[t.ClientId]}
+				apps[t.ClientId] = app
+				scopes[t.ClientId] = make(map[string]struct{})
+			}
+			if !gsmhelpers.Contains(userKey, app.Users) {
+				app.Users = append(app.Users, userKey)
+			}
+			for _, s := range t.Scopes {
+				scopes[t.ClientId][s] = struct{}{}
+			}
+		}
+	}
+	result := make([]*TokenApp, 0, len(apps))
+	for clientID, app := range apps {
+		categories := make(map[string]struct{})
+		for s := range scopes[clientID] {
+			app.Scopes = append(app.Scopes, s)
+			if c := ScopeRiskCategory(s); c != "" {
+				app.HighRiskScopes = append(app.HighRiskScopes, s)
+				categories[c] = struct{}{}
+			}
+		}
+		for c := range categories {
+			app.RiskCategories = append(app.RiskCategories, c)
+		}
+		sort.Strings(app.Scopes)
+		sort.Strings(app.HighRiskScopes)
+		sort.Strings(app.RiskCategories)
+		sort.Strings(app.Users)
+		app.HighRisk = len(app.HighRiskScopes) > 0
+		app.UserCount = len(app.Users)
+		result = append(result, app)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].UserCount != result[j].UserCount {
+			return result[i].UserCount > result[j].UserCount
+		}
+		return result[i].ClientID < result[j].ClientID
+	})
+	return result
+}
@@ -0,0 +1,253 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gsmadmin
+
+import (
+	"encoding/json"
+	"strings"
+
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// UserProperties are the flat user properties that external data sources (LDIF, HR exports) can be mapped to
+var UserProperties = []string{
+	"primaryEmail",
+	"fullName",
+	"givenName",
+	"familyName",
+	"workPhone",
+	"mobilePhone",
+	"title",
+	"department",
+	"costCenter",
+	"employeeId",
+	"manager",
+	"orgUnitPath",
+	"recoveryEmail",
+}
+
+// MappedAttribute returns the attribute (LDAP attribute or source field) that is mapped to a user property
+func MappedAttribute(attributeMap map[string]string, property string) string {
+	for k := range attributeMap {
+		if attributeMap[k] == property {
+			return k
+		}
+	}
+	return ""
+}
+
+// toMapSlice converts one of the untyped list properties of a user (phones, organizations, etc.) to a slice of maps
+func toMapSlice(v any) []map[string]any {
+	if v == nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var m []map[string]any
+	err = json.Unmarshal(b, &m)
+	if err != nil {
+		return nil
+	}
+	return m
+}
+
+// findByType returns the first element of a list property that has the specified type
+func findByType(list []map[string]any, t string) map[string]any {
+	for i := range list {
+		if list[i]["type"] == t {
+			return list[i]
+		}
+	}
+	return nil
+}
+
+// primaryOrFirst returns the primary element of a list property or the first one, if none is marked as primary
+func primaryOrFirst(list []map[string]any) map[string]any {
+	for i := range list {
+		if list[i]["primary"] == true {
+			return list[i]
+		}
+	}
+	if len(list) > 0 {
+		return list[0]
+	}
+	return nil
+}
+
+// stringValue returns the value of a key in a map as a string
+func stringValue(m map[string]any, key string) string {
+	if m == nil {
+		return ""
+	}
+	s, _ := m[key].(string)
+	return s
+}
+
+// setTypedValue sets the value of the first element of a list property with the specified type.
+// If the element does not exist, it is added. If value is empty, the element is removed.
+func setTypedValue(list []map[string]any, t, value string) []map[string]any {
+	for i := range list {
+		if list[i]["type"] != t {
+			continue
+		}
+		if value == "" {
+			return append(list[:i], list[i+1:]...)
+		}
+		list[i]["value"] = value
+		return list
+	}
+	if value == "" {
+		if list == nil {
+			return []map[string]any{}
+		}
+		return list
+	}
+	return append(list, map[string]any{"type": t, "value": value})
+}
+
+// GetUserProperty returns the value of a flat user property (see UserProperties)
+func GetUserProperty(user *admin.User, property string) string {
+	switch property {
+	case "primaryEmail":
+		return user.PrimaryEmail
+	case "fullName", "givenName", "familyName":
+		if user.Name == nil {
+			return ""
+		}
+		return map[string]string{"fullName": user.Name.FullName, "givenName": user.Name.GivenName, "familyName": user.Name.FamilyName}[property]
+	case "workPhone":
+		return stringValue(findByType(toMapSlice(user.Phones), "work"), "value")
+	case "mobilePhone":
+		return stringValue(findByType(toMapSlice(user.Phones), "mobile"), "value")
+	case "title", "department", "costCenter":
+		return stringValue(primaryOrFirst(toMapSlice(user.Organizations)), property)
+	case "employeeId":
+		return stringValue(findByType(toMapSlice(user.ExternalIds), "organization"), "value")
+	case "manager":
+		return stringValue(findByType(toMapSlice(user.Relations), "manager"), "value")
+	case "orgUnitPath":
+		return user.OrgUnitPath
+	case "recoveryEmail":
+		return user.RecoveryEmail
+	}
+	return ""
+}
+
+// PatchUserProperties returns a user object that can be used to update the specified flat user properties (see UserProperties) of a user.
+// List properties (phones, organizations, externalIds, relations) are based on the current values of the user,
+// so that only the entries affected by the properties are changed.
+// Empty values clear the property.
+func PatchUserProperties(current *admin.User, properties map[string]string) *admin.User {
+	user := &admin.User{}
+	var phones, organizations []map[string]any
+	var phonesChanged, organizationsChanged bool
+	for _, property := range UserProperties {
+		value, found := properties[property]
+		if !found {
+			continue
+		}
+		switch property {
+		case "primaryEmail":
+			user.PrimaryEmail = value
+		case "fullName", "givenName", "familyName":
+			if user.Name == nil {
+				user.Name = &admin.UserName{}
+				if current.Name != nil {
+					user.Name.GivenName = current.Name.GivenName
+					user.Name.FamilyName = current.Name.FamilyName
+				}
+			}
+			switch property {
+			case "fullName":
+				user.Name.FullName = value
+			case "givenName":
+				user.Name.GivenName = value
+			case "familyName":
+				user.Name.FamilyName = value
+			}
+		case "workPhone", "mobilePhone":
+			if !phonesChanged {
+				phones = toMapSlice(current.Phones)
+				phonesChanged = true
+			}
+			phones = setTypedValue(phones, strings.TrimSuffix(property, "Phone"), value)
+		case "title", "department", "costCenter":
+			if !organizationsChanged {
+				organizations = toMapSlice(current.Organizations)
+				organizationsChanged = true
+			}
+			organization := primaryOrFirst(organizations)
+			if organization == nil {
+				organization = map[string]any{"primary": true}
+				organizations = append(organizations, organization)
+			}
+			if value == "" {
+				delete(organization, property)
+			} else {
+				organization[property] = value
+			}
+		case "employeeId":
+			user.ExternalIds = setTypedValue(toMapSlice(current.ExternalIds), "organization", value)
+			user.ForceSendFields = append(user.ForceSendFields, "ExternalIds")
+		case "manager":
+			user.Relations = setTypedValue(toMapSlice(current.Relations), "manager", value)
+			user.ForceSendFields = append(user.ForceSendFields, "Relations")
+		case "orgUnitPath":
+			user.OrgUnitPath = value
+		case "recoveryEmail":
+			user.RecoveryEmail = value
+			if value == "" {
+				user.ForceSendFields = append(user.ForceSendFields, "RecoveryEmail")
+			}
+		}
+	}
+	if phonesChanged {
+		if len(phones) > 0 && primaryOrFirst(phones)["primary"] != true {
+			phones[0]["primary"] = true
+		}
+		user.Phones = phones
+		user.ForceSendFields = append(user.ForceSendFields, "Phones")
+	}
+	if organizationsChanged {
+		user.Organizations = organizations
+		user.ForceSendFields = append(user.ForceSendFields, "Organizations")
+	}
+	return user
+}
+
+// UserTemplateData returns the data of a user that can be used in templates (e.g. email signatures).
+// It contains all flat user properties (see UserProperties), as well as "customSchemas" (e.g. {{.customSchemas.Employment.Location}})
+// and the complete user object as "user".
+func UserTemplateData(user *admin.User) map[string]any {
+	data := make(map[string]any, len(UserProperties)+2)
+	for _, property := range UserProperties {
+		data[property] = GetUserProperty(user, property)
+	}
+	customSchemas := make(map[string]map[string]any, len(user.CustomSchemas))
+	for schema, fields := range user.CustomSchemas {
+		var m map[string]any
+		if json.Unmarshal(fields, &m) == nil {
+			customSchemas[schema] = m
+		}
+	}
+	data["customSchemas"] = customSchemas
+	data["user"] = user
+	return data
+}
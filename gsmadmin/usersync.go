@@ -0,0 +1,286 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gsmadmin
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hanneshayashi/gsm/gsmhelpers"
+
+	admin "google.golang.org/api/admin/directory/v1"
+	"gopkg.in/yaml.v3"
+)
+
+// UserSyncMapping configures how the records of an HR export are mapped to users
+type UserSyncMapping struct {
+	// Attributes maps the fields (columns) of the source to user properties (see UserProperties)
+	Attributes map[string]string `yaml:"attributes"`
+	// Protected contains email addresses and orgUnit paths (starting with "/") of users that are never changed
+	Protected []string `yaml:"protected"`
+}
+
+// UserSyncDiff represents the change of a single property
+type UserSyncDiff struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// UserSyncChange represents a change to a single user, planned by a user sync
+type UserSyncChange struct {
+	Action       string                   `json:"action"`
+	PrimaryEmail string                   `json:"primaryEmail"`
+	Changes      map[string]*UserSyncDiff `json:"changes,omitempty"`
+	Error        string                   `json:"error,omitempty"`
+	Current      *admin.User              `json:"-"`
+}
+
+// UserSyncPlan contains all changes planned by a user sync
+type UserSyncPlan struct {
+	SourceRecords  int `json:"sourceRecords"`
+	DirectoryUsers int `json:"directoryUsers"`
+	Protected      int `json:"protected"`
+	// Active is the number of users in the directory that are neither suspended nor protected, i.e. the users that can be suspended by the sync
+	Active  int               `json:"active"`
+	Changes []*UserSyncChange `json:"changes"`
+	Errors  []string          `json:"errors,omitempty"`
+}
+
+// LoadUserSyncMapping reads a YAML file that configures a user sync, e.g.:
+//
+//	attributes:
+//	  Email: primaryEmail
+//	  First Name: givenName
+//	  Last Name: familyName
+//	  Job Title: title
+//	protected:
+//	  - admin@example.com
+//	  - /Service Accounts
+func LoadUserSyncMapping(path string) (*UserSyncMapping, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	mapping := &UserSyncMapping{}
+	err = yaml.Unmarshal(content, mapping)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing mapping %s: %v", path, err)
+	}
+	if MappedAttribute(mapping.Attributes, "primaryEmail") == "" {
+		return nil, fmt.Errorf("mapping %s does not map any field to primaryEmail", path)
+	}
+	for k := range mapping.Attributes {
+		if !gsmhelpers.Contains(mapping.Attributes[k], UserProperties) {
+			return nil, fmt.Errorf("unknown user property %q for field %s. Must be one of %s", mapping.Attributes[k], k, strings.Join(UserProperties, ", "))
+		}
+	}
+	return mapping, nil
+}
+
+// IsProtected returns true if a user must not be changed by a user sync.
+// Super administrators and delegated administrators are always protected.
+func (m *UserSyncMapping) IsProtected(user *admin.User) bool {
+	if user.IsAdmin || user.IsDelegatedAdmin {
+		return true
+	}
+	for _, p := range m.Protected {
+		if strings.HasPrefix(p, "/") {
+			ou := strings.ToLower(strings.TrimSuffix(p, "/"))
+			path := strings.ToLower(user.OrgUnitPath)
+			if ou == "" || path == ou || strings.HasPrefix(path, ou+"/") {
+				return true
+			}
+		} else if strings.EqualFold(p, user.PrimaryEmail) {
+			return true
+		}
+	}
+	return false
+}
+
+// syncValuesEqual compares a value from the source to the current value of a user property
+func syncValuesEqual(property, a, b string) bool {
+	switch property {
+	case "manager", "recoveryEmail", "primaryEmail", "orgUnitPath":
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// PlanUserSync compares the records of an HR export to the current users and returns the changes required to bring the users in line with the source.
+// Users that are not present in the source are suspended, unless they are protected.
+// If clearEmpty is true, properties that are empty in the source are cleared. Otherwise, they are ignored.
+// If unsuspend is true, suspended users that are present in the source are unsuspended.
+// Managers that are not specified by an email address are resolved by their employeeId in the source.
+func PlanUserSync(records []map[string]string, users []*admin.User, mapping *UserSyncMapping, clearEmpty, unsuspend bool) *UserSyncPlan {
+	plan := &UserSyncPlan{
+		SourceRecords:  len(records),
+		DirectoryUsers: len(users),
+	}
+	source := make(map[string]map[string]string)
+	employees := make(map[string]string)
+	var emails []string
+	for i := range records {
+		properties := make(map[string]string)
+		for field, property := range mapping.Attributes {
+			properties[property] = strings.TrimSpace(records[i][field])
+		}
+		email := strings.ToLower(properties["primaryEmail"])
+		if email == "" {
+			plan.Errors = append(plan.Errors, fmt.Sprintf("record %d: no primaryEmail", i+1))
+			continue
+		}
+		if _, found := source[email]; found {
+			plan.Errors = append(plan.Errors, fmt.Sprintf("record %d: duplicate primaryEmail %s", i+1, email))
+			continue
+		}
+		source[email] = properties
+		emails = append(emails, email)
+		if properties["employeeId"] != "" {
+			employees[properties["employeeId"]] = properties["primaryEmail"]
+		}
+	}
+	for _, email := range emails {
+		manager := source[email]["manager"]
+		if manager == "" || strings.Contains(manager, "@") {
+			continue
+		}
+		if managerEmail, found := employees[manager]; found {
+			source[email]["manager"] = managerEmail
+		} else {
+			plan.Errors = append(plan.Errors, fmt.Sprintf("%s: unable to resolve manager %s", email, manager))
+			delete(source[email], "manager")
+		}
+	}
+	directory := make(map[string]*admin.User)
+	for i := range users {
+		directory[strings.ToLower(users[i].PrimaryEmail)] = users[i]
+		if !users[i].Suspended && !mapping.IsProtected(users[i]) {
+			plan.Active++
+		}
+	}
+	for _, email := range emails {
+		properties := source[email]
+		user, found := directory[email]
+		if !found {
+			change := &UserSyncChange{Action: "create", PrimaryEmail: properties["primaryEmail"], Changes: map[string]*UserSyncDiff{}}
+			for property, value := range properties {
+				if value != "" && property != "primaryEmail" {
+					change.Changes[property] = &UserSyncDiff{New: value}
+				}
+			}
+			if properties["givenName"] == "" || properties["familyName"] == "" {
+				change.Error = "given name and family name are required to create a user"
+			}
+			plan.Changes = append(plan.Changes, change)
+			continue
+		}
+		if mapping.IsProtected(user) {
+			plan.Protected++
+			continue
+		}
+		change := &UserSyncChange{Action: "update", PrimaryEmail: user.PrimaryEmail, Changes: map[string]*UserSyncDiff{}, Current: user}
+		for property, value := range properties {
+			if property == "primaryEmail" || (value == "" && !clearEmpty) {
+				continue
+			}
+			current := GetUserProperty(user, property)
+			if !syncValuesEqual(property, current, value) {
+				change.Changes[property] = &UserSyncDiff{Old: current, New: value}
+			}
+		}
+		if unsuspend && user.Suspended {
+			change.Changes["suspended"] = &UserSyncDiff{Old: "true", New: "false"}
+		}
+		if len(change.Changes) > 0 {
+			plan.Changes = append(plan.Changes, change)
+		}
+	}
+	for i := range users {
+		if _, found := source[strings.ToLower(users[i].PrimaryEmail)]; found || users[i].Suspended {
+			continue
+		}
+		if mapping.IsProtected(users[i]) {
+			plan.Protected++
+			continue
+		}
+		plan.Changes = append(plan.Changes, &UserSyncChange{
+			Action:       "suspend",
+			PrimaryEmail: users[i].PrimaryEmail,
+			Changes:      map[string]*UserSyncDiff{"suspended": {Old: "false", New: "true"}},
+			Current:      users[i],
+		})
+	}
+	sort.Slice(plan.Changes, func(i, j int) bool {
+		if plan.Changes[i].Action != plan.Changes[j].Action {
+			return plan.Changes[i].Action < plan.Changes[j].Action
+		}
+		return plan.Changes[i].PrimaryEmail < plan.Changes[j].PrimaryEmail
+	})
+	return plan
+}
+
+// Count returns the number of planned changes with the specified action
+func (p *UserSyncPlan) Count(action string) int {
+	n := 0
+	for i := range p.Changes {
+		if p.Changes[i].Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// UserSyncChangeToUser returns the user object that is used to apply a planned change
+func UserSyncChangeToUser(change *UserSyncChange) *admin.User {
+	current := change.Current
+	if current == nil {
+		current = &admin.User{}
+	}
+	properties := make(map[string]string)
+	for property := range change.Changes {
+		if property != "suspended" {
+			properties[property] = change.Changes[property].New
+		}
+	}
+	user := PatchUserProperties(current, properties)
+	if diff, found := change.Changes["suspended"]; found {
+		user.Suspended, _ = strconv.ParseBool(diff.New)
+		user.ForceSendFields = append(user.ForceSendFields, "Suspended")
+	}
+	if change.Action == "create" {
+		user.PrimaryEmail = change.PrimaryEmail
+	}
+	return user
+}
+
+// String returns a human-readable representation of a change, e.g. to be used in logs
+func (c *UserSyncChange) String() string {
+	properties := make([]string, 0, len(c.Changes))
+	for property := range c.Changes {
+		properties = append(properties, property)
+	}
+	sort.Strings(properties)
+	diffs := make([]string, len(properties))
+	for i, property := range properties {
+		diffs[i] = fmt.Sprintf("%s: %q -> %q", property, c.Changes[property].Old, c.Changes[property].New)
+	}
+	return fmt.Sprintf("%s %s (%s)", c.Action, c.PrimaryEmail, strings.Join(diffs, ", "))
+}
@@ -0,0 +1,490 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gsmadmin
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const gdRelPrefix = "http://schemas.google.com/g/2005#"
+
+// vCardProperty is a single (unfolded) content line of a vCard
+type vCardProperty struct {
+	name   string
+	params map[string][]string
+	value  string
+}
+
+// types returns the lower case values of the TYPE parameter, including vCard 2.1 style parameters without a name
+func (p *vCardProperty) types() []string {
+	var types []string
+	for _, t := range p.params["TYPE"] {
+		for v := range strings.SplitSeq(t, ",") {
+			types = append(types, strings.ToLower(strings.TrimSpace(v)))
+		}
+	}
+	return types
+}
+
+func (p *vCardProperty) hasType(t ...string) bool {
+	for _, pt := range p.types() {
+		for i := range t {
+			if pt == t[i] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *vCardProperty) preferred() bool {
+	return p.hasType("pref") || len(p.params["PREF"]) > 0
+}
+
+// unfoldVCardLines reads the content lines of a vCard stream, joining folded lines
+func unfoldVCardLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// parseVCardProperty parses a content line in the form group.NAME;PARAM=value:value
+func parseVCardProperty(line string) (*vCardProperty, error) {
+	var inQuotes bool
+	colon := -1
+	for i, c := range line {
+		if c == '"' {
+			inQuotes = !inQuotes
+		} else if c == ':' && !inQuotes {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return nil, fmt.Errorf("invalid content line: %s", line)
+	}
+	parts := strings.Split(line[:colon], ";")
+	p := &vCardProperty{params: make(map[string][]string), value: line[colon+1:]}
+	p.name = strings.ToUpper(parts[0])
+	if i := strings.LastIndex(p.name, "."); i >= 0 {
+		p.name = p.name[i+1:]
+	}
+	for _, param := range parts[1:] {
+		k, v, ok := strings.Cut(param, "=")
+		if !ok {
+			// vCard 2.1 allows types without the TYPE= prefix
+			k, v = "TYPE", param
+		}
+		p.params[strings.ToUpper(k)] = append(p.params[strings.ToUpper(k)], strings.Trim(v, `"`))
+	}
+	return p, nil
+}
+
+// splitVCardValue splits a structured value at unescaped separators and unescapes the components
+func splitVCardValue(value string, sep rune) []string {
+	var parts []string
+	var b strings.Builder
+	escaped := false
+	for _, c := range value {
+		switch {
+		case escaped:
+			switch c {
+			case 'n', 'N':
+				b.WriteRune('\n')
+			default:
+				b.WriteRune(c)
+			}
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == sep:
+			parts = append(parts, b.String())
+			b.Reset()
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return append(parts, b.String())
+}
+
+func unescapeVCardValue(value string) string {
+	return splitVCardValue(value, 0)[0]
+}
+
+func escapeVCardValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ",", `\,`, ";", `\;`, "\r\n", `\n`, "\n", `\n`).Replace(value)
+}
+
+func component(parts []string, i int) string {
+	if i < len(parts) {
+		return strings.TrimSpace(parts[i])
+	}
+	return ""
+}
+
+// primaryFlag returns "true" for the first preferred value of a property and "false" for all others,
+// because a shared contact may only have one primary value of each kind
+func primaryFlag(p *vCardProperty, seen map[string]bool) string {
+	if !p.preferred() || seen[p.name] {
+		return "false"
+	}
+	seen[p.name] = true
+	return "true"
+}
+
+// vCardRel maps the TYPE parameter of a vCard property to a GData rel
+func vCardRel(p *vCardProperty, fallback string) string {
+	switch {
+	case p.hasType("cell", "mobile"):
+		return gdRelPrefix + "mobile"
+	case p.hasType("fax") && p.hasType("home"):
+		return gdRelPrefix + "home_fax"
+	case p.hasType("fax"):
+		return gdRelPrefix + "work_fax"
+	case p.hasType("pager"):
+		return gdRelPrefix + "pager"
+	case p.hasType("work"):
+		return gdRelPrefix + "work"
+	case p.hasType("home"):
+		return gdRelPrefix + "home"
+	}
+	return gdRelPrefix + fallback
+}
+
+// relType maps a GData rel back to a vCard TYPE
+func relType(rel, label string) string {
+	switch strings.TrimPrefix(rel, gdRelPrefix) {
+	case "mobile":
+		return "cell"
+	case "home_fax":
+		return "home,fax"
+	case "work_fax", "fax":
+		return "work,fax"
+	case "pager":
+		return "pager"
+	case "work":
+		return "work"
+	case "home":
+		return "home"
+	}
+	switch strings.ToLower(label) {
+	case "work", "home":
+		return strings.ToLower(label)
+	case "mobile":
+		return "cell"
+	}
+	return ""
+}
+
+// vCardToEntry converts the properties of a single vCard to a shared contact
+func vCardToEntry(properties []*vCardProperty) *Entry {
+	entry := &Entry{}
+	org := Organization{Rel: gdRelPrefix + "work"}
+	primary := make(map[string]bool)
+	for _, p := range properties {
+		switch p.name {
+		case "FN":
+			entry.Name.FullName = unescapeVCardValue(p.value)
+		case "N":
+			n := splitVCardValue(p.value, ';')
+			entry.Name.FamilyName = component(n, 0)
+			entry.Name.GivenName = component(n, 1)
+			entry.Name.AdditionalName = component(n, 2)
+			entry.Name.NamePrefix = component(n, 3)
+			entry.Name.NameSuffix = component(n, 4)
+		case "ORG":
+			o := splitVCardValue(p.value, ';')
+			org.OrgName = component(o, 0)
+			org.OrgDepartment = component(o, 1)
+		case "TITLE":
+			org.OrgTitle = unescapeVCardValue(p.value)
+		case "ROLE":
+			org.OrgJobDescription = unescapeVCardValue(p.value)
+		case "EMAIL":
+			address := strings.TrimPrefix(strings.TrimSpace(unescapeVCardValue(p.value)), "mailto:")
+			if address == "" {
+				continue
+			}
+			entry.Email = append(entry.Email, Email{Address: address, Rel: vCardRel(p, "other"), Primary: primaryFlag(p, primary)})
+		case "TEL":
+			number := strings.TrimPrefix(strings.TrimSpace(unescapeVCardValue(p.value)), "tel:")
+			if number == "" {
+				continue
+			}
+			entry.PhoneNumber = append(entry.PhoneNumber, PhoneNumber{PhoneNumber: number, Rel: vCardRel(p, "other"), Primary: primaryFlag(p, primary)})
+		case "ADR":
+			a := splitVCardValue(p.value, ';')
+			address := StructuredPostalAddress{
+				Pobox:     component(a, 0),
+				Housename: component(a, 1),
+				Street:    component(a, 2),
+				City:      component(a, 3),
+				Region:    component(a, 4),
+				Postcode:  component(a, 5),
+				Country:   component(a, 6),
+				MailClass: gdRelPrefix + "both",
+				Primary:   primaryFlag(p, primary),
+			}
+			address.Label = "Other"
+			switch {
+			case p.hasType("work"):
+				address.Label = "Work"
+			case p.hasType("home"):
+				address.Label = "Home"
+			}
+			if l := p.params["LABEL"]; len(l) > 0 {
+				address.FormattedAddress = unescapeVCardValue(l[0])
+			}
+			entry.StructuredPostalAddress = append(entry.StructuredPostalAddress, address)
+		case "URL":
+			href := strings.TrimSpace(unescapeVCardValue(p.value))
+			if href == "" {
+				continue
+			}
+			rel := "other"
+			switch {
+			case p.hasType("work"):
+				rel = "work"
+			case p.hasType("home"):
+				rel = "home-page"
+			}
+			entry.Website = append(entry.Website, Website{Href: href, Rel: rel, Primary: primaryFlag(p, primary)})
+		case "NOTE":
+			entry.Content = unescapeVCardValue(p.value)
+		}
+	}
+	if org.OrgName != "" || org.OrgDepartment != "" || org.OrgTitle != "" || org.OrgJobDescription != "" {
+		entry.Organization = []Organization{org}
+	}
+	if entry.Name.FullName == "" {
+		entry.Name.FullName = strings.Join(strings.Fields(strings.Join([]string{entry.Name.NamePrefix, entry.Name.GivenName, entry.Name.AdditionalName, entry.Name.FamilyName, entry.Name.NameSuffix}, " ")), " ")
+	}
+	return entry
+}
+
+// ParseVCards parses vCard 3.0 and 4.0 (and most 2.1) files and converts every vCard to a shared contact.
+// Supported properties are FN, N, ORG, TITLE, ROLE, EMAIL, TEL, ADR, URL and NOTE.
+func ParseVCards(r io.Reader) ([]*Entry, error) {
+	lines, err := unfoldVCardLines(r)
+	if err != nil {
+		return nil, err
+	}
+	var entries []*Entry
+	var current []*vCardProperty
+	inCard := false
+	for _, line := range lines {
+		p, err := parseVCardProperty(line)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case p.name == "BEGIN" && strings.EqualFold(p.value, "VCARD"):
+			inCard = true
+			current = nil
+		case p.name == "END" && strings.EqualFold(p.value, "VCARD"):
+			if !inCard {
+				return nil, fmt.Errorf("END:VCARD without BEGIN:VCARD")
+			}
+			entries = append(entries, vCardToEntry(current))
+			inCard = false
+		case inCard:
+			current = append(current, p)
+		}
+	}
+	if inCard {
+		return nil, fmt.Errorf("vCard is not terminated with END:VCARD")
+	}
+	return entries, nil
+}
+
+// vCardWriter writes folded content lines
+type vCardWriter struct {
+	w   io.Writer
+	err error
+}
+
+// line writes a content line, folding it after 75 octets (without splitting UTF-8 sequences)
+func (v *vCardWriter) line(s string) {
+	if v.err != nil {
+		return
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range s {
+		l := len(string(c))
+		if n+l > 75 {
+			b.WriteString("\r\n ")
+			n = 1
+		}
+		b.WriteRune(c)
+		n += l
+	}
+	b.WriteString("\r\n")
+	_, v.err = io.WriteString(v.w, b.String())
+}
+
+func (v *vCardWriter) property(name, types string, pref bool, version, value string) {
+	var params string
+	if version == "4.0" {
+		if types != "" {
+			params += ";TYPE=" + types
+		}
+		if pref {
+			params += ";PREF=1"
+		}
+	} else {
+		if pref {
+			if types != "" {
+				types += ","
+			}
+			types += "pref"
+		}
+		if types != "" {
+			params += ";TYPE=" + types
+		}
+	}
+	v.line(name + params + ":" + value)
+}
+
+// WriteVCards writes the shared contacts as vCards of the specified version ("3.0" or "4.0")
+func WriteVCards(w io.Writer, entries []Entry, version string) error {
+	if version != "3.0" && version != "4.0" {
+		return fmt.Errorf("unsupported vCard version %s. Must be 3.0 or 4.0", version)
+	}
+	v := &vCardWriter{w: w}
+	for i := range entries {
+		e := &entries[i]
+		v.line("BEGIN:VCARD")
+		v.line("VERSION:" + version)
+		fn := e.Name.FullName
+		if fn == "" {
+			fn = e.Title.Text
+		}
+		if fn == "" && len(e.Email) > 0 {
+			fn = e.Email[0].Address
+		}
+		v.line("FN:" + escapeVCardValue(fn))
+		n := []string{e.Name.FamilyName, e.Name.GivenName, e.Name.AdditionalName, e.Name.NamePrefix, e.Name.NameSuffix}
+		for j := range n {
+			n[j] = escapeVCardValue(n[j])
+		}
+		v.line("N:" + strings.Join(n, ";"))
+		for _, o := range e.Organization {
+			if o.OrgName != "" || o.OrgDepartment != "" {
+				v.line("ORG:" + escapeVCardValue(o.OrgName) + ";" + escapeVCardValue(o.OrgDepartment))
+			}
+			if o.OrgTitle != "" {
+				v.line("TITLE:" + escapeVCardValue(o.OrgTitle))
+			}
+			if o.OrgJobDescription != "" {
+				v.line("ROLE:" + escapeVCardValue(o.OrgJobDescription))
+			}
+		}
+		for _, m := range e.Email {
+			v.property("EMAIL", relType(m.Rel, m.Label), m.Primary == "true", version, escapeVCardValue(m.Address))
+		}
+		for _, p := range e.PhoneNumber {
+			v.property("TEL", relType(p.Rel, p.Label), p.Primary == "true", version, escapeVCardValue(p.PhoneNumber))
+		}
+		for _, a := range e.StructuredPostalAddress {
+			adr := []string{a.Pobox, a.Housename, a.Street, a.City, a.Region, a.Postcode, a.Country}
+			for j := range adr {
+				adr[j] = escapeVCardValue(adr[j])
+			}
+			v.property("ADR", relType(a.Usage, a.Label), a.Primary == "true", version, strings.Join(adr, ";"))
+		}
+		for _, u := range e.Website {
+			t := ""
+			switch u.Rel {
+			case "work":
+				t = "work"
+			case "home", "home-page":
+				t = "home"
+			}
+			v.property("URL", t, u.Primary == "true", version, escapeVCardValue(u.Href))
+		}
+		if e.Content != "" {
+			v.line("NOTE:" + escapeVCardValue(e.Content))
+		}
+		if e.ID != "" {
+			v.line("UID:" + escapeVCardValue(e.ID))
+		}
+		v.line("END:VCARD")
+	}
+	return v.err
+}
+
+// SharedContactEmails returns the lower case email addresses of a shared contact
+func SharedContactEmails(e *Entry) []string {
+	emails := make([]string, 0, len(e.Email))
+	for i := range e.Email {
+		if a := strings.ToLower(strings.TrimSpace(e.Email[i].Address)); a != "" {
+			emails = append(emails, a)
+		}
+	}
+	return emails
+}
+
+// SharedContactURL returns the URL that can be used to get, update or delete a shared contact
+func SharedContactURL(e *Entry) string {
+	for i := range e.Link {
+		if e.Link[i].Rel == "self" {
+			return e.Link[i].Href
+		}
+	}
+	return strings.Replace(strings.Replace(e.ID, "http://", "https://", 1), "/base/", "/full/", 1)
+}
+
+// MergeSharedContact copies all properties that are set in source to target
+func MergeSharedContact(target, source *Entry) {
+	if source.Name != (Name{}) {
+		target.Name = source.Name
+	}
+	if len(source.Email) > 0 {
+		target.Email = source.Email
+	}
+	if len(source.PhoneNumber) > 0 {
+		target.PhoneNumber = source.PhoneNumber
+	}
+	if len(source.StructuredPostalAddress) > 0 {
+		target.StructuredPostalAddress = source.StructuredPostalAddress
+	}
+	if len(source.Organization) > 0 {
+		target.Organization = source.Organization
+	}
+	if len(source.Website) > 0 {
+		target.Website = source.Website
+	}
+	if source.Content != "" {
+		target.Content = source.Content
+	}
+}
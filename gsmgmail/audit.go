@@ -0,0 +1,42 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gsmgmail
+
+import (
+	"net/mail"
+	"strings"
+)
+
+// AddressDomain returns the (lower case) domain of an email address or an empty string if the address is invalid
+func AddressDomain(address string) string {
+	if a, err := mail.ParseAddress(address); err == nil {
+		address = a.Address
+	}
+	i := strings.LastIndex(address, "@")
+	if i < 0 {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(address[i+1:]))
+}
+
+// IsExternalAddress returns true if the domain of the address is not one of the given (lower case) domains.
+// Addresses without a valid domain are considered external.
+func IsExternalAddress(address string, domains map[string]bool) bool {
+	domain := AddressDomain(address)
+	return domain == "" || !domains[domain]
+}
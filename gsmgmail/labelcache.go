@@ -0,0 +1,150 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gsmgmail
+
+import (
+	"strings"
+	"sync"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// systemLabelNames maps the names of system labels (as used in the API and in Google Takeout) to their IDs.
+// Names that map to an empty ID (e.g. "Archived") don't correspond to a label and are ignored.
+var systemLabelNames = map[string]string{
+	"inbox":               "INBOX",
+	"sent":                "SENT",
+	"sent mail":           "SENT",
+	"draft":               "DRAFT",
+	"drafts":              "DRAFT",
+	"spam":                "SPAM",
+	"trash":               "TRASH",
+	"starred":             "STARRED",
+	"unread":              "UNREAD",
+	"important":           "IMPORTANT",
+	"chat":                "CHAT",
+	"category_personal":   "CATEGORY_PERSONAL",
+	"category personal":   "CATEGORY_PERSONAL",
+	"category_social":     "CATEGORY_SOCIAL",
+	"category social":     "CATEGORY_SOCIAL",
+	"category_promotions": "CATEGORY_PROMOTIONS",
+	"category promotions": "CATEGORY_PROMOTIONS",
+	"category_updates":    "CATEGORY_UPDATES",
+	"category updates":    "CATEGORY_UPDATES",
+	"category_forums":     "CATEGORY_FORUMS",
+	"category forums":     "CATEGORY_FORUMS",
+	"opened":              "",
+	"archived":            "",
+	"archive":             "",
+}
+
+// SystemLabelID returns the ID of a system label by its name.
+// ok is true for names of system labels, id may be empty for names that don't correspond to a label (e.g. "Archived").
+func SystemLabelID(name string) (id string, ok bool) {
+	id, ok = systemLabelNames[strings.ToLower(strings.TrimSpace(name))]
+	return id, ok
+}
+
+// LabelCache resolves label names to label IDs for a user and creates missing labels.
+// It is safe for concurrent use.
+type LabelCache struct {
+	userID string
+	mu     sync.Mutex
+	ids    map[string]string
+	labels []*gmail.Label
+}
+
+// NewLabelCache lists the labels of the user and returns a new LabelCache
+func NewLabelCache(userID string) (*LabelCache, error) {
+	labels, err := ListLabels(userID, "labels(id,name,type)")
+	if err != nil {
+		return nil, err
+	}
+	c := &LabelCache{userID: userID, ids: make(map[string]string), labels: labels}
+	for _, l := range labels {
+		c.ids[strings.ToLower(l.Name)] = l.Id
+	}
+	return c, nil
+}
+
+// Labels returns the labels that are known to the cache (including labels created by it)
+func (c *LabelCache) Labels() []*gmail.Label {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*gmail.Label(nil), c.labels...)
+}
+
+// ID returns the ID of the label with the given name (e.g. "Projects/Alpha").
+// System labels are resolved by their name (see SystemLabelID).
+// If the label doesn't exist and create is true, the label and its missing parents are created.
+// An empty ID without an error is returned if the name doesn't correspond to a label (and create is false or the name is ignored).
+func (c *LabelCache) ID(name string, create bool) (string, error) {
+	name = strings.Trim(strings.TrimSpace(name), "/")
+	if name == "" {
+		return "", nil
+	}
+	systemID, system := SystemLabelID(name)
+	if systemID != "" {
+		return systemID, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Names like "Archive" are only ignored if the user doesn't have a label with that name
+	return c.id(name, create && !system)
+}
+
+func (c *LabelCache) id(name string, create bool) (string, error) {
+	if id, ok := c.ids[strings.ToLower(name)]; ok || !create {
+		return id, nil
+	}
+	if i := strings.LastIndex(name, "/"); i > 0 {
+		_, err := c.id(name[:i], true)
+		if err != nil {
+			return "", err
+		}
+	}
+	label, err := CreateLabel(c.userID, "id,name,type", &gmail.Label{Name: name, LabelListVisibility: "labelShow", MessageListVisibility: "show"})
+	if err != nil {
+		return "", err
+	}
+	c.ids[strings.ToLower(label.Name)] = label.Id
+	c.labels = append(c.labels, label)
+	return label.Id, nil
+}
+
+// Add adds a label that was created or renamed outside of the cache.
+// If the label is already known by its ID, the previous name no longer resolves to it.
+func (c *LabelCache) Add(id, name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, l := range c.labels {
+		if l.Id != id {
+			continue
+		}
+		if old := strings.ToLower(l.Name); c.ids[old] == id {
+			delete(c.ids, old)
+		}
+		renamed := *l
+		renamed.Name = name
+		c.labels[i] = &renamed
+		c.ids[strings.ToLower(name)] = id
+		return
+	}
+	c.ids[strings.ToLower(name)] = id
+	c.labels = append(c.labels, &gmail.Label{Id: id, Name: name, Type: "user"})
+}
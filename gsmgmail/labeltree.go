@@ -0,0 +1,139 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gsmgmail
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// LabelTreeNode is a label in the tree of nested labels.
+// Nodes for parents that don't exist as labels (e.g. "a" for the label "a/b") have no ID.
+type LabelTreeNode struct {
+	ID             string           `json:"id,omitempty"`
+	Name           string           `json:"name"`
+	Path           string           `json:"path"`
+	MessagesTotal  int64            `json:"messagesTotal"`
+	MessagesUnread int64            `json:"messagesUnread"`
+	Children       []*LabelTreeNode `json:"children,omitempty"`
+}
+
+// LabelParents returns the names of the parents of a nested label, e.g. ["a", "a/b"] for "a/b/c"
+func LabelParents(name string) []string {
+	var parents []string
+	for i := range len(name) {
+		if name[i] == '/' && i > 0 {
+			parents = append(parents, name[:i])
+		}
+	}
+	return parents
+}
+
+// IsLabelDescendant returns true if the label name is nested under (but not equal to) the parent name
+func IsLabelDescendant(name, parent string) bool {
+	return len(name) > len(parent)+1 && strings.EqualFold(name[:len(parent)+1], parent+"/")
+}
+
+// FindLabelByName returns the user label with the given name (case-insensitive) or nil
+func FindLabelByName(labels []*gmail.Label, name string) *gmail.Label {
+	for _, l := range labels {
+		if l.Type != "system" && strings.EqualFold(l.Name, name) {
+			return l
+		}
+	}
+	return nil
+}
+
+// LabelDescendants returns all user labels that are nested under the parent name, sorted by depth
+func LabelDescendants(labels []*gmail.Label, parent string) []*gmail.Label {
+	var descendants []*gmail.Label
+	for _, l := range labels {
+		if l.Type != "system" && IsLabelDescendant(l.Name, parent) {
+			descendants = append(descendants, l)
+		}
+	}
+	sort.SliceStable(descendants, func(i, j int) bool {
+		return strings.Count(descendants[i].Name, "/") < strings.Count(descendants[j].Name, "/")
+	})
+	return descendants
+}
+
+// BuildLabelTree builds the tree of the user labels. The nodes are sorted by name.
+func BuildLabelTree(labels []*gmail.Label) []*LabelTreeNode {
+	root := &LabelTreeNode{}
+	nodes := map[string]*LabelTreeNode{"": root}
+	var node func(path string) *LabelTreeNode
+	node = func(path string) *LabelTreeNode {
+		key := strings.ToLower(path)
+		if n, ok := nodes[key]; ok {
+			return n
+		}
+		parent := ""
+		name := path
+		if i := strings.LastIndex(path, "/"); i > 0 {
+			parent, name = path[:i], path[i+1:]
+		}
+		n := &LabelTreeNode{Name: name, Path: path}
+		p := node(parent)
+		p.Children = append(p.Children, n)
+		nodes[key] = n
+		return n
+	}
+	for _, l := range labels {
+		if l.Type == "system" {
+			continue
+		}
+		n := node(l.Name)
+		n.ID = l.Id
+		n.MessagesTotal = l.MessagesTotal
+		n.MessagesUnread = l.MessagesUnread
+	}
+	for _, n := range nodes {
+		sort.Slice(n.Children, func(i, j int) bool {
+			return strings.ToLower(n.Children[i].Name) < strings.ToLower(n.Children[j].Name)
+		})
+	}
+	return root.Children
+}
+
+// WriteLabelTree writes the tree as indented text with the number of (unread) messages of each label
+func WriteLabelTree(w io.Writer, nodes []*LabelTreeNode) error {
+	var write func(nodes []*LabelTreeNode, indent string) error
+	write = func(nodes []*LabelTreeNode, indent string) error {
+		for _, n := range nodes {
+			counts := " (no label)"
+			if n.ID != "" {
+				counts = fmt.Sprintf(" (%d messages, %d unread)", n.MessagesTotal, n.MessagesUnread)
+			}
+			_, err := fmt.Fprintln(w, indent+n.Name+counts)
+			if err != nil {
+				return err
+			}
+			err = write(n.Children, indent+"  ")
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return write(nodes, "")
+}
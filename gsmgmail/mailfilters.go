@@ -0,0 +1,379 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gsmgmail
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// MailFilter is a filter from a mailFilters.xml file, as exported by the Gmail web interface.
+// Labels are the names of user labels, system labels are resolved to their IDs in the action.
+type MailFilter struct {
+	Criteria *gmail.FilterCriteria
+	Action   *gmail.FilterAction
+	Labels   []string
+	// Unsupported contains properties that can't be mapped to a filter (e.g. cannedResponse)
+	Unsupported []string
+}
+
+type mailFilterProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type mailFiltersFeedIn struct {
+	Entries []struct {
+		Properties []mailFilterProperty `xml:"property"`
+	} `xml:"entry"`
+}
+
+// mailFilterSizeUnits are the size units of mailFilters.xml
+var mailFilterSizeUnits = []struct {
+	unit  string
+	bytes int64
+}{{"s_smb", 1024 * 1024}, {"s_skb", 1024}, {"s_sb", 1}}
+
+// mailFilterSmartLabels maps the smartLabelToApply values of mailFilters.xml to category labels
+var mailFilterSmartLabels = map[string]string{
+	"^smartlabel_personal":     "CATEGORY_PERSONAL",
+	"^smartlabel_social":       "CATEGORY_SOCIAL",
+	"^smartlabel_promo":        "CATEGORY_PROMOTIONS",
+	"^smartlabel_notification": "CATEGORY_UPDATES",
+	"^smartlabel_group":        "CATEGORY_FORUMS",
+}
+
+// mailFilterLabelActions maps the boolean properties of mailFilters.xml to labels that are added (true) or removed (false)
+var mailFilterLabelActions = []struct {
+	property string
+	labelID  string
+	add      bool
+}{
+	{"shouldArchive", "INBOX", false},
+	{"shouldMarkAsRead", "UNREAD", false},
+	{"shouldNeverSpam", "SPAM", false},
+	{"shouldNeverMarkAsImportant", "IMPORTANT", false},
+	{"shouldStar", "STARRED", true},
+	{"shouldTrash", "TRASH", true},
+	{"shouldAlwaysMarkAsImportant", "IMPORTANT", true},
+}
+
+// ParseMailFilters parses a mailFilters.xml file
+func ParseMailFilters(r io.Reader) ([]*MailFilter, error) {
+	feed := &mailFiltersFeedIn{}
+	err := xml.NewDecoder(r).Decode(feed)
+	if err != nil {
+		return nil, err
+	}
+	filters := make([]*MailFilter, 0, len(feed.Entries))
+	for i, e := range feed.Entries {
+		f := &MailFilter{Criteria: &gmail.FilterCriteria{}, Action: &gmail.FilterAction{}}
+		properties := make(map[string]string)
+		for _, p := range e.Properties {
+			properties[p.Name] = p.Value
+		}
+		var sizeUnit int64 = 1
+		for name, value := range properties {
+			switch name {
+			case "from":
+				f.Criteria.From = value
+			case "to":
+				f.Criteria.To = value
+			case "subject":
+				f.Criteria.Subject = value
+			case "hasTheWord":
+				f.Criteria.Query = value
+			case "doesNotHaveTheWord":
+				f.Criteria.NegatedQuery = value
+			case "hasAttachment":
+				f.Criteria.HasAttachment = value == "true"
+			case "excludeChats":
+				f.Criteria.ExcludeChats = value == "true"
+			case "size":
+				f.Criteria.Size, err = strconv.ParseInt(value, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("filter %d: invalid size %q", i+1, value)
+				}
+			case "sizeOperator":
+				switch value {
+				case "s_sl":
+					f.Criteria.SizeComparison = "larger"
+				case "s_ss":
+					f.Criteria.SizeComparison = "smaller"
+				}
+			case "sizeUnit":
+				for _, u := range mailFilterSizeUnits {
+					if u.unit == value {
+						sizeUnit = u.bytes
+					}
+				}
+			case "label":
+				if id, ok := SystemLabelID(value); ok && id != "" {
+					f.Action.AddLabelIds = append(f.Action.AddLabelIds, id)
+				} else {
+					f.Labels = append(f.Labels, value)
+				}
+			case "forwardTo":
+				f.Action.Forward = value
+			case "smartLabelToApply":
+				if id, ok := mailFilterSmartLabels[value]; ok {
+					f.Action.AddLabelIds = append(f.Action.AddLabelIds, id)
+				} else {
+					f.Unsupported = append(f.Unsupported, name)
+				}
+			default:
+				handled := false
+				for _, a := range mailFilterLabelActions {
+					if a.property != name {
+						continue
+					}
+					handled = true
+					if value != "true" {
+						continue
+					}
+					if a.add {
+						f.Action.AddLabelIds = append(f.Action.AddLabelIds, a.labelID)
+					} else {
+						f.Action.RemoveLabelIds = append(f.Action.RemoveLabelIds, a.labelID)
+					}
+				}
+				if !handled {
+					f.Unsupported = append(f.Unsupported, name)
+				}
+			}
+		}
+		f.Criteria.Size *= sizeUnit
+		slices.Sort(f.Action.AddLabelIds)
+		slices.Sort(f.Action.RemoveLabelIds)
+		slices.Sort(f.Unsupported)
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+// MergeMailFilters merges filters with identical criteria into a single filter.
+// The Gmail web interface exports a filter with multiple labels as multiple filters with the same criteria.
+func MergeMailFilters(filters []*MailFilter) []*MailFilter {
+	var merged []*MailFilter
+	for _, f := range filters {
+		i := slices.IndexFunc(merged, func(m *MailFilter) bool {
+			return FilterCriteriaEqual(m.Criteria, f.Criteria)
+		})
+		if i == -1 {
+			merged = append(merged, f)
+			continue
+		}
+		m := merged[i]
+		m.Labels = mergeUnique(m.Labels, f.Labels)
+		m.Action.AddLabelIds = mergeUnique(m.Action.AddLabelIds, f.Action.AddLabelIds)
+		m.Action.RemoveLabelIds = mergeUnique(m.Action.RemoveLabelIds, f.Action.RemoveLabelIds)
+		m.Unsupported = mergeUnique(m.Unsupported, f.Unsupported)
+		if m.Action.Forward == "" {
+			m.Action.Forward = f.Action.Forward
+		}
+	}
+	return merged
+}
+
+func mergeUnique(a, b []string) []string {
+	for _, s := range b {
+		if !slices.Contains(a, s) {
+			a = append(a, s)
+		}
+	}
+	return a
+}
+
+// FilterCriteriaEqual returns true if two filters have identical criteria
+func FilterCriteriaEqual(a, b *gmail.FilterCriteria) bool {
+	if a == nil {
+		a = &gmail.FilterCriteria{}
+	}
+	if b == nil {
+		b = &gmail.FilterCriteria{}
+	}
+	eq := func(x, y string) bool {
+		return strings.EqualFold(strings.TrimSpace(x), strings.TrimSpace(y))
+	}
+	return eq(a.From, b.From) && eq(a.To, b.To) && eq(a.Subject, b.Subject) && eq(a.Query, b.Query) && eq(a.NegatedQuery, b.NegatedQuery) &&
+		a.HasAttachment == b.HasAttachment && a.ExcludeChats == b.ExcludeChats && a.Size == b.Size && (a.Size == 0 || eq(a.SizeComparison, b.SizeComparison))
+}
+
+type mailFiltersFeedOut struct {
+	XMLName   xml.Name             `xml:"feed"`
+	Xmlns     string               `xml:"xmlns,attr"`
+	XmlnsApps string               `xml:"xmlns:apps,attr"`
+	Title     string               `xml:"title"`
+	ID        string               `xml:"id"`
+	Updated   string               `xml:"updated"`
+	Author    *mailFiltersAuthor   `xml:"author,omitempty"`
+	Entries   []mailFilterEntryOut `xml:"entry"`
+}
+
+type mailFiltersAuthor struct {
+	Email string `xml:"email"`
+}
+
+type mailFilterEntryOut struct {
+	Category struct {
+		Term string `xml:"term,attr"`
+	} `xml:"category"`
+	Title      string               `xml:"title"`
+	ID         string               `xml:"id"`
+	Updated    string               `xml:"updated"`
+	Content    string               `xml:"content"`
+	Properties []mailFilterProperty `xml:"apps:property"`
+}
+
+// mailFilterProperties converts a filter to the properties of mailFilters.xml.
+// A filter that adds more than one user label is converted to one set of properties per label,
+// because mailFilters.xml only supports a single label per filter.
+func mailFilterProperties(f *gmail.Filter, labelNames map[string]string) [][]mailFilterProperty {
+	var properties []mailFilterProperty
+	add := func(name, value string) {
+		if value != "" {
+			properties = append(properties, mailFilterProperty{Name: name, Value: value})
+		}
+	}
+	if c := f.Criteria; c != nil {
+		add("from", c.From)
+		add("to", c.To)
+		add("subject", c.Subject)
+		add("hasTheWord", c.Query)
+		add("doesNotHaveTheWord", c.NegatedQuery)
+		if c.HasAttachment {
+			add("hasAttachment", "true")
+		}
+		if c.ExcludeChats {
+			add("excludeChats", "true")
+		}
+		if c.Size > 0 {
+			for _, u := range mailFilterSizeUnits {
+				if c.Size%u.bytes == 0 {
+					add("size", strconv.FormatInt(c.Size/u.bytes, 10))
+					add("sizeUnit", u.unit)
+					break
+				}
+			}
+			if strings.EqualFold(c.SizeComparison, "smaller") {
+				add("sizeOperator", "s_ss")
+			} else {
+				add("sizeOperator", "s_sl")
+			}
+		}
+	}
+	var labels []string
+	if a := f.Action; a != nil {
+		for _, id := range a.AddLabelIds {
+			found := false
+			for _, la := range mailFilterLabelActions {
+				if la.add && la.labelID == id {
+					add(la.property, "true")
+					found = true
+				}
+			}
+			for smartLabel, labelID := range mailFilterSmartLabels {
+				if labelID == id {
+					add("smartLabelToApply", smartLabel)
+					found = true
+				}
+			}
+			if !found {
+				name, ok := labelNames[id]
+				if !ok {
+					name = id
+				}
+				labels = append(labels, name)
+			}
+		}
+		for _, id := range a.RemoveLabelIds {
+			for _, la := range mailFilterLabelActions {
+				if !la.add && la.labelID == id {
+					add(la.property, "true")
+				}
+			}
+		}
+		add("forwardTo", a.Forward)
+	}
+	if len(labels) == 0 {
+		return [][]mailFilterProperty{properties}
+	}
+	result := make([][]mailFilterProperty, len(labels))
+	for i := range labels {
+		p := properties
+		if i > 0 {
+			// Additional labels only repeat the criteria
+			p = nil
+			for _, prop := range properties {
+				if !strings.HasPrefix(prop.Name, "should") && prop.Name != "forwardTo" && prop.Name != "smartLabelToApply" {
+					p = append(p, prop)
+				}
+			}
+		}
+		result[i] = append(slices.Clone(p), mailFilterProperty{Name: "label", Value: labels[i]})
+	}
+	return result
+}
+
+// WriteMailFilters writes filters in the mailFilters.xml format that can be imported in the Gmail web interface.
+// labelNames maps label IDs to label names. author is optional.
+func WriteMailFilters(w io.Writer, filters []*gmail.Filter, labelNames map[string]string, author string) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	feed := &mailFiltersFeedOut{
+		Xmlns:     "http://www.w3.org/2005/Atom",
+		XmlnsApps: "http://schemas.google.com/apps/2006",
+		Title:     "Mail Filters",
+		Updated:   now,
+	}
+	if author != "" {
+		feed.Author = &mailFiltersAuthor{Email: author}
+	}
+	var ids []string
+	for _, f := range filters {
+		for i, properties := range mailFilterProperties(f, labelNames) {
+			id := f.Id
+			if i > 0 {
+				id = fmt.Sprintf("%s-%d", f.Id, i)
+			}
+			ids = append(ids, id)
+			e := mailFilterEntryOut{Title: "Mail Filter", ID: "tag:mail.google.com,2008:filter:" + id, Updated: now, Properties: properties}
+			e.Category.Term = "filter"
+			feed.Entries = append(feed.Entries, e)
+		}
+	}
+	feed.ID = "tag:mail.google.com,2008:filters:" + strings.Join(ids, ",")
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	err = enc.Encode(feed)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,126 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gsmgmail
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+// MailMergeTemplate renders messages from the fields of a record (e.g. a CSV line)
+type MailMergeTemplate struct {
+	subject     *texttemplate.Template
+	text        *texttemplate.Template
+	html        *htmltemplate.Template
+	attachments []*texttemplate.Template
+}
+
+// NewMailMergeTemplate parses the templates for a mail merge.
+// subject is a template string, textPath and htmlPath are paths to template files (at least one must be set).
+// attachments are template strings that are rendered to the paths of the files to attach.
+// All templates fail if they reference a field that does not exist.
+func NewMailMergeTemplate(subject, textPath, htmlPath string, attachments []string) (*MailMergeTemplate, error) {
+	if textPath == "" && htmlPath == "" {
+		return nil, fmt.Errorf("at least one of a text or HTML template is required")
+	}
+	t := &MailMergeTemplate{}
+	var err error
+	t.subject, err = texttemplate.New("subject").Option("missingkey=error").Parse(subject)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing subject template: %v", err)
+	}
+	if textPath != "" {
+		t.text, err = texttemplate.ParseFiles(textPath)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing text template: %v", err)
+		}
+		t.text.Option("missingkey=error")
+	}
+	if htmlPath != "" {
+		t.html, err = htmltemplate.ParseFiles(htmlPath)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing HTML template: %v", err)
+		}
+		t.html.Option("missingkey=error")
+	}
+	for i := range attachments {
+		a, err := texttemplate.New(fmt.Sprintf("attachment%d", i)).Option("missingkey=error").Parse(attachments[i])
+		if err != nil {
+			return nil, fmt.Errorf("error parsing attachment template %s: %v", attachments[i], err)
+		}
+		t.attachments = append(t.attachments, a)
+	}
+	return t, nil
+}
+
+// Render renders the message for a record.
+// The fields "to", "cc", "bcc", "from" and "replyTo" of the record are used as the headers of the message.
+// Attachment templates that render to an empty string are ignored, so attachments can be optional.
+func (t *MailMergeTemplate) Render(record map[string]string) (*MailMessage, error) {
+	m := &MailMessage{
+		To:      record["to"],
+		Cc:      record["cc"],
+		Bcc:     record["bcc"],
+		From:    record["from"],
+		ReplyTo: record["replyTo"],
+	}
+	if strings.TrimSpace(m.To) == "" {
+		return nil, fmt.Errorf("record has no recipient (to)")
+	}
+	var b strings.Builder
+	err := t.subject.Execute(&b, record)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering subject: %v", err)
+	}
+	m.Subject = strings.TrimSpace(b.String())
+	if t.text != nil {
+		b.Reset()
+		err = t.text.Execute(&b, record)
+		if err != nil {
+			return nil, fmt.Errorf("error rendering text body: %v", err)
+		}
+		m.Text = b.String()
+	}
+	if t.html != nil {
+		b.Reset()
+		err = t.html.Execute(&b, record)
+		if err != nil {
+			return nil, fmt.Errorf("error rendering HTML body: %v", err)
+		}
+		m.HTML = b.String()
+	}
+	for _, a := range t.attachments {
+		b.Reset()
+		err = a.Execute(&b, record)
+		if err != nil {
+			return nil, fmt.Errorf("error rendering attachment path: %v", err)
+		}
+		path := strings.TrimSpace(b.String())
+		if path == "" {
+			continue
+		}
+		part, err := ReadMailPart(path)
+		if err != nil {
+			return nil, err
+		}
+		m.Attachments = append(m.Attachments, part)
+	}
+	return m, nil
+}
@@ -0,0 +1,202 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gsmgmail
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/mail"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// MailExportManifestEntry describes an exported message.
+// For mbox exports, Offset and Size are the position of the message in the mbox file.
+type MailExportManifestEntry struct {
+	ID           string   `json:"id"`
+	ThreadID     string   `json:"threadId,omitempty"`
+	LabelIDs     []string `json:"labelIds,omitempty"`
+	Labels       []string `json:"labels,omitempty"`
+	InternalDate int64    `json:"internalDate,omitempty"`
+	SHA256       string   `json:"sha256"`
+	File         string   `json:"file"`
+	Offset       int64    `json:"offset,omitempty"`
+	Size         int64    `json:"size,omitempty"`
+}
+
+// ReadMailExportManifest reads a manifest (one JSON object per line).
+// A missing manifest is not an error. A truncated last line (e.g. after a crash) is ignored.
+func ReadMailExportManifest(path string) ([]*MailExportManifestEntry, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []*MailExportManifestEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		e := &MailExportManifestEntry{}
+		if json.Unmarshal(scanner.Bytes(), e) != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+var mboxFromLine = regexp.MustCompile(`(?m)^(>*From )`)
+
+// mboxSender returns the address of the sender of a raw message for the "From " line of an mbox file
+func mboxSender(raw []byte) string {
+	m, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err == nil {
+		if from, err := mail.ParseAddress(m.Header.Get("From")); err == nil {
+			return from.Address
+		}
+	}
+	return "MAILER-DAEMON"
+}
+
+// WriteMboxMessage writes a raw message to w in mboxrd format and returns the number of bytes written.
+// Line endings are converted to LF and lines starting with "From " (or ">From ", etc.) are quoted with ">".
+// If labels are given, they are added as an X-Gmail-Labels header, like in Google Takeout exports.
+func WriteMboxMessage(w io.Writer, raw []byte, date time.Time, labels []string) (int64, error) {
+	var buf bytes.Buffer
+	buf.WriteString("From " + mboxSender(raw) + " " + date.UTC().Format(time.ANSIC) + "\n")
+	if len(labels) > 0 {
+		quoted := make([]string, len(labels))
+		for i, l := range labels {
+			quoted[i] = l
+			if strings.ContainsAny(l, ",\"") {
+				quoted[i] = `"` + strings.ReplaceAll(l, `"`, `""`) + `"`
+			}
+		}
+		buf.WriteString("X-Gmail-Labels: " + strings.Join(quoted, ",") + "\n")
+	}
+	body := bytes.ReplaceAll(raw, []byte("\r\n"), []byte("\n"))
+	buf.Write(mboxFromLine.ReplaceAll(body, []byte(">$1")))
+	if !bytes.HasSuffix(body, []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+// MboxMessage is a message read from an mbox file
+type MboxMessage struct {
+	// Number is the position of the message in the file, starting at 1
+	Number int
+	Raw    []byte
+	// Labels are the labels from the X-Gmail-Labels header of the message (if any)
+	Labels []string
+}
+
+// MboxReader reads messages from an mbox file one at a time, so that large files don't have to be loaded into memory
+type MboxReader struct {
+	r       *bufio.Reader
+	started bool
+	number  int
+}
+
+// NewMboxReader returns a new MboxReader for r
+func NewMboxReader(r io.Reader) *MboxReader {
+	return &MboxReader{r: bufio.NewReaderSize(r, 64*1024)}
+}
+
+var mboxQuotedFromLine = regexp.MustCompile(`^>+From `)
+
+// Next returns the next message of the file or io.EOF if there are no more messages.
+// Lines starting with ">From " (">>From ", etc.) are unquoted (mboxrd).
+func (m *MboxReader) Next() (*MboxMessage, error) {
+	var buf bytes.Buffer
+	for {
+		line, err := m.r.ReadBytes('\n')
+		if len(line) > 0 {
+			switch {
+			case bytes.HasPrefix(line, []byte("From ")):
+				if m.started && buf.Len() > 0 {
+					m.number++
+					return newMboxMessage(m.number, buf.Bytes()), nil
+				}
+				m.started = true
+			case !m.started:
+				// Ignore anything before the first "From " line
+			case mboxQuotedFromLine.Match(line):
+				buf.Write(line[1:])
+			default:
+				buf.Write(line)
+			}
+		}
+		if err == io.EOF {
+			if buf.Len() == 0 {
+				return nil, io.EOF
+			}
+			m.number++
+			return newMboxMessage(m.number, buf.Bytes()), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func newMboxMessage(number int, raw []byte) *MboxMessage {
+	// The writer adds an empty line after every message
+	raw = bytes.TrimSuffix(raw, []byte("\n"))
+	raw = bytes.TrimSuffix(raw, []byte("\r"))
+	message := &MboxMessage{Number: number, Raw: raw}
+	if m, err := mail.ReadMessage(bytes.NewReader(raw)); err == nil {
+		message.Labels = ParseGmailLabels(m.Header.Get("X-Gmail-Labels"))
+	}
+	return message
+}
+
+// ParseGmailLabels parses the value of an X-Gmail-Labels header (comma separated, labels that contain commas are quoted)
+func ParseGmailLabels(header string) []string {
+	if strings.TrimSpace(header) == "" {
+		return nil
+	}
+	r := csv.NewReader(strings.NewReader(header))
+	r.LazyQuotes = true
+	r.TrimLeadingSpace = true
+	record, err := r.Read()
+	if err != nil {
+		record = strings.Split(header, ",")
+	}
+	var labels []string
+	for _, l := range record {
+		if l = strings.TrimSpace(l); l != "" {
+			labels = append(labels, l)
+		}
+	}
+	return labels
+}
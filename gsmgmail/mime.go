@@ -0,0 +1,352 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gsmgmail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/http"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MailHeader is an additional header of a composed message
+type MailHeader struct {
+	Name  string
+	Value string
+}
+
+// MailPart is an attachment or inline image of a composed message
+type MailPart struct {
+	Filename    string
+	ContentType string
+	ContentID   string
+	Data        []byte
+}
+
+// MailMessage is a message that can be composed into an RFC 5322 / MIME message.
+// If both Text and HTML are set, the message contains a multipart/alternative part.
+// Inline images are referenced in the HTML body by their ContentID (e.g. <img src="cid:logo.png">).
+// If the message has no HTML body, inline images are added as regular attachments.
+type MailMessage struct {
+	From         string
+	To           string
+	Cc           string
+	Bcc          string
+	ReplyTo      string
+	Subject      string
+	InReplyTo    string
+	References   string
+	Headers      []MailHeader
+	Text         string
+	HTML         string
+	Attachments  []*MailPart
+	InlineImages []*MailPart
+	Date         time.Time
+}
+
+// reservedMailHeaders are set by the composer and can't be used as custom headers
+var reservedMailHeaders = []string{"Content-Type", "Content-Transfer-Encoding", "Mime-Version", "Content-Disposition"}
+
+// ReadMailPart reads a file to be used as an attachment or inline image.
+// The Content-ID of the part is its file name.
+func ReadMailPart(path string) (*MailPart, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	name := filepath.Base(path)
+	contentType := mime.TypeByExtension(filepath.Ext(name))
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	return &MailPart{Filename: name, ContentType: contentType, ContentID: name, Data: data}, nil
+}
+
+// ParseMailHeader parses a custom header in the form "Name: value"
+func ParseMailHeader(s string) (MailHeader, error) {
+	name, value, ok := strings.Cut(s, ":")
+	name = textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name))
+	if !ok || name == "" || strings.ContainsAny(name, " \t") {
+		return MailHeader{}, fmt.Errorf("invalid header %q. Must be in the form \"Name: value\"", s)
+	}
+	for _, r := range reservedMailHeaders {
+		if strings.EqualFold(name, r) {
+			return MailHeader{}, fmt.Errorf("header %s is set automatically and can't be overridden", name)
+		}
+	}
+	return MailHeader{Name: name, Value: strings.TrimSpace(value)}, nil
+}
+
+// encodeAddressList RFC 2047-encodes the display names of a list of addresses
+func encodeAddressList(list string) (string, error) {
+	addresses, err := mail.ParseAddressList(list)
+	if err != nil {
+		return "", fmt.Errorf("invalid address list %q: %v", list, err)
+	}
+	s := make([]string, len(addresses))
+	for i := range addresses {
+		s[i] = addresses[i].String()
+	}
+	return strings.Join(s, ", "), nil
+}
+
+// foldHeader folds a header line at whitespace, so that lines don't exceed 78 characters where possible
+func foldHeader(line string) string {
+	if len(line) <= 78 {
+		return line
+	}
+	var b strings.Builder
+	lineLength := 0
+	for i, word := range strings.Split(line, " ") {
+		if i > 0 {
+			// The first word after the header name is never moved to a new line
+			if i > 1 && lineLength+1+len(word) > 78 {
+				b.WriteString("\r\n ")
+				lineLength = 1
+			} else {
+				b.WriteByte(' ')
+				lineLength++
+			}
+		}
+		b.WriteString(word)
+		lineLength += len(word)
+	}
+	return b.String()
+}
+
+func randomToken() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// writeQuotedPrintable writes s as quoted-printable with CRLF line endings
+func writeQuotedPrintable(w io.Writer, s string) error {
+	qp := quotedprintable.NewWriter(w)
+	_, err := qp.Write([]byte(strings.ReplaceAll(s, "\r\n", "\n")))
+	if err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// writeBase64 writes data as base64 with lines of 76 characters
+func writeBase64(w io.Writer, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		if _, err := io.WriteString(w, encoded[:76]+"\r\n"); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	_, err := io.WriteString(w, encoded+"\r\n")
+	return err
+}
+
+// mimePart is a node in the MIME tree of a message.
+// Leaf parts have a content function, multipart parts have children.
+type mimePart struct {
+	header   textproto.MIMEHeader
+	content  func(io.Writer) error
+	boundary string
+	children []*mimePart
+}
+
+func multipartPart(subtype string, children ...*mimePart) *mimePart {
+	boundary := randomToken()
+	return &mimePart{
+		header:   textproto.MIMEHeader{"Content-Type": {"multipart/" + subtype + "; boundary=" + boundary}},
+		boundary: boundary,
+		children: children,
+	}
+}
+
+func textPart(subtype, body string) *mimePart {
+	return &mimePart{
+		header: textproto.MIMEHeader{
+			"Content-Type":              {"text/" + subtype + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		},
+		content: func(w io.Writer) error {
+			return writeQuotedPrintable(w, body)
+		},
+	}
+}
+
+func filePart(p *MailPart, inline bool) *mimePart {
+	disposition := "attachment"
+	if inline {
+		disposition = "inline"
+	}
+	// The content type may already contain parameters, e.g. "text/plain; charset=utf-8"
+	mediaType, params, err := mime.ParseMediaType(p.ContentType)
+	if err != nil {
+		mediaType, params = "application/octet-stream", map[string]string{}
+	}
+	params["name"] = p.Filename
+	part := &mimePart{
+		header: textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(mediaType, params)},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType(disposition, map[string]string{"filename": p.Filename})},
+		},
+		content: func(w io.Writer) error {
+			return writeBase64(w, p.Data)
+		},
+	}
+	if inline && p.ContentID != "" {
+		part.header.Set("Content-ID", "<"+p.ContentID+">")
+	}
+	return part
+}
+
+func (p *mimePart) write(w io.Writer) error {
+	if p.content != nil {
+		return p.content(w)
+	}
+	mw := multipart.NewWriter(w)
+	err := mw.SetBoundary(p.boundary)
+	if err != nil {
+		return err
+	}
+	for _, c := range p.children {
+		pw, err := mw.CreatePart(c.header)
+		if err != nil {
+			return err
+		}
+		err = c.write(pw)
+		if err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// mimeTree returns the root of the MIME tree of the message:
+// multipart/mixed (attachments) > multipart/related (inline images) > multipart/alternative (text and HTML)
+func (m *MailMessage) mimeTree() *mimePart {
+	var root *mimePart
+	switch {
+	case m.Text != "" && m.HTML != "":
+		root = multipartPart("alternative", textPart("plain", m.Text), textPart("html", m.HTML))
+	case m.HTML != "":
+		root = textPart("html", m.HTML)
+	default:
+		root = textPart("plain", m.Text)
+	}
+	attachments := m.Attachments
+	if len(m.InlineImages) > 0 {
+		if m.HTML != "" {
+			root = multipartPart("related", root)
+			for _, p := range m.InlineImages {
+				root.children = append(root.children, filePart(p, true))
+			}
+		} else {
+			// Without an HTML body, the images can't be referenced, so they are attached instead
+			attachments = append(attachments[:len(attachments):len(attachments)], m.InlineImages...)
+		}
+	}
+	if len(attachments) > 0 {
+		root = multipartPart("mixed", root)
+		for _, p := range attachments {
+			root.children = append(root.children, filePart(p, false))
+		}
+	}
+	return root
+}
+
+// Bytes composes the message with CRLF line endings
+func (m *MailMessage) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	header := func(name, value string) {
+		buf.WriteString(foldHeader(name+": "+value) + "\r\n")
+	}
+	date := m.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	header("Date", date.Format(time.RFC1123Z))
+	for _, h := range []struct{ name, value string }{{"From", m.From}, {"Reply-To", m.ReplyTo}, {"To", m.To}, {"Cc", m.Cc}, {"Bcc", m.Bcc}} {
+		if strings.TrimSpace(h.value) == "" {
+			continue
+		}
+		encoded, err := encodeAddressList(h.value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", h.name, err)
+		}
+		header(h.name, encoded)
+	}
+	if from, err := mail.ParseAddress(m.From); err == nil {
+		if _, domain, ok := strings.Cut(from.Address, "@"); ok {
+			header("Message-ID", "<"+randomToken()+"@"+domain+">")
+		}
+	}
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	if m.InReplyTo != "" {
+		header("In-Reply-To", m.InReplyTo)
+	}
+	if m.References != "" {
+		header("References", m.References)
+	}
+	for _, h := range m.Headers {
+		header(h.Name, mime.QEncoding.Encode("utf-8", h.Value))
+	}
+	header("MIME-Version", "1.0")
+	root := m.mimeTree()
+	for _, k := range []string{"Content-Type", "Content-Transfer-Encoding"} {
+		if v := root.header.Get(k); v != "" {
+			header(k, v)
+		}
+	}
+	buf.WriteString("\r\n")
+	err := root.write(&buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Raw returns the composed message as a base64url encoded string, as expected by the Gmail API
+func (m *MailMessage) Raw() (string, error) {
+	b, err := m.Bytes()
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(b), nil
+}
+
+// DecodeRaw decodes the base64url encoded raw field of a message returned by the Gmail API
+func DecodeRaw(raw string) ([]byte, error) {
+	b, err := base64.URLEncoding.DecodeString(raw)
+	if err != nil {
+		return base64.RawURLEncoding.DecodeString(strings.TrimRight(raw, "="))
+	}
+	return b, nil
+}
@@ -85,7 +85,20 @@ func GetSendAs(userID, sendAsEmail, fields string) (*gmail.SendAs, error) {
 // ListSendAs lists the send-as aliases for the specified account.
 // The result includes the primary send-as address associated with the account as well as any custom "from" aliases.
 func ListSendAs(userID, fields string) ([]*gmail.SendAs, error) {
-	srv := getUsersSettingsSendAsService()
+	return listSendAs(getUsersSettingsSendAsService(), userID, fields)
+}
+
+// ListSendAsAsUser lists the send-as aliases for the specified account, impersonating the user (see SetSubjectClientFunc).
+// Gmail only allows access to the settings of the authenticated user, so this is required when using domain-wide delegation with a different subject.
+func ListSendAsAsUser(userID, fields string) ([]*gmail.SendAs, error) {
+	srv, err := getUsersSettingsSendAsServiceAsUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	return listSendAs(srv, userID, fields)
+}
+
+func listSendAs(srv *gmail.UsersSettingsSendAsService, userID, fields string) ([]*gmail.SendAs, error) {
 	c := srv.List(userID)
 	if fields != "" {
 		c.Fields(googleapi.Field(fields))
@@ -105,7 +118,19 @@ func ListSendAs(userID, fields string) ([]*gmail.SendAs, error) {
 
 // PatchSendAs PATCHes the specified send-as alias.
 func PatchSendAs(userID, sendAsEmail, fields string, sendAs *gmail.SendAs) (*gmail.SendAs, error) {
-	srv := getUsersSettingsSendAsService()
+	return patchSendAs(getUsersSettingsSendAsService(), userID, sendAsEmail, fields, sendAs)
+}
+
+// PatchSendAsAsUser PATCHes the specified send-as alias, impersonating the user (see ListSendAsAsUser).
+func PatchSendAsAsUser(userID, sendAsEmail, fields string, sendAs *gmail.SendAs) (*gmail.SendAs, error) {
+	srv, err := getUsersSettingsSendAsServiceAsUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	return patchSendAs(srv, userID, sendAsEmail, fields, sendAs)
+}
+
+func patchSendAs(srv *gmail.UsersSettingsSendAsService, userID, sendAsEmail, fields string, sendAs *gmail.SendAs) (*gmail.SendAs, error) {
 	c := srv.Patch(userID, sendAsEmail, sendAs)
 	if fields != "" {
 		c.Fields(googleapi.Field(fields))
@@ -20,6 +20,7 @@ package gsmgmail
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 
@@ -43,6 +44,7 @@ var (
 	usersSettingsSendAsService              *gmail.UsersSettingsSendAsService
 	usersSettingsSendAsSmimeInfoService     *gmail.UsersSettingsSendAsSmimeInfoService
 	usersThreadsService                     *gmail.UsersThreadsService
+	subjectClientFunc                       func(subject string) (*http.Client, error)
 )
 
 // SetClient is used to inject a *http.Client into the package
@@ -50,6 +52,12 @@ func SetClient(c *http.Client) {
 	client = c
 }
 
+// SetSubjectClientFunc is used to inject a function that returns a *http.Client that impersonates a specific user (domain-wide delegation).
+// It is only used by functions that explicitly act as the user, such as ListSendAsAsUser and PatchSendAsAsUser.
+func SetSubjectClientFunc(f func(subject string) (*http.Client, error)) {
+	subjectClientFunc = f
+}
+
 func getGmailService() *gmail.Service {
 	if client == nil {
 		log.Fatalf("gsmgmail.client is not set. Set with gsmgmail.SetClient(client)")
@@ -154,3 +162,20 @@ func getUsersThreadsService() *gmail.UsersThreadsService {
 	}
 	return usersThreadsService
 }
+
+// getUsersSettingsSendAsServiceAsUser returns a send-as service that impersonates userID.
+// If no function to create clients for a subject is set (e.g. when authenticating as a user), the default service is returned.
+func getUsersSettingsSendAsServiceAsUser(userID string) (*gmail.UsersSettingsSendAsService, error) {
+	if subjectClientFunc == nil {
+		return getUsersSettingsSendAsService(), nil
+	}
+	c, err := subjectClientFunc(userID)
+	if err != nil {
+		return nil, fmt.Errorf("error creating client for %s: %v", userID, err)
+	}
+	srv, err := gmail.NewService(context.Background(), option.WithHTTPClient(c))
+	if err != nil {
+		return nil, fmt.Errorf("error creating gmail service for %s: %v", userID, err)
+	}
+	return srv.Users.Settings.SendAs, nil
+}
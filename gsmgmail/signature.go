@@ -0,0 +1,222 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gsmgmail
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// maxSignatureLength is the maximum length of a signature that Gmail accepts
+const maxSignatureLength = 10000
+
+var signatureWhitespace = regexp.MustCompile(`\s+`)
+
+// signatureUnsafeElements are removed from signatures, including their content
+var signatureUnsafeElements = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"iframe":   true,
+	"frame":    true,
+	"frameset": true,
+	"object":   true,
+	"applet":   true,
+	"embed":    true,
+	"form":     true,
+	"input":    true,
+	"button":   true,
+	"textarea": true,
+	"select":   true,
+	"meta":     true,
+	"link":     true,
+	"base":     true,
+	"svg":      true,
+	"math":     true,
+}
+
+// signatureURLAttributes are attributes that contain URLs
+var signatureURLAttributes = map[string]bool{
+	"href":       true,
+	"src":        true,
+	"action":     true,
+	"formaction": true,
+	"background": true,
+	"lowsrc":     true,
+	"dynsrc":     true,
+	"poster":     true,
+}
+
+// unsafeSignatureURL returns true if the URL uses a scheme that can execute code or embed content
+func unsafeSignatureURL(url string) bool {
+	// Browsers ignore whitespace and control characters in the scheme
+	url = strings.ToLower(strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, url))
+	for _, scheme := range []string{"javascript:", "vbscript:", "data:"} {
+		if strings.HasPrefix(url, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizeSignatureAttributes removes event handlers and replaces unsafe URLs with "#"
+func sanitizeSignatureAttributes(attributes []html.Attribute) []html.Attribute {
+	var safe []html.Attribute
+	for _, a := range attributes {
+		key := strings.ToLower(a.Key)
+		if strings.HasPrefix(key, "on") {
+			continue
+		}
+		if (signatureURLAttributes[key] || strings.HasSuffix(key, ":href")) && unsafeSignatureURL(a.Val) {
+			a.Val = "#"
+		}
+		safe = append(safe, a)
+	}
+	return safe
+}
+
+// ParseSignatureTemplate parses an HTML signature template (https://pkg.go.dev/html/template).
+// Missing fields are rendered as empty strings.
+func ParseSignatureTemplate(path string) (*htmltemplate.Template, error) {
+	t, err := htmltemplate.ParseFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	return t.Option("missingkey=zero"), nil
+}
+
+// RenderSignature renders a signature template with the given data and sanitizes the result (see SanitizeSignature)
+func RenderSignature(t *htmltemplate.Template, data any) (string, error) {
+	var b strings.Builder
+	err := t.Execute(&b, data)
+	if err != nil {
+		return "", err
+	}
+	signature := SanitizeSignature(b.String())
+	if len(signature) > maxSignatureLength {
+		return "", fmt.Errorf("signature is %d characters long. Gmail only allows %d characters", len(signature), maxSignatureLength)
+	}
+	return signature, nil
+}
+
+// SanitizeSignature removes elements, attributes and URLs that Gmail doesn't allow in signatures (scripts, styles, forms, event handlers, etc.),
+// as well as comments and whitespace between tags.
+// The signature is parsed with an HTML tokenizer, so unquoted attributes and unusual tag syntax are handled like a browser would.
+func SanitizeSignature(signature string) string {
+	var b strings.Builder
+	z := html.NewTokenizer(strings.NewReader(signature))
+	// skip is the unsafe element whose content is currently removed and depth the number of nested elements with the same name
+	skip := ""
+	depth := 0
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		// The raw text is kept as is, so that the text of the signature isn't re-encoded
+		raw := string(z.Raw())
+		t := z.Token()
+		if skip != "" {
+			switch {
+			case tt == html.StartTagToken && t.Data == skip:
+				depth++
+			case tt == html.EndTagToken && t.Data == skip:
+				depth--
+				if depth == 0 {
+					skip = ""
+				}
+			}
+			continue
+		}
+		switch tt {
+		case html.TextToken:
+			if strings.TrimSpace(t.Data) != "" {
+				b.WriteString(raw)
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if signatureUnsafeElements[t.Data] {
+				if tt == html.StartTagToken && !signatureVoidElement(t.Data) {
+					skip = t.Data
+					depth = 1
+				}
+				continue
+			}
+			t.Attr = sanitizeSignatureAttributes(t.Attr)
+			b.WriteString(t.String())
+		case html.EndTagToken:
+			if !signatureUnsafeElements[t.Data] {
+				b.WriteString(t.String())
+			}
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// signatureVoidElement returns true for unsafe elements that never have content or an end tag
+func signatureVoidElement(name string) bool {
+	switch name {
+	case "input", "meta", "link", "base", "embed", "frame":
+		return true
+	}
+	return false
+}
+
+// normalizeSignature renders a signature in a canonical form: tags with their attributes quoted and escaped in the same way,
+// text with decoded HTML entities, non-breaking spaces as spaces and collapsed whitespace. Whitespace between tags is removed.
+func normalizeSignature(signature string) string {
+	var b, text strings.Builder
+	flush := func() {
+		s := strings.ReplaceAll(text.String(), "\u00a0", " ")
+		if strings.TrimSpace(s) != "" {
+			b.WriteString(signatureWhitespace.ReplaceAllString(s, " "))
+		}
+		text.Reset()
+	}
+	z := html.NewTokenizer(strings.NewReader(signature))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		t := z.Token()
+		if tt == html.TextToken {
+			text.WriteString(t.Data)
+			continue
+		}
+		flush()
+		if tt == html.StartTagToken || tt == html.EndTagToken || tt == html.SelfClosingTagToken {
+			b.WriteString(t.String())
+		}
+	}
+	flush()
+	return strings.TrimSpace(b.String())
+}
+
+// SignatureEqual returns true if two signatures only differ in whitespace, the quoting of attributes or the encoding of HTML entities
+// (e.g., html/template renders "+" as "&#43;", while Gmail returns the character itself).
+func SignatureEqual(a, b string) bool {
+	return normalizeSignature(a) == normalizeSignature(b)
+}
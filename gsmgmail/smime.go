@@ -0,0 +1,108 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gsmgmail
+
+import (
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// oidEmailAddress is the OID of the (deprecated) emailAddress attribute in the subject of a certificate
+var oidEmailAddress = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 1}
+
+// SmimeCertificate is the certificate of a PKCS#12 file
+type SmimeCertificate struct {
+	Path string
+	// Emails are the email addresses of the certificate's subject alternative names and subject
+	Emails    []string
+	IssuerCN  string
+	NotBefore time.Time
+	NotAfter  time.Time
+	pkcs12    []byte
+}
+
+// ReadSmimeCertificate reads a PKCS#12 file and parses its certificate.
+// Files encrypted with legacy algorithms (3DES / RC2) and with PBES2 / AES (the default of OpenSSL 3) are supported.
+// If the file contains a certificate chain, the certificate that belongs to the private key is used.
+func ReadSmimeCertificate(path, password string) (*SmimeCertificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	_, cert, _, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding %s: %v", path, err)
+	}
+	s := &SmimeCertificate{
+		Path:      path,
+		IssuerCN:  cert.Issuer.CommonName,
+		NotBefore: cert.NotBefore,
+		NotAfter:  cert.NotAfter,
+		pkcs12:    data,
+	}
+	emails := cert.EmailAddresses
+	for _, n := range cert.Subject.Names {
+		if e, ok := n.Value.(string); ok && n.Type.Equal(oidEmailAddress) {
+			emails = append(emails, e)
+		}
+	}
+	for _, e := range emails {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if e != "" && !HasSmimeEmail(s.Emails, e) {
+			s.Emails = append(s.Emails, e)
+		}
+	}
+	return s, nil
+}
+
+// HasSmimeEmail returns true if the email address is one of the (lower case) addresses
+func HasSmimeEmail(emails []string, email string) bool {
+	email = strings.ToLower(email)
+	for _, e := range emails {
+		if e == email {
+			return true
+		}
+	}
+	return false
+}
+
+// Expired returns true if the certificate is not valid at the given time
+func (c *SmimeCertificate) Expired(t time.Time) bool {
+	return t.After(c.NotAfter)
+}
+
+// SmimeInfo returns a SmimeInfo that can be used to upload the certificate with InsertSmimeInfo
+func (c *SmimeCertificate) SmimeInfo(encryptedKeyPassword string) *gmail.SmimeInfo {
+	return &gmail.SmimeInfo{
+		Pkcs12:               base64.URLEncoding.EncodeToString(c.pkcs12),
+		EncryptedKeyPassword: encryptedKeyPassword,
+	}
+}
+
+// Uploaded returns true if the SmimeInfo (most likely) contains this certificate.
+// The API doesn't return the certificate itself, so the issuer and expiration are compared.
+func (c *SmimeCertificate) Uploaded(info *gmail.SmimeInfo) bool {
+	return info.Expiration == c.NotAfter.UnixMilli() && strings.EqualFold(info.IssuerCn, c.IssuerCN)
+}
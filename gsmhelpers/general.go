@@ -19,14 +19,19 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 package gsmhelpers
 
 import (
+	crand "crypto/rand"
+	"encoding/base64"
 	"encoding/csv"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"math/rand/v2"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -75,9 +80,93 @@ func GetCSVContent(path string, delimiter rune, skipHeader bool) ([][]string, er
 	return csv, nil
 }
 
+// GetCSVRecords reads a CSV file with a header and returns each line as a map of header to value
+func GetCSVRecords(path string, delimiter rune) ([]map[string]string, error) {
+	content, err := GetCSVContent(path, delimiter, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(content) == 0 {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+	records := make([]map[string]string, 0, len(content)-1)
+	for _, line := range content[1:] {
+		record := make(map[string]string)
+		for i := range content[0] {
+			if i < len(line) {
+				record[content[0][i]] = line[i]
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// GetJSONRecords reads a JSON file that contains an array of flat objects and returns each object as a map of key to value.
+// Values that are not strings are converted to their string representation.
+func GetJSONRecords(path string) ([]map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer CloseLog(f, path)
+	dec := json.NewDecoder(f)
+	dec.UseNumber()
+	var objects []map[string]any
+	err = dec.Decode(&objects)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	records := make([]map[string]string, 0, len(objects))
+	for i := range objects {
+		record := make(map[string]string)
+		for k, v := range objects[i] {
+			switch v := v.(type) {
+			case nil:
+				record[k] = ""
+			case string:
+				record[k] = v
+			default:
+				record[k] = fmt.Sprint(v)
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// WriteFileAtomic writes a file via a temporary file, so that an interrupted write never leaves a partial file.
+// Missing parent directories are created.
+func WriteFileAtomic(path string, data []byte) error {
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	err = os.WriteFile(tmp, data, 0o644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
 // formatError adds an errKey prefix to an error message
 func formatError(err error, errKey string) error {
-	return fmt.Errorf("%s: %v", errKey, err)
+	return fmt.Errorf("%s: %w", errKey, err)
+}
+
+// ErrorCode returns the HTTP status code of a Google API error or 0 if err is not (and does not wrap) a Google API error
+func ErrorCode(err error) int {
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) {
+		return gerr.Code
+	}
+	return 0
+}
+
+// IsNotFound returns true if err is (or wraps) a Google API error with the HTTP status code 404
+func IsNotFound(err error) bool {
+	return ErrorCode(err) == 404
 }
 
 // logError returns a retryable error, indicating that the operation should be reattempted or nil if no error occurred or if the error is not retryable
@@ -317,6 +406,59 @@ func GetBatchMaps(cmd *cobra.Command, cmdFlags map[string]*Flag) (<-chan map[str
 	return maps, nil
 }
 
+// GetBatchMapsWithHeader works like GetBatchMaps, but always treats the first line of the CSV file as a header.
+// In addition to the flag values, each map contains the value of every column whose header does not match a flag name, keyed by the header.
+// A column value is only marked as changed if the cell is not empty.
+func GetBatchMapsWithHeader(cmd *cobra.Command, cmdFlags map[string]*Flag) (<-chan map[string]*Value, error) {
+	flags, err := consolidateFlags(cmd, cmdFlags)
+	if err != nil {
+		return nil, fmt.Errorf("error consolidating flags: %v", err)
+	}
+	csvReader, err := getCSVReader(flags)
+	if err != nil {
+		return nil, fmt.Errorf("error with CSV file: %v", err)
+	}
+	threads := MaxThreads(flags["batchThreads"].GetInt())
+	maps := make(chan map[string]*Value, threads)
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, err
+	}
+	err = checkBatchFlags(flags, cmdFlags, int64(len(header)))
+	if err != nil {
+		return nil, fmt.Errorf("error with batch flag index: %v", err)
+	}
+	cmdName := cmd.Parent().Use
+	i := 0
+	go func() {
+		defer close(maps)
+		for {
+			i++
+			line, err := csvReader.Read()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				log.Printf("Error reading line %d: %v\n", i, err)
+				continue
+			}
+			m := batchFlagsToMap(flags, cmdFlags, line, cmdName)
+			for j := range header {
+				if _, found := m[header[j]]; found || j >= len(line) {
+					continue
+				}
+				m[header[j]] = &Value{
+					Value:   line[j],
+					Type:    "string",
+					Changed: line[j] != "",
+				}
+			}
+			maps <- m
+		}
+	}()
+	return maps, nil
+}
+
 // GetObjectRetry performs an action that returns an object, retrying on failure when appropriate
 func GetObjectRetry(errKey string, c func() (any, error)) (any, error) {
 	result, err := backoff.RetryNotifyWithData(func() (any, error) {
@@ -392,6 +534,30 @@ func EnsurePrefix(s, p string) string {
 	return s
 }
 
+// ParseAge parses a duration like time.ParseDuration, but also accepts whole days ("90d") and weeks ("12w")
+func ParseAge(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			i, err := strconv.Atoi(n)
+			if err != nil || i < 0 {
+				return 0, fmt.Errorf("invalid duration %q", s)
+			}
+			return time.Duration(i) * unit, nil
+		}
+	}
+	return time.ParseDuration(s)
+}
+
+// RandomPassword returns a random password, generated from n random bytes
+func RandomPassword(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := crand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
 // CloseLog is used when deferring Close() because we also want to check for the error of the Close()
 func CloseLog(closer io.Closer, resource string) {
 	err := closer.Close()
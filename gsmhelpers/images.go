@@ -0,0 +1,97 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gsmhelpers
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+
+	// Register additional decoders for image.Decode
+	_ "image/gif"
+	_ "image/png"
+)
+
+// ResizeImage scales img down so that neither side is larger than maxDimension, keeping the aspect ratio.
+// Every pixel of the result is the average of the source pixels it covers.
+// Images that are already small enough are returned unchanged.
+func ResizeImage(img image.Image, maxDimension int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if maxDimension <= 0 || (w <= maxDimension && h <= maxDimension) {
+		return img
+	}
+	nw, nh := maxDimension, maxDimension
+	if w > h {
+		nh = max(1, h*maxDimension/w)
+	} else {
+		nw = max(1, w*maxDimension/h)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, nw, nh))
+	for y := range nh {
+		y0 := b.Min.Y + y*h/nh
+		y1 := max(y0+1, b.Min.Y+(y+1)*h/nh)
+		for x := range nw {
+			x0 := b.Min.X + x*w/nw
+			x1 := max(x0+1, b.Min.X+(x+1)*w/nw)
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					bl += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
+
+// NormalizeImageToJPEG decodes a JPEG, PNG or GIF image, scales it down to maxDimension and encodes it as a JPEG.
+// Transparent areas are filled with white.
+// If the result is larger than maxBytes, the quality is reduced until it fits.
+func NormalizeImageToJPEG(r io.Reader, maxDimension, maxBytes, quality int) ([]byte, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	img = ResizeImage(img, maxDimension)
+	b := img.Bounds()
+	flat := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(flat, flat.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, b.Min, draw.Over)
+	for q := quality; q > 0; q -= 10 {
+		var buf bytes.Buffer
+		err = jpeg.Encode(&buf, flat, &jpeg.Options{Quality: q})
+		if err != nil {
+			return nil, err
+		}
+		if maxBytes <= 0 || buf.Len() <= maxBytes {
+			return buf.Bytes(), nil
+		}
+	}
+	return nil, fmt.Errorf("image is larger than %d bytes even at the lowest quality", maxBytes)
+}
@@ -0,0 +1,245 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gsmhelpers
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// LDIFAttribute is a single attribute value of an LDIF entry
+type LDIFAttribute struct {
+	Name  string
+	Value string
+}
+
+// LDIFEntry represents a single entry (record) of an LDIF file
+type LDIFEntry struct {
+	DN         string
+	Attributes []LDIFAttribute
+}
+
+// Get returns all values of an attribute.
+// Attribute names are case-insensitive and attribute options (e.g. ";lang-en") are ignored.
+func (e *LDIFEntry) Get(name string) []string {
+	var values []string
+	for i := range e.Attributes {
+		n, _, _ := strings.Cut(e.Attributes[i].Name, ";")
+		if strings.EqualFold(n, name) {
+			values = append(values, e.Attributes[i].Value)
+		}
+	}
+	return values
+}
+
+// GetFirst returns the first value of an attribute or an empty string if the attribute is not set
+func (e *LDIFEntry) GetFirst(name string) string {
+	values := e.Get(name)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// Add adds one or more values of an attribute to the entry. Empty values are ignored.
+func (e *LDIFEntry) Add(name string, values ...string) {
+	for i := range values {
+		if values[i] != "" {
+			e.Attributes = append(e.Attributes, LDIFAttribute{Name: name, Value: values[i]})
+		}
+	}
+}
+
+// NormalizeDN returns a DN in a form that can be used to compare it to other DNs
+func NormalizeDN(dn string) string {
+	rdns := strings.Split(dn, ",")
+	for i := range rdns {
+		rdns[i] = strings.TrimSpace(rdns[i])
+	}
+	return strings.ToLower(strings.Join(rdns, ","))
+}
+
+// parseLDIFLine parses a single (unfolded) line of an LDIF file
+func parseLDIFLine(line string) (string, string, error) {
+	name, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return "", "", fmt.Errorf("invalid line %q", line)
+	}
+	switch {
+	case strings.HasPrefix(value, ":"):
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value[1:]))
+		if err != nil {
+			return "", "", fmt.Errorf("error decoding value of %s: %v", name, err)
+		}
+		return name, string(decoded), nil
+	case strings.HasPrefix(value, "<"):
+		return "", "", fmt.Errorf("URL values are not supported (%s)", name)
+	}
+	return name, strings.TrimLeft(value, " "), nil
+}
+
+// ParseLDIF parses the content records of an LDIF file (RFC 2849).
+// Change records are only supported if their changetype is "add".
+func ParseLDIF(r io.Reader) ([]*LDIFEntry, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	var entries []*LDIFEntry
+	var lines []string
+	var comment bool
+	flush := func() error {
+		if len(lines) == 0 {
+			return nil
+		}
+		entry := &LDIFEntry{}
+		for i := range lines {
+			name, value, err := parseLDIFLine(lines[i])
+			if err != nil {
+				return err
+			}
+			switch {
+			case i == 0 && strings.EqualFold(name, "version"):
+				continue
+			case strings.EqualFold(name, "dn"):
+				entry.DN = value
+			case strings.EqualFold(name, "changetype"):
+				if !strings.EqualFold(value, "add") {
+					return fmt.Errorf("%s: unsupported changetype %s", entry.DN, value)
+				}
+			default:
+				entry.Add(name, value)
+			}
+		}
+		lines = nil
+		if entry.DN == "" {
+			if len(entry.Attributes) == 0 {
+				return nil
+			}
+			return fmt.Errorf("entry without dn")
+		}
+		entries = append(entries, entry)
+		return nil
+	}
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		switch {
+		case line == "":
+			comment = false
+			err := flush()
+			if err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(line, " "):
+			if comment {
+				continue
+			}
+			if len(lines) == 0 {
+				return nil, fmt.Errorf("continuation line without preceding line: %q", line)
+			}
+			lines[len(lines)-1] += line[1:]
+		case strings.HasPrefix(line, "#"):
+			comment = true
+		default:
+			comment = false
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	err := flush()
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ldifSafeString returns true if a value can be written to an LDIF file without base64 encoding
+func ldifSafeString(s string) bool {
+	if s == "" {
+		return true
+	}
+	if s[0] == ' ' || s[0] == ':' || s[0] == '<' || s[len(s)-1] == ' ' {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] == 0 || s[i] == '\n' || s[i] == '\r' || s[i] > 127 {
+			return false
+		}
+	}
+	return true
+}
+
+// writeLDIFLine writes an attribute to an LDIF file, folding lines longer than 76 characters (including the leading space of continuation lines)
+func writeLDIFLine(w *bufio.Writer, name, value string) error {
+	var line string
+	if ldifSafeString(value) {
+		line = name + ": " + value
+	} else {
+		line = name + ":: " + base64.StdEncoding.EncodeToString([]byte(value))
+	}
+	// Continuation lines start with a space, so they contain one character less
+	width := 76
+	for len(line) > width {
+		_, err := w.WriteString(line[:width] + "\n ")
+		if err != nil {
+			return err
+		}
+		line = line[width:]
+		width = 75
+	}
+	_, err := w.WriteString(line + "\n")
+	return err
+}
+
+// WriteLDIF writes entries to w in the LDIF format (RFC 2849).
+// Attributes are written in the order in which they were added to the entry.
+func WriteLDIF(w io.Writer, entries []*LDIFEntry) error {
+	bw := bufio.NewWriter(w)
+	_, err := bw.WriteString("version: 1\n")
+	if err != nil {
+		return err
+	}
+	for i := range entries {
+		_, err = bw.WriteString("\n")
+		if err != nil {
+			return err
+		}
+		err = writeLDIFLine(bw, "dn", entries[i].DN)
+		if err != nil {
+			return err
+		}
+		for j := range entries[i].Attributes {
+			err = writeLDIFLine(bw, entries[i].Attributes[j].Name, entries[i].Attributes[j].Value)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// SortLDIFEntries sorts entries by their DN
+func SortLDIFEntries(entries []*LDIFEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		return NormalizeDN(entries[i].DN) < NormalizeDN(entries[j].DN)
+	})
+}
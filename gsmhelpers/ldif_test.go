@@ -0,0 +1,172 @@
+/*
+Copyright © 2020 Hannes Hayashi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gsmhelpers
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLDIF(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []*LDIFEntry
+		wantErr bool
+	}{
+		{
+			name:  "simple entry",
+			input: "version: 1\n\ndn: uid=alice,dc=example,dc=com\nmail: alice@example.com\ncn: Alice\n",
+			want: []*LDIFEntry{
+				{DN: "uid=alice,dc=example,dc=com", Attributes: []LDIFAttribute{{"mail", "alice@example.com"}, {"cn", "Alice"}}},
+			},
+		},
+		{
+			name:  "folded lines",
+			input: "dn: uid=alice,\n dc=example,dc=com\ndescription: a long\n  description\n",
+			want: []*LDIFEntry{
+				{DN: "uid=alice,dc=example,dc=com", Attributes: []LDIFAttribute{{"description", "a long description"}}},
+			},
+		},
+		{
+			name:  "base64 values",
+			input: "dn:: dWlkPWrDtnJnLGRjPWV4YW1wbGUsZGM9Y29t\ncn:: SsO2cmcgTcO8bGxlcg==\n",
+			want: []*LDIFEntry{
+				{DN: "uid=jörg,dc=example,dc=com", Attributes: []LDIFAttribute{{"cn", "Jörg Müller"}}},
+			},
+		},
+		{
+			name:  "folded base64 value",
+			input: "dn: uid=jorg,dc=example,dc=com\ncn:: SsO2cmcgT\n cO8bGxlcg==\n",
+			want: []*LDIFEntry{
+				{DN: "uid=jorg,dc=example,dc=com", Attributes: []LDIFAttribute{{"cn", "Jörg Müller"}}},
+			},
+		},
+		{
+			name:  "comments, CRLF and multiple entries",
+			input: "# export\r\n#  continued comment\r\ndn: uid=a,dc=example,dc=com\r\nmail: a@example.com\r\n\r\n\r\ndn: uid=b,dc=example,dc=com\r\nchangetype: add\r\nmail: b@example.com\r\n",
+			want: []*LDIFEntry{
+				{DN: "uid=a,dc=example,dc=com", Attributes: []LDIFAttribute{{"mail", "a@example.com"}}},
+				{DN: "uid=b,dc=example,dc=com", Attributes: []LDIFAttribute{{"mail", "b@example.com"}}},
+			},
+		},
+		{
+			name:    "unsupported changetype",
+			input:   "dn: uid=a,dc=example,dc=com\nchangetype: modify\n",
+			wantErr: true,
+		},
+		{
+			name:    "URL value",
+			input:   "dn: uid=a,dc=example,dc=com\njpegPhoto:< file:///tmp/a.jpg\n",
+			wantErr: true,
+		},
+		{
+			name:    "continuation without line",
+			input:   " dn: uid=a,dc=example,dc=com\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLDIF(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLDIF() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLDIF() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteLDIFRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry *LDIFEntry
+		// encoded is a substring that must be part of the output
+		encoded string
+	}{
+		{
+			name:    "plain values",
+			entry:   &LDIFEntry{DN: "uid=alice,dc=example,dc=com", Attributes: []LDIFAttribute{{"mail", "alice@example.com"}, {"telephoneNumber", "+49 30 1234"}, {"telephoneNumber", "+49 30 5678"}}},
+			encoded: "telephoneNumber: +49 30 5678\n",
+		},
+		{
+			name:    "non-ASCII value",
+			entry:   &LDIFEntry{DN: "uid=jorg,dc=example,dc=com", Attributes: []LDIFAttribute{{"cn", "Jörg Müller"}}},
+			encoded: "cn:: SsO2cmcgTcO8bGxlcg==\n",
+		},
+		{
+			name:    "leading space and colon",
+			entry:   &LDIFEntry{DN: "uid=a,dc=example,dc=com", Attributes: []LDIFAttribute{{"description", " leading space"}, {"title", ":colon"}}},
+			encoded: "description:: IGxlYWRpbmcgc3BhY2U=\n",
+		},
+		{
+			name:    "folded value",
+			entry:   &LDIFEntry{DN: "uid=a,dc=example,dc=com", Attributes: []LDIFAttribute{{"description", strings.Repeat("0123456789", 20)}}},
+			encoded: "\n 3456789012",
+		},
+		{
+			name:  "folded base64 value",
+			entry: &LDIFEntry{DN: "uid=a,dc=example,dc=com", Attributes: []LDIFAttribute{{"description", strings.Repeat("äöü", 30)}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := WriteLDIF(&buf, []*LDIFEntry{tt.entry})
+			if err != nil {
+				t.Fatalf("WriteLDIF() error = %v", err)
+			}
+			out := buf.String()
+			if !strings.HasPrefix(out, "version: 1\n") {
+				t.Errorf("WriteLDIF() output doesn't start with the version: %q", out)
+			}
+			if !strings.Contains(out, tt.encoded) {
+				t.Errorf("WriteLDIF() output doesn't contain %q:\n%s", tt.encoded, out)
+			}
+			for _, line := range strings.Split(out, "\n") {
+				if len(line) > 76 {
+					t.Errorf("line is not folded (%d characters): %q", len(line), line)
+				}
+			}
+			got, err := ParseLDIF(&buf)
+			if err != nil {
+				t.Fatalf("ParseLDIF() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, []*LDIFEntry{tt.entry}) {
+				t.Errorf("round trip = %+v, want %+v", got, tt.entry)
+			}
+		})
+	}
+}
+
+func TestLDIFEntryGet(t *testing.T) {
+	entry := &LDIFEntry{DN: "uid=a,dc=example,dc=com", Attributes: []LDIFAttribute{{"cn", "Anna"}, {"CN;lang-de", "Anna (de)"}, {"mail", "a@example.com"}}}
+	if got := entry.Get("cn"); !reflect.DeepEqual(got, []string{"Anna", "Anna (de)"}) {
+		t.Errorf("Get(cn) = %v", got)
+	}
+	if got := entry.GetFirst("Mail"); got != "a@example.com" {
+		t.Errorf("GetFirst(Mail) = %q", got)
+	}
+	if got := entry.GetFirst("sn"); got != "" {
+		t.Errorf("GetFirst(sn) = %q", got)
+	}
+}